@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"backend/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Helper function to read and validate a category from the request body.
+// The slug is derived from the name when left out.
+func bindCategory(c *gin.Context) (models.Category, bool) {
+	var input struct {
+		Name        string `json:"name"`
+		Slug        string `json:"slug"`
+		Description string `json:"description"`
+		Position    int    `json:"position"`
+		ParentID    *int   `json:"parentId"`
+		Posting     string `json:"posting"` // everyone (default) or admins
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return models.Category{}, false
+	}
+
+	category := models.Category{
+		Name:        strings.TrimSpace(input.Name),
+		Slug:        strings.TrimSpace(input.Slug),
+		Description: strings.TrimSpace(input.Description),
+		Position:    input.Position,
+		ParentID:    input.ParentID,
+		Posting:     input.Posting,
+	}
+	if category.Slug == "" {
+		category.Slug = models.Slugify(category.Name)
+	}
+	if category.Posting == "" {
+		category.Posting = models.PostingEveryone
+	}
+
+	var problem string
+	switch {
+	case category.Name == "":
+		problem = "Category name is required"
+	case utf8.RuneCountInString(category.Name) > 50:
+		problem = "Category name must be no more than 50 characters long"
+	case !models.ValidSlug(category.Slug):
+		problem = "Slug must contain only lowercase letters, digits and single dashes"
+	case utf8.RuneCountInString(category.Description) > 500:
+		problem = "Description must be no more than 500 characters long"
+	case category.Posting != models.PostingEveryone && category.Posting != models.PostingAdmins:
+		problem = "Posting must be everyone or admins"
+	}
+	if problem != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": problem})
+		return category, false
+	}
+	return category, true
+}
+
+// Helper function to answer category errors the admin can correct
+func categoryConflict(c *gin.Context, err error) bool {
+	switch err {
+	case models.ErrDuplicateCategory, models.ErrCategoryInUse:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case models.ErrUnknownParent, models.ErrCategoryCycle:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
+
+// Add a category, optionally below another one
+func (h *Handler) CreateCategory(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	category, ok := bindCategory(c)
+	if !ok {
+		return
+	}
+
+	categoryID, err := h.Categories.CreateCategory(category)
+	if categoryConflict(c, err) {
+		return
+	} else if err != nil {
+		log.Printf("Error creating category: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+		return
+	}
+
+	log.Printf("Admin '%s' created category %d (%s)", admin.Username, categoryID, category.Slug)
+	c.JSON(http.StatusCreated, gin.H{"message": "Category created", "id": categoryID, "slug": category.Slug})
+}
+
+// Replace a category's name, slug, description, order, parent and posting permission
+func (h *Handler) UpdateCategory(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	category, ok := bindCategory(c)
+	if !ok {
+		return
+	}
+	category.ID = categoryID
+
+	found, err := h.Categories.UpdateCategory(category)
+	if categoryConflict(c, err) {
+		return
+	} else if err != nil {
+		log.Printf("Error updating category: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	log.Printf("Admin '%s' updated category %d", admin.Username, categoryID)
+	c.JSON(http.StatusOK, gin.H{"message": "Category updated"})
+}
+
+// Remove a category that no longer holds threads or subcategories
+func (h *Handler) DeleteCategory(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	found, err := h.Categories.DeleteCategory(categoryID)
+	if categoryConflict(c, err) {
+		return
+	} else if err != nil {
+		log.Printf("Error deleting category: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	log.Printf("Admin '%s' deleted category %d", admin.Username, categoryID)
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted"})
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"backend/models"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reads the paging and sorting options of a comment tree from the query string
+func commentTreeQuery(c *gin.Context) models.CommentTreeQuery {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	depth, _ := strconv.Atoi(c.Query("depth"))
+	return models.CommentTreeQuery{
+		SortBy: c.DefaultQuery("sortBy", "created_at"),
+		Order:  c.Query("order"),
+		Limit:  limit,
+		Offset: offset,
+		Depth:  depth,
+	}
+}
+
+// Fetches a page of the comment tree below a post, writing an error response
+// if that fails
+func (h *Handler) fetchCommentTree(c *gin.Context, parentID int) ([]*models.CommentNode, int, bool) {
+	replies, more, err := h.Threads.FetchCommentTree(parentID, commentTreeQuery(c))
+	if err == models.ErrInvalidOrder {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, 0, false
+	} else if err != nil {
+		log.Printf("Error fetching comment tree: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return nil, 0, false
+	}
+
+	var posts []*models.Thread
+	var collect func(nodes []*models.CommentNode)
+	collect = func(nodes []*models.CommentNode) {
+		for _, node := range nodes {
+			posts = append(posts, &node.Thread)
+			collect(node.Replies)
+		}
+	}
+	collect(replies)
+	h.tokenize(posts...)
+	return replies, more, true
+}
+
+// Responds with a thread and the first page of its comment tree
+func (h *Handler) getThreadTree(c *gin.Context, thread *models.Thread) {
+	replies, more, ok := h.fetchCommentTree(c, thread.ID)
+	if !ok {
+		return
+	}
+	if thread.Deleted && len(replies) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+	thread.Redact()
+	h.tokenize(thread)
+
+	c.JSON(http.StatusOK, gin.H{"thread": thread, "comments": replies, "moreReplies": more})
+}
+
+// Fetches the top-level thread a post belongs to, writing a 404 if the post
+// is missing or the thread hidden. Deleted threads are left to the caller.
+func (h *Handler) fetchRootThread(c *gin.Context, threadID int) (*models.Thread, bool) {
+	rootID, err := h.Threads.FetchRootThreadID(threadID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return nil, false
+	} else if err != nil {
+		log.Printf("Error fetching thread: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thread"})
+		return nil, false
+	}
+	root, err := h.Threads.FetchThreadByID(rootID)
+	if err != nil {
+		log.Printf("Error fetching thread: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thread"})
+		return nil, false
+	}
+	if root == nil || root.Hidden {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return nil, false
+	}
+	return root, true
+}
+
+// Load the next page of replies below a thread or comment, each with the
+// first page of its own replies. Also follows "continue this thread" links.
+func (h *Handler) GetThreadReplies(c *gin.Context) {
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
+		return
+	}
+
+	// Replies under hidden comments and tombstones stay readable, as in the
+	// full thread; everything under a hidden thread is gone altogether
+	root, ok := h.fetchRootThread(c, threadID)
+	if !ok {
+		return
+	}
+
+	replies, more, ok := h.fetchCommentTree(c, threadID)
+	if !ok {
+		return
+	}
+	// A deleted thread stays up only while replies hang off it
+	if root.Deleted && len(replies) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"replies": replies, "moreReplies": more})
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"backend/events"
+	"backend/models"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// How often an idle event stream sends a keep-alive comment
+const eventHeartbeat = 25 * time.Second
+
+// Publishes an event on the stream of the thread that threadID belongs to
+func (h *Handler) publish(threadID int, kind string, data interface{}) {
+	rootID, err := h.Threads.FetchRootThreadID(threadID)
+	if err != nil {
+		log.Printf("Failed to resolve root of thread %d for %s event: %v", threadID, kind, err)
+		return
+	}
+	h.Events.Publish(events.Event{Type: kind, ThreadID: rootID, Data: data})
+}
+
+// Publishes a post's current vote counts
+func (h *Handler) publishVotes(thread *models.Thread) {
+	h.publish(thread.ID, events.VotesChanged, gin.H{
+		"id":            thread.ID,
+		"score":         thread.Score,
+		"likesCount":    thread.LikesCount,
+		"dislikesCount": thread.DislikesCount,
+	})
+}
+
+// Stream a thread's new comments, edits, deletions and vote changes as
+// Server-Sent Events
+func (h *Handler) StreamThreadEvents(c *gin.Context) {
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
+		return
+	}
+
+	root, ok := h.fetchRootThread(c, threadID)
+	if !ok {
+		return
+	}
+	if root.Deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+	rootID := root.ID
+
+	updates, unsubscribe := h.Events.Subscribe(rootID)
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Keep reverse proxies from buffering the stream
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	c.SSEvent("ready", gin.H{"threadId": rootID})
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-updates:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event.Data)
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"backend/models"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Retrieves the caller's personalized feed: threads by users they follow, in
+// categories and tags they subscribe to, and like the ones they liked or
+// saved, newest first. Threads they have already seen are left out.
+func (h *Handler) GetFeed(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	var cursor *models.ThreadCursor
+	if cursorToken := c.Query("cursor"); cursorToken != "" {
+		var err error
+		cursor, err = models.DecodeCursor(cursorToken)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
+
+	threads, next, err := h.Feed.FetchFeed(principal.UserID, limit, cursor)
+	if err == models.ErrCursorMismatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor was not issued by the feed"})
+		return
+	} else if err != nil {
+		log.Printf("Error fetching feed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch feed"})
+		return
+	}
+
+	// A missing cursor is returned as null
+	response := gin.H{"threads": threads, "nextCursor": nil}
+	if next != "" {
+		response["nextCursor"] = next
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"backend/events"
+	"backend/models"
+	"backend/moderation"
+	"backend/notifications"
+	"backend/ratelimit"
+)
+
+// Handler serves the HTTP API on top of the configured stores
+type Handler struct {
+	Threads       models.ThreadStore
+	Users         models.UserStore
+	Interactions  models.InteractionStore
+	Notifications models.NotificationStore
+	Mentions      models.MentionStore
+	Moderation    models.ModerationStore
+	Reports       models.ReportStore
+	Revisions     models.RevisionStore
+	ThreadStatus  models.ThreadStatusStore
+	Categories    models.CategoryStore
+	Tags          models.TagStore
+	Subscriptions models.SubscriptionStore
+	Feed          models.FeedStore
+	Notifier      *notifications.Service
+	Moderator     *moderation.Service
+	Events        events.Broker
+	Limiter       ratelimit.Limiter
+	RateLimits    map[string]ratelimit.Policy // Policy per route group; missing groups are not limited
+
+	RestrictTagCreation bool // Only admins may introduce new tags
+}
+
+// NewHandler wires every store to a single backend, such as
+// models.NewPostgresStore or models.NewMemoryStore. Thread events go through
+// an in-process hub and rate limits are counted in memory; replace Events and
+// Limiter to share them between instances.
+func NewHandler(store models.Store) *Handler {
+	return &Handler{
+		Threads:       store,
+		Users:         store,
+		Interactions:  store,
+		Notifications: store,
+		Mentions:      store,
+		Moderation:    store,
+		Reports:       store,
+		Revisions:     store,
+		ThreadStatus:  store,
+		Categories:    store,
+		Tags:          store,
+		Subscriptions: store,
+		Feed:          store,
+		Notifier:      notifications.NewService(store),
+		Moderator:     moderation.NewService(store),
+		Events:        events.NewHub(),
+		Limiter:       ratelimit.NewMemoryLimiter(),
+		RateLimits:    ratelimit.DefaultPolicies(),
+	}
+}
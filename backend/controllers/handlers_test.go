@@ -0,0 +1,1800 @@
+package controllers_test
+
+import (
+	"backend/controllers"
+	"backend/models"
+	"backend/ratelimit"
+	"backend/routes"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Setenv("JWT_SECRET_KEY", "test-secret")
+	os.Exit(m.Run())
+}
+
+// testServer runs the API on an in-memory store
+type testServer struct {
+	t       *testing.T
+	store   *models.MemoryStore
+	handler *controllers.Handler
+	router  *gin.Engine
+}
+
+func newTestServer(t *testing.T) *testServer {
+	return newTestServerWithLimits(t, nil)
+}
+
+// newTestServerWithLimits throttles routes under the given policies only;
+// nil turns rate limiting off
+func newTestServerWithLimits(t *testing.T, limits map[string]ratelimit.Policy) *testServer {
+	store := models.NewMemoryStore()
+	router := gin.New()
+	h := controllers.NewHandler(store)
+	h.RateLimits = limits
+	routes.RegisterRoutes(router, h)
+	return &testServer{t: t, store: store, handler: h, router: router}
+}
+
+// do sends a request and decodes the JSON response body into a map
+func (s *testServer) do(method, path, token string, body interface{}) (int, map[string]interface{}) {
+	s.t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(payload)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	var decoded map[string]interface{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &decoded)
+	return rec.Code, decoded
+}
+
+// signUp registers a user and returns their access and refresh tokens
+func (s *testServer) signUp(username string) (string, string) {
+	s.t.Helper()
+	credentials := gin.H{"username": username, "password": "password123"}
+	if code, body := s.do("POST", "/users", "", credentials); code != http.StatusCreated {
+		s.t.Fatalf("register %s: got %d %v", username, code, body)
+	}
+	code, body := s.do("POST", "/users/login", "", credentials)
+	if code != http.StatusOK {
+		s.t.Fatalf("login %s: got %d %v", username, code, body)
+	}
+	return body["token"].(string), body["refreshToken"].(string)
+}
+
+// createThread posts a thread as the token's owner and returns its ID
+func (s *testServer) createThread(token, title string) int {
+	s.t.Helper()
+	code, body := s.do("POST", "/threads", token, gin.H{
+		"title":    title,
+		"content":  "Some content that is long enough.",
+		"category": "Community",
+		"tag":      "Rules",
+	})
+	if code != http.StatusCreated {
+		s.t.Fatalf("create thread: got %d %v", code, body)
+	}
+	code, body = s.do("GET", "/threads", "", nil)
+	if code != http.StatusOK {
+		s.t.Fatalf("list threads: got %d %v", code, body)
+	}
+	for _, item := range body["threads"].([]interface{}) {
+		thread := item.(map[string]interface{})
+		if thread["title"] == title {
+			return int(thread["id"].(float64))
+		}
+	}
+	s.t.Fatalf("thread %q not listed", title)
+	return 0
+}
+
+func TestRegisterRejectsDuplicateUsername(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("alice")
+
+	code, _ := s.do("POST", "/users", "", gin.H{"username": "alice", "password": "other"})
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for duplicate username, got %d", code)
+	}
+}
+
+func TestLoginRejectsWrongPassword(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("alice")
+
+	code, _ := s.do("POST", "/users/login", "", gin.H{"username": "alice", "password": "nope"})
+	if code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", code)
+	}
+	code, _ = s.do("POST", "/users/login", "", gin.H{"username": "nobody", "password": "nope"})
+	if code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for unknown user, got %d", code)
+	}
+}
+
+func TestCreateThreadRequiresToken(t *testing.T) {
+	s := newTestServer(t)
+
+	code, _ := s.do("POST", "/threads", "", gin.H{"title": "Hello world", "content": "Long enough content"})
+	if code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a token, got %d", code)
+	}
+}
+
+func TestCreateThreadAndFetchDetails(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.signUp("alice")
+	id := s.createThread(token, "First thread")
+
+	code, body := s.do("GET", "/threads/"+strconv.Itoa(id), "", nil)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %v", code, body)
+	}
+	thread := body["thread"].(map[string]interface{})
+	if thread["author"] != "alice" || thread["category"] != "Community" {
+		t.Fatalf("unexpected thread %v", thread)
+	}
+
+	// Duplicate titles fail validation
+	code, _ = s.do("POST", "/threads", token, gin.H{"title": "First thread", "content": "Some other content here"})
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a duplicate title, got %d", code)
+	}
+}
+
+func TestCannotActAsAnotherUser(t *testing.T) {
+	s := newTestServer(t)
+	aliceToken, _ := s.signUp("alice")
+	s.signUp("bob")
+
+	code, _ := s.do("POST", "/threads", aliceToken, gin.H{
+		"username": "bob",
+		"title":    "Impersonation",
+		"content":  "Posting on behalf of bob",
+	})
+	if code != http.StatusForbidden {
+		t.Fatalf("expected 403 when claiming another username, got %d", code)
+	}
+
+	code, _ = s.do("PUT", "/users/bob/bio", aliceToken, gin.H{"bio": "hacked"})
+	if code != http.StatusForbidden {
+		t.Fatalf("expected 403 editing another user's bio, got %d", code)
+	}
+}
+
+func TestOnlyOwnerCanEditOrDelete(t *testing.T) {
+	s := newTestServer(t)
+	aliceToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	id := s.createThread(aliceToken, "Alice's thread")
+
+	code, _ := s.do("PUT", "/threads/"+strconv.Itoa(id), bobToken, gin.H{"content": "Rewritten by bob"})
+	if code != http.StatusForbidden {
+		t.Fatalf("expected 403 for bob editing, got %d", code)
+	}
+	code, _ = s.do("DELETE", "/threads/"+strconv.Itoa(id), bobToken, nil)
+	if code != http.StatusForbidden {
+		t.Fatalf("expected 403 for bob deleting, got %d", code)
+	}
+	code, _ = s.do("DELETE", "/threads/"+strconv.Itoa(id), aliceToken, nil)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 for alice deleting, got %d", code)
+	}
+	code, _ = s.do("GET", "/threads/"+strconv.Itoa(id), "", nil)
+	if code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", code)
+	}
+}
+
+func TestLikeAndDislikeCounts(t *testing.T) {
+	s := newTestServer(t)
+	aliceToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	id := s.createThread(aliceToken, "Likeable thread")
+	base := "/threads/" + strconv.Itoa(id)
+
+	s.do("POST", base+"/like", aliceToken, nil)
+	s.do("POST", base+"/like", bobToken, nil)
+	s.do("POST", base+"/like", bobToken, nil) // Repeated likes count once
+
+	_, body := s.do("GET", base+"/likes", "", nil)
+	if body["likes_count"] != float64(2) {
+		t.Fatalf("expected 2 likes, got %v", body)
+	}
+
+	// Switching to a dislike replaces the like
+	s.do("POST", base+"/dislike", bobToken, nil)
+	_, body = s.do("GET", base+"/likes", "", nil)
+	if body["likes_count"] != float64(1) {
+		t.Fatalf("expected 1 like after switching, got %v", body)
+	}
+	_, body = s.do("GET", base+"/likestate?username=bob", "", nil)
+	if body["liked"] != false || body["disliked"] != true {
+		t.Fatalf("unexpected like state %v", body)
+	}
+
+	code, _ := s.do("POST", "/threads/9999/like", bobToken, nil)
+	if code != http.StatusNotFound {
+		t.Fatalf("expected 404 liking a missing thread, got %d", code)
+	}
+}
+
+func TestCommentDepthLimit(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.signUp("alice")
+	parent := s.createThread(token, "Deep thread")
+
+	for depth := 1; depth <= models.MaxCommentDepth+1; depth++ {
+		code, _ := s.do("POST", "/threads/"+strconv.Itoa(parent)+"/comment", token, gin.H{"content": "A nested reply"})
+		if depth > models.MaxCommentDepth {
+			if code != http.StatusBadRequest {
+				t.Fatalf("expected 400 past the maximum depth, got %d", code)
+			}
+			break
+		}
+		if code != http.StatusCreated {
+			t.Fatalf("comment at depth %d: got %d", depth, code)
+		}
+		_, body := s.do("GET", "/threads/"+strconv.Itoa(parent), "", nil)
+		comments := body["comments"].([]interface{})
+		parent = int(comments[0].(map[string]interface{})["id"].(float64))
+	}
+}
+
+func TestPromoteRequiresAdmin(t *testing.T) {
+	s := newTestServer(t)
+	aliceToken, _ := s.signUp("alice")
+	s.signUp("bob")
+
+	code, _ := s.do("PUT", "/users/bob/promote", aliceToken, nil)
+	if code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin, got %d", code)
+	}
+
+	if err := s.store.PromoteUser("alice"); err != nil {
+		t.Fatal(err)
+	}
+	code, _ = s.do("PUT", "/users/bob/promote", aliceToken, nil)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 for an admin, got %d", code)
+	}
+	_, body := s.do("GET", "/users/bob/info", "", nil)
+	if body["role"] != "Admin" {
+		t.Fatalf("expected bob to be an admin, got %v", body)
+	}
+}
+
+func TestRefreshRotationAndReuse(t *testing.T) {
+	s := newTestServer(t)
+	token, refresh := s.signUp("alice")
+
+	code, body := s.do("POST", "/users/refresh", "", gin.H{"refreshToken": refresh})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 refreshing, got %d %v", code, body)
+	}
+	rotated := body["refreshToken"].(string)
+	refreshed := body["token"].(string)
+
+	// Replaying the old token revokes the whole family
+	code, _ = s.do("POST", "/users/refresh", "", gin.H{"refreshToken": refresh})
+	if code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 on reuse, got %d", code)
+	}
+	code, _ = s.do("POST", "/users/refresh", "", gin.H{"refreshToken": rotated})
+	if code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for the rotated token after reuse, got %d", code)
+	}
+
+	// Access tokens issued within the same second go too
+	for _, accessToken := range []string{token, refreshed} {
+		code, _ = s.do("POST", "/threads", accessToken, gin.H{"title": "After reuse", "content": "Should be rejected"})
+		if code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 with an access token from before the reuse, got %d", code)
+		}
+	}
+}
+
+func TestPasswordChangeRevokesSessions(t *testing.T) {
+	s := newTestServer(t)
+	token, refresh := s.signUp("alice")
+
+	code, body := s.do("POST", "/users/alice/password", token, gin.H{"currentPassword": "password123", "newPassword": "password456"})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 changing the password, got %d %v", code, body)
+	}
+	code, _ = s.do("POST", "/threads", token, gin.H{"title": "Old session", "content": "Should be rejected"})
+	if code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with a token from before the change, got %d", code)
+	}
+
+	// The caller stays signed in with the tokens issued by the change
+	code, body = s.do("POST", "/threads", body["token"].(string), gin.H{
+		"title":    "New session",
+		"content":  "Some content that is long enough.",
+		"category": "Community",
+		"tag":      "Rules",
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201 with the new token, got %d %v", code, body)
+	}
+	code, _ = s.do("POST", "/users/refresh", "", gin.H{"refreshToken": refresh})
+	if code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 refreshing after the change, got %d", code)
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	s := newTestServer(t)
+	token, refresh := s.signUp("alice")
+
+	code, _ := s.do("POST", "/users/logout", token, gin.H{"refreshToken": refresh})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 logging out, got %d", code)
+	}
+	code, _ = s.do("POST", "/threads", token, gin.H{"title": "After logout", "content": "Should be rejected"})
+	if code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with a revoked token, got %d", code)
+	}
+	code, _ = s.do("POST", "/users/refresh", "", gin.H{"refreshToken": refresh})
+	if code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 refreshing after logout, got %d", code)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.signUp("alice")
+	s.createThread(token, "Gardening tips")
+	s.createThread(token, "Cooking tips")
+
+	code, body := s.do("GET", "/search?q=garden*&type=threads", "", nil)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %v", code, body)
+	}
+	if results := body["threads"].([]interface{}); len(results) != 1 {
+		t.Fatalf("expected 1 result, got %v", results)
+	}
+
+	code, _ = s.do("GET", "/search?q=***", "", nil)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an empty query, got %d", code)
+	}
+
+	// Comments go with the thread they were posted in, at any depth
+	comment := func(parentID int, content string) int {
+		_, body := s.do("POST", "/threads/"+strconv.Itoa(parentID)+"/comment", token, gin.H{"content": content})
+		return int(body["id"].(float64))
+	}
+	hidden := s.createThread(token, "Thread to be hidden")
+	comment(comment(hidden, "Compost first"), "Compost nested")
+	deleted := s.createThread(token, "Thread to be deleted")
+	comment(deleted, "Compost under a deleted thread")
+	comments := func() []interface{} {
+		_, body := s.do("GET", "/search?q=compost&type=comments", "", nil)
+		return body["comments"].([]interface{})
+	}
+	if results := comments(); len(results) != 3 {
+		t.Fatalf("expected 3 comments, got %v", results)
+	}
+	bobToken, _ := s.signUp("bob")
+	s.store.PromoteUser("bob")
+	s.do("POST", "/threads/"+strconv.Itoa(hidden)+"/report", bobToken, gin.H{"reason": "spam"})
+	s.do("POST", "/moderation/reports/"+strconv.Itoa(hidden)+"/resolve", bobToken, gin.H{"action": "hide"})
+	s.do("DELETE", "/threads/"+strconv.Itoa(deleted), token, nil)
+	if results := comments(); len(results) != 0 {
+		t.Fatalf("expected comments under hidden and deleted threads left out, got %v", results)
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.signUp("alice")
+	for _, title := range []string{"Thread one", "Thread two", "Thread three"} {
+		s.createThread(token, title)
+	}
+
+	_, first := s.do("GET", "/threads?paginate=cursor&limit=2", "", nil)
+	if len(first["threads"].([]interface{})) != 2 || first["prevCursor"] != nil {
+		t.Fatalf("unexpected first page %v", first)
+	}
+	next := first["nextCursor"].(string)
+
+	_, second := s.do("GET", "/threads?limit=2&cursor="+next, "", nil)
+	threads := second["threads"].([]interface{})
+	if len(threads) != 1 || threads[0].(map[string]interface{})["title"] != "Thread one" {
+		t.Fatalf("unexpected second page %v", second)
+	}
+	if second["nextCursor"] != nil {
+		t.Fatalf("expected no next cursor on the last page, got %v", second["nextCursor"])
+	}
+
+	_, back := s.do("GET", "/threads?limit=2&cursor="+second["prevCursor"].(string), "", nil)
+	if len(back["threads"].([]interface{})) != 2 || back["prevCursor"] != nil {
+		t.Fatalf("unexpected page going back %v", back)
+	}
+
+	code, _ := s.do("GET", "/threads?limit=2&sortBy=likes&cursor="+next, "", nil)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a cursor from another sort, got %d", code)
+	}
+}
+
+func TestVoteEndpoint(t *testing.T) {
+	s := newTestServer(t)
+	aliceToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	id := s.createThread(aliceToken, "Votable thread")
+	path := "/threads/" + strconv.Itoa(id) + "/vote"
+
+	s.do("PUT", path, aliceToken, gin.H{"value": 1})
+	code, body := s.do("PUT", path, bobToken, gin.H{"value": -1})
+	if code != http.StatusOK || body["score"] != float64(0) || body["dislikesCount"] != float64(1) {
+		t.Fatalf("unexpected vote response %d %v", code, body)
+	}
+
+	// Flipping a vote moves it between counters
+	_, body = s.do("PUT", path, bobToken, gin.H{"value": 1})
+	if body["score"] != float64(2) || body["likesCount"] != float64(2) || body["dislikesCount"] != float64(0) {
+		t.Fatalf("unexpected score after flipping %v", body)
+	}
+
+	// Zero clears the vote
+	_, body = s.do("PUT", path, bobToken, gin.H{"value": 0})
+	if body["score"] != float64(1) {
+		t.Fatalf("unexpected score after clearing %v", body)
+	}
+
+	code, _ = s.do("PUT", path, bobToken, gin.H{"value": 2})
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an out-of-range vote, got %d", code)
+	}
+	code, _ = s.do("PUT", path, bobToken, gin.H{})
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a missing vote, got %d", code)
+	}
+
+	_, body = s.do("GET", "/users/alice/votes", aliceToken, nil)
+	votes := body["votes"].([]interface{})
+	if len(votes) != 1 || votes[0].(map[string]interface{})["value"] != float64(1) {
+		t.Fatalf("unexpected vote history %v", body)
+	}
+	code, _ = s.do("GET", "/users/alice/votes", bobToken, nil)
+	if code != http.StatusForbidden {
+		t.Fatalf("expected 403 reading another user's votes, got %d", code)
+	}
+
+	// Deleted and hidden posts take no votes
+	_, body = s.do("POST", "/threads/"+strconv.Itoa(id)+"/comment", aliceToken, gin.H{"content": "Deleted later"})
+	deleted := "/threads/" + strconv.Itoa(int(body["id"].(float64)))
+	s.do("POST", deleted+"/comment", bobToken, gin.H{"content": "Keeps the tombstone"})
+	s.do("DELETE", deleted, aliceToken, nil)
+	hidden := "/threads/" + strconv.Itoa(s.createThread(aliceToken, "Hidden thread"))
+	s.store.PromoteUser("bob")
+	s.do("POST", hidden+"/report", bobToken, gin.H{"reason": "spam"})
+	s.do("POST", "/moderation/reports/"+strings.TrimPrefix(hidden, "/threads/")+"/resolve", bobToken, gin.H{"action": "hide"})
+	for _, post := range []string{deleted, hidden} {
+		if code, _ := s.do("PUT", post+"/vote", bobToken, gin.H{"value": 1}); code != http.StatusNotFound {
+			t.Fatalf("expected 404 voting on %s, got %d", post, code)
+		}
+		for _, method := range []string{"POST", "DELETE"} {
+			for _, action := range []string{"/like", "/dislike"} {
+				if code, _ := s.do(method, post+action, bobToken, nil); code != http.StatusNotFound {
+					t.Fatalf("expected 404 for %s %s%s, got %d", method, post, action, code)
+				}
+			}
+		}
+	}
+}
+
+func TestRankedSorts(t *testing.T) {
+	s := newTestServer(t)
+	aliceToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	carolToken, _ := s.signUp("carol")
+	loved := s.createThread(aliceToken, "Loved thread")
+	divisive := s.createThread(aliceToken, "Divisive thread")
+	s.createThread(aliceToken, "Quiet thread")
+
+	vote := func(token string, id, value int) {
+		s.do("PUT", "/threads/"+strconv.Itoa(id)+"/vote", token, gin.H{"value": value})
+	}
+	vote(aliceToken, loved, 1)
+	vote(bobToken, loved, 1)
+	vote(carolToken, loved, 1)
+	vote(aliceToken, divisive, 1)
+	vote(bobToken, divisive, -1)
+
+	titles := func(query string) []string {
+		code, body := s.do("GET", "/threads?"+query, "", nil)
+		if code != http.StatusOK {
+			t.Fatalf("GET /threads?%s: got %d %v", query, code, body)
+		}
+		var result []string
+		for _, item := range body["threads"].([]interface{}) {
+			result = append(result, item.(map[string]interface{})["title"].(string))
+		}
+		return result
+	}
+
+	if got := titles("sortBy=top&window=week"); got[0] != "Loved thread" || got[2] != "Divisive thread" {
+		t.Fatalf("unexpected top order %v", got)
+	}
+	if got := titles("sortBy=top&order=asc"); got[0] != "Divisive thread" {
+		t.Fatalf("unexpected ascending top order %v", got)
+	}
+	if got := titles("sortBy=controversial"); got[0] != "Divisive thread" {
+		t.Fatalf("unexpected controversial order %v", got)
+	}
+	if got := titles("sortBy=hot"); got[0] != "Loved thread" {
+		t.Fatalf("unexpected hot order %v", got)
+	}
+	if got := titles("sortBy=rising"); got[0] != "Loved thread" || got[2] != "Quiet thread" {
+		t.Fatalf("unexpected rising order %v", got)
+	}
+
+	code, _ := s.do("GET", "/threads?sortBy=top&window=year", "", nil)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown window, got %d", code)
+	}
+	code, _ = s.do("GET", "/threads?order=sideways", "", nil)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown order, got %d", code)
+	}
+	code, _ = s.do("GET", "/threads?sortBy=hot&paginate=cursor", "", nil)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for cursor pagination by hot, got %d", code)
+	}
+
+	// Cursors remember the window and order they were issued for
+	_, body := s.do("GET", "/threads?sortBy=top&order=asc&paginate=cursor&limit=2", "", nil)
+	next := body["nextCursor"].(string)
+	_, body = s.do("GET", "/threads?sortBy=top&order=asc&limit=2&cursor="+next, "", nil)
+	if threads := body["threads"].([]interface{}); len(threads) != 1 || threads[0].(map[string]interface{})["title"] != "Loved thread" {
+		t.Fatalf("unexpected second ascending page %v", body)
+	}
+	code, _ = s.do("GET", "/threads?sortBy=top&limit=2&cursor="+next, "", nil)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 reusing a cursor with another order, got %d", code)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	s := newTestServer(t)
+	aliceToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	id := s.createThread(aliceToken, "Notify me")
+	path := "/threads/" + strconv.Itoa(id)
+
+	// A reply that also mentions alice, a self-reply, and a first like
+	s.do("POST", path+"/comment", bobToken, gin.H{"content": "Nice one @alice and @nobody"})
+	s.do("POST", path+"/comment", aliceToken, gin.H{"content": "Thanks everyone"})
+	s.do("PUT", path+"/vote", bobToken, gin.H{"value": 1})
+	s.do("PUT", path+"/vote", bobToken, gin.H{"value": 0})
+	s.do("PUT", path+"/vote", bobToken, gin.H{"value": 1}) // Milestones are announced once
+
+	_, body := s.do("GET", "/notifications", aliceToken, nil)
+	notifications := body["notifications"].([]interface{})
+	if len(notifications) != 3 || body["unreadCount"] != float64(3) {
+		t.Fatalf("expected 3 unread notifications, got %v", body)
+	}
+	types := map[string]bool{}
+	for _, item := range notifications {
+		types[item.(map[string]interface{})["type"].(string)] = true
+	}
+	if !types["reply"] || !types["mention"] || !types["like_milestone"] {
+		t.Fatalf("unexpected notification types %v", types)
+	}
+
+	// Only the recipient can mark a notification read
+	first := strconv.Itoa(int(notifications[0].(map[string]interface{})["id"].(float64)))
+	code, _ := s.do("PUT", "/notifications/"+first+"/read", bobToken, nil)
+	if code != http.StatusNotFound {
+		t.Fatalf("expected 404 marking someone else's notification, got %d", code)
+	}
+	s.do("PUT", "/notifications/"+first+"/read", aliceToken, nil)
+	_, body = s.do("GET", "/notifications/unread-count", aliceToken, nil)
+	if body["unreadCount"] != float64(2) {
+		t.Fatalf("expected 2 unread, got %v", body)
+	}
+	s.do("PUT", "/notifications/read", aliceToken, nil)
+	_, body = s.do("GET", "/notifications?unread=true", aliceToken, nil)
+	if len(body["notifications"].([]interface{})) != 0 {
+		t.Fatalf("expected no unread notifications, got %v", body)
+	}
+
+	// Disabled types are not delivered
+	code, body = s.do("PUT", "/notifications/preferences", aliceToken, gin.H{"reply": false})
+	if code != http.StatusOK || body["preferences"].(map[string]interface{})["reply"] != false {
+		t.Fatalf("unexpected preferences response %d %v", code, body)
+	}
+	s.do("POST", path+"/comment", bobToken, gin.H{"content": "Another reply"})
+	_, body = s.do("GET", "/notifications/unread-count", aliceToken, nil)
+	if body["unreadCount"] != float64(0) {
+		t.Fatalf("expected the muted reply to be dropped, got %v", body)
+	}
+	code, _ = s.do("PUT", "/notifications/preferences", aliceToken, gin.H{"pokes": true})
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown type, got %d", code)
+	}
+
+	// Promotions name the acting admin
+	s.store.PromoteUser("alice")
+	s.do("PUT", "/users/bob/promote", aliceToken, nil)
+	_, body = s.do("GET", "/notifications", bobToken, nil)
+	promotion := body["notifications"].([]interface{})[0].(map[string]interface{})
+	if promotion["type"] != "promotion" || promotion["actor"] != "alice" {
+		t.Fatalf("unexpected promotion notification %v", promotion)
+	}
+}
+
+func TestThreadEventStream(t *testing.T) {
+	s := newTestServer(t)
+	aliceToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	id := s.createThread(aliceToken, "Live thread")
+	path := "/threads/" + strconv.Itoa(id)
+
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+path+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	nextEvent := func() string {
+		t.Helper()
+		for lines.Scan() {
+			if name, ok := strings.CutPrefix(lines.Text(), "event:"); ok {
+				return name
+			}
+		}
+		t.Fatalf("stream ended early: %v", lines.Err())
+		return ""
+	}
+	if name := nextEvent(); name != "ready" {
+		t.Fatalf("expected ready event, got %q", name)
+	}
+
+	// Replies at any depth reach the root thread's stream
+	_, body := s.do("POST", path+"/comment", bobToken, gin.H{"content": "First!"})
+	commentPath := "/threads/" + strconv.Itoa(int(body["id"].(float64)))
+	s.do("POST", commentPath+"/comment", aliceToken, gin.H{"content": "Welcome"})
+	s.do("PUT", commentPath+"/vote", aliceToken, gin.H{"value": 1})
+	s.do("PUT", commentPath, bobToken, gin.H{"content": "First! (edited)"})
+	s.do("DELETE", commentPath, bobToken, nil)
+
+	for _, want := range []string{"comment", "comment", "vote", "edit", "delete"} {
+		if name := nextEvent(); name != want {
+			t.Fatalf("expected %s event, got %q", want, name)
+		}
+	}
+
+	// Changes to a hidden post go out with its content withheld
+	_, body = s.do("POST", path+"/comment", bobToken, gin.H{"content": "Secret draft"})
+	secretPath := "/threads/" + strconv.Itoa(int(body["id"].(float64)))
+	s.do("PUT", secretPath, bobToken, gin.H{"content": "Secret final"})
+	_, body = s.do("GET", secretPath+"/revisions", "", nil)
+	revisionID := int(body["revisions"].([]interface{})[0].(map[string]interface{})["id"].(float64))
+	s.store.PromoteUser("alice")
+	s.do("POST", secretPath+"/report", aliceToken, gin.H{"reason": "spam"})
+	s.do("POST", "/moderation/reports/"+strings.TrimPrefix(secretPath, "/threads/")+"/resolve", aliceToken, gin.H{"action": "hide"})
+	s.do("PUT", secretPath, bobToken, gin.H{"content": "Secret edit"})
+	s.do("POST", secretPath+"/revisions/"+strconv.Itoa(revisionID)+"/rollback", aliceToken, nil)
+	for i, want := range []string{"comment", "edit", "edit", "edit"} {
+		if name := nextEvent(); name != want {
+			t.Fatalf("expected %s event, got %q", want, name)
+		}
+		if lines.Scan(); i >= 2 && strings.Contains(lines.Text(), "Secret") {
+			t.Fatalf("expected hidden content withheld, got %s", lines.Text())
+		}
+	}
+
+	code, _ := s.do("GET", "/threads/9999/events", "", nil)
+	if code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing thread, got %d", code)
+	}
+
+	// Hidden and deleted threads have nothing to stream
+	hidden := "/threads/" + strconv.Itoa(s.createThread(aliceToken, "Hidden live thread"))
+	s.do("POST", hidden+"/report", bobToken, gin.H{"reason": "spam"})
+	s.do("POST", "/moderation/reports/"+strings.TrimPrefix(hidden, "/threads/")+"/resolve", aliceToken, gin.H{"action": "hide"})
+	deleted := "/threads/" + strconv.Itoa(s.createThread(aliceToken, "Deleted live thread"))
+	s.do("POST", deleted+"/comment", bobToken, gin.H{"content": "Keeps the tombstone"})
+	s.do("DELETE", deleted, aliceToken, nil)
+	for _, removed := range []string{hidden, deleted} {
+		if code, _ := s.do("GET", removed+"/events", "", nil); code != http.StatusNotFound {
+			t.Fatalf("expected 404 streaming %s, got %d", removed, code)
+		}
+	}
+}
+
+func TestMentionsAndAutocomplete(t *testing.T) {
+	s := newTestServer(t)
+	aliceToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	bobbyToken, _ := s.signUp("Bobby")
+	id := s.createThread(aliceToken, "Mentions")
+	path := "/threads/" + strconv.Itoa(id)
+
+	_, body := s.do("POST", path+"/comment", aliceToken, gin.H{"content": "Hi @bob, meet @nobody (mail@bob.com)"})
+	commentPath := "/threads/" + strconv.Itoa(int(body["id"].(float64)))
+
+	// Only existing users become mention tokens
+	_, body = s.do("GET", path, "", nil)
+	comment := body["comments"].([]interface{})[0].(map[string]interface{})
+	tokens, _ := json.Marshal(comment["tokens"])
+	want := `[{"text":"Hi ","type":"text"},{"text":"@bob","type":"mention","username":"bob"},{"text":", meet @nobody (mail@bob.com)","type":"text"}]`
+	if string(tokens) != want {
+		t.Fatalf("unexpected tokens %s", tokens)
+	}
+
+	// Edits notify only users who were not mentioned before
+	s.do("PUT", commentPath, aliceToken, gin.H{"content": "Hi @bob and @Bobby"})
+	_, body = s.do("GET", "/notifications/unread-count", bobToken, nil)
+	if body["unreadCount"] != float64(1) {
+		t.Fatalf("expected bob to be notified once, got %v", body)
+	}
+	_, body = s.do("GET", "/notifications/unread-count", bobbyToken, nil)
+	if body["unreadCount"] != float64(1) {
+		t.Fatalf("expected Bobby to be notified after the edit, got %v", body)
+	}
+
+	code, body := s.do("GET", "/users/search?prefix=BO", "", nil)
+	if users := body["users"].([]interface{}); code != http.StatusOK || len(users) != 2 || users[0] != "bob" || users[1] != "Bobby" {
+		t.Fatalf("unexpected suggestions %d %v", code, body)
+	}
+	code, _ = s.do("GET", "/users/search", "", nil)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a prefix, got %d", code)
+	}
+}
+
+func TestMarkdownRendering(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.signUp("alice")
+
+	code, body := s.do("POST", "/threads", token, gin.H{
+		"title":    "Formatting",
+		"content":  "**Bold** <script>alert(1)</script>\n\n> quoted [site](https://example.com) [bad](javascript:alert(1))",
+		"category": "Community",
+		"tag":      "Rules",
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("create thread: got %d %v", code, body)
+	}
+	path := "/threads/" + strconv.Itoa(int(body["id"].(float64)))
+	s.do("POST", path+"/comment", token, gin.H{"content": "- one\n- `two`\n\n```go\nx := \"<b>\"\n```"})
+
+	_, body = s.do("GET", path, "", nil)
+	thread := body["thread"].(map[string]interface{})
+	wantThread := "<p><strong>Bold</strong> &lt;script&gt;alert(1)&lt;/script&gt;</p>\n" +
+		`<blockquote>` + "\n" + `<p>quoted <a href="https://example.com" rel="nofollow noopener noreferrer">site</a> [bad](javascript:alert(1))</p>` + "\n</blockquote>"
+	if thread["contentHtml"] != wantThread || !strings.HasPrefix(thread["content"].(string), "**Bold**") {
+		t.Fatalf("unexpected thread rendering %q", thread["contentHtml"])
+	}
+	comment := body["comments"].([]interface{})[0].(map[string]interface{})
+	wantComment := "<ul>\n<li>one</li>\n<li><code>two</code></li>\n</ul>\n" +
+		`<pre><code class="language-go">x := &#34;&lt;b&gt;&#34;` + "\n</code></pre>"
+	if comment["contentHtml"] != wantComment {
+		t.Fatalf("unexpected comment rendering %q", comment["contentHtml"])
+	}
+
+	// Limits count characters of the source, not bytes or markup
+	code, _ = s.do("POST", path+"/comment", token, gin.H{"content": strings.Repeat("é", 500)})
+	if code != http.StatusCreated {
+		t.Fatalf("expected 500 characters to be accepted, got %d", code)
+	}
+	code, _ = s.do("POST", path+"/comment", token, gin.H{"content": strings.Repeat("*", 501)})
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 501 characters to be rejected, got %d", code)
+	}
+}
+
+func TestModerationRules(t *testing.T) {
+	s := newTestServer(t)
+	adminToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	s.store.PromoteUser("alice")
+
+	code, _ := s.do("POST", "/moderation/rules", bobToken, gin.H{"pattern": "x", "kind": "word", "action": "reject"})
+	if code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin, got %d", code)
+	}
+	for _, rule := range []gin.H{
+		{"pattern": "mother", "kind": "substring", "action": "reject"},
+		{"pattern": "darn", "kind": "word", "action": "mask"},
+		{"pattern": "sp[a4]m+", "kind": "regex", "action": "flag"},
+	} {
+		if code, body := s.do("POST", "/moderation/rules", adminToken, rule); code != http.StatusCreated {
+			t.Fatalf("create rule %v: got %d %v", rule, code, body)
+		}
+	}
+	for _, rule := range []gin.H{
+		{"pattern": "(", "kind": "regex", "action": "flag"},
+		{"pattern": "x", "kind": "fuzzy", "action": "flag"},
+		{"pattern": "x", "kind": "word", "action": "ban"},
+	} {
+		if code, _ := s.do("POST", "/moderation/rules", adminToken, rule); code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %v, got %d", rule, code)
+		}
+	}
+
+	// Full-width letters, lookalike scripts and invisible characters are folded
+	id := s.createThread(adminToken, "Moderated")
+	path := "/threads/" + strconv.Itoa(id)
+	for _, content := range []string{"Ｍｏｔｈｅｒ board", "My mо​therboard"} {
+		code, body := s.do("POST", path+"/comment", bobToken, gin.H{"content": content})
+		if code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected %q to be rejected, got %d %v", content, code, body)
+		}
+	}
+	code, _ = s.do("POST", "/threads", bobToken, gin.H{"title": "About my MOTHER", "content": "Long enough content", "category": "Community", "tag": "Rules"})
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected rules to apply to titles, got %d", code)
+	}
+	code, _ = s.do("PUT", "/users/bob/bio", bobToken, gin.H{"bio": "I love my mother"})
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected rules to apply to bios, got %d", code)
+	}
+
+	// Masks replace whole words only; flags keep the post and queue it
+	s.do("POST", path+"/comment", bobToken, gin.H{"content": "Darn it, darned thing"})
+	_, body := s.do("POST", path+"/comment", bobToken, gin.H{"content": "Buy SP4MMM now"})
+	flaggedID := int(body["id"].(float64))
+	_, body = s.do("GET", path+"?sortBy=created_at", "", nil)
+	contents := map[string]bool{}
+	for _, item := range body["comments"].([]interface{}) {
+		contents[item.(map[string]interface{})["content"].(string)] = true
+	}
+	if !contents["**** it, darned thing"] || !contents["Buy SP4MMM now"] {
+		t.Fatalf("unexpected stored comments %v", contents)
+	}
+
+	// Titles are checked for uniqueness as they read once masked
+	s.createThread(adminToken, "Well **** it")
+	code, body = s.do("POST", "/threads", bobToken, gin.H{"title": "Well darn it", "content": "Long enough content", "category": "Community", "tag": "Rules"})
+	if code != http.StatusUnprocessableEntity || fmt.Sprint(body["errors"]) != "[Title must be unique]" {
+		t.Fatalf("expected a masked duplicate title to be refused, got %d %v", code, body)
+	}
+	bobThread := s.createThread(bobToken, "Bob's own title")
+	code, body = s.do("PUT", "/threads/"+strconv.Itoa(bobThread), bobToken, gin.H{"title": "Well darn it", "content": "Long enough content"})
+	if code != http.StatusBadRequest || fmt.Sprint(body["errors"]) != "[Title must be unique]" {
+		t.Fatalf("expected an edit to a masked duplicate title to be refused, got %d %v", code, body)
+	}
+
+	_, body = s.do("GET", "/moderation/flags", adminToken, nil)
+	flags := body["flags"].([]interface{})
+	if len(flags) != 1 || flags[0].(map[string]interface{})["threadId"] != float64(flaggedID) {
+		t.Fatalf("unexpected flags %v", body)
+	}
+	flagPath := "/moderation/flags/" + strconv.Itoa(flaggedID)
+	if code, _ := s.do("DELETE", flagPath, adminToken, nil); code != http.StatusOK {
+		t.Fatalf("expected flags to clear, got %d", code)
+	}
+	if code, _ := s.do("DELETE", flagPath, adminToken, nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 once cleared, got %d", code)
+	}
+
+	// Usernames are refused rather than masked
+	for _, username := range []string{"mother_1", "Darn"} {
+		code, body := s.do("POST", "/users", "", gin.H{"username": username, "password": "password123"})
+		if code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected username %q to be refused, got %d %v", username, code, body)
+		}
+	}
+
+	// Rollbacks are held to the rules in force now
+	bobPath := "/threads/" + strconv.Itoa(bobThread)
+	s.do("PUT", bobPath, bobToken, gin.H{"content": "Rewritten by bob"})
+	s.do("POST", "/moderation/rules", adminToken, gin.H{"pattern": "enough", "kind": "word", "action": "reject"})
+	_, body = s.do("GET", bobPath+"/revisions", "", nil)
+	revisionID := int(body["revisions"].([]interface{})[0].(map[string]interface{})["id"].(float64))
+	code, body = s.do("POST", bobPath+"/revisions/"+strconv.Itoa(revisionID)+"/rollback", adminToken, nil)
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected a rollback to rejected text to be refused, got %d %v", code, body)
+	}
+}
+
+func TestReportsAndModerationQueue(t *testing.T) {
+	s := newTestServer(t)
+	adminToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	carolToken, _ := s.signUp("carol")
+	s.store.PromoteUser("alice")
+
+	id := s.createThread(bobToken, "Reported thread")
+	path := "/threads/" + strconv.Itoa(id)
+	_, body := s.do("POST", path+"/comment", bobToken, gin.H{"content": "Reported reply"})
+	commentID := int(body["id"].(float64))
+	commentPath := "/threads/" + strconv.Itoa(commentID)
+
+	for _, tc := range []struct {
+		token string
+		body  gin.H
+		want  int
+	}{
+		{carolToken, gin.H{"reason": "spam"}, http.StatusCreated},
+		{carolToken, gin.H{"reason": "hate"}, http.StatusConflict},
+		{adminToken, gin.H{"reason": "other", "details": "  "}, http.StatusBadRequest},
+		{adminToken, gin.H{"reason": "rude"}, http.StatusBadRequest},
+		{adminToken, gin.H{"reason": "spam"}, http.StatusCreated},
+		{bobToken, gin.H{"reason": "spam"}, http.StatusBadRequest},
+	} {
+		if code, body := s.do("POST", commentPath+"/report", tc.token, tc.body); code != tc.want {
+			t.Fatalf("report %v: expected %d, got %d %v", tc.body, tc.want, code, body)
+		}
+	}
+	s.do("POST", path+"/report", carolToken, gin.H{"reason": "off_topic"})
+
+	// Reports are grouped per post, most reported first
+	code, _ := s.do("GET", "/moderation/reports", carolToken, nil)
+	if code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin, got %d", code)
+	}
+	_, body = s.do("GET", "/moderation/reports", adminToken, nil)
+	items := body["items"].([]interface{})
+	first := items[0].(map[string]interface{})
+	if len(items) != 2 || first["threadId"] != float64(commentID) || first["reportCount"] != float64(2) ||
+		first["reasons"].(map[string]interface{})["spam"] != float64(2) {
+		t.Fatalf("unexpected queue %v", body)
+	}
+
+	// Hiding redacts the comment; warning notifies the author
+	_, body = s.do("POST", "/moderation/reports/"+strconv.Itoa(commentID)+"/resolve", adminToken, gin.H{"action": "hide", "note": "Spam link"})
+	action := body["action"].(map[string]interface{})
+	if action["moderator"] != "alice" || action["reportsResolved"] != float64(2) {
+		t.Fatalf("unexpected action %v", body)
+	}
+	_, body = s.do("GET", path, "", nil)
+	comment := body["comments"].([]interface{})[0].(map[string]interface{})
+	if comment["content"] != models.HiddenPlaceholder || comment["hidden"] != true {
+		t.Fatalf("expected the comment to be hidden, got %v", comment)
+	}
+	if code, _ := s.do("POST", "/threads/"+strconv.Itoa(commentID)+"/comment", carolToken, gin.H{"content": "Replying anyway"}); code != http.StatusNotFound {
+		t.Fatalf("expected 404 replying to a hidden comment, got %d", code)
+	}
+	code, _ = s.do("POST", "/moderation/reports/"+strconv.Itoa(commentID)+"/resolve", adminToken, gin.H{"action": "dismiss"})
+	if code != http.StatusNotFound {
+		t.Fatalf("expected 404 with no open reports, got %d", code)
+	}
+	s.do("POST", "/moderation/reports/"+strconv.Itoa(id)+"/resolve", adminToken, gin.H{"action": "warn"})
+	_, body = s.do("GET", "/notifications", bobToken, nil)
+	if n := body["notifications"].([]interface{}); len(n) == 0 || n[0].(map[string]interface{})["type"] != "warning" {
+		t.Fatalf("expected a warning notification, got %v", body)
+	}
+
+	// Deleting leaves a tombstone over the reply and keeps the record
+	s.do("POST", path+"/report", carolToken, gin.H{"reason": "spam"})
+	s.do("POST", "/moderation/reports/"+strconv.Itoa(id)+"/resolve", adminToken, gin.H{"action": "delete"})
+	if _, body := s.do("GET", path, "", nil); body["thread"].(map[string]interface{})["deleted"] != true {
+		t.Fatalf("expected the thread to be deleted, got %v", body)
+	}
+	_, body = s.do("GET", "/moderation/actions", adminToken, nil)
+	actions := body["actions"].([]interface{})
+	if len(actions) != 3 || actions[0].(map[string]interface{})["action"] != "delete" || actions[0].(map[string]interface{})["author"] != "bob" {
+		t.Fatalf("unexpected action log %v", body)
+	}
+
+	// Hidden and deleted posts are off the queue for good
+	daveToken, _ := s.signUp("dave")
+	for _, target := range []int{commentID, id} {
+		if code, _ := s.do("POST", "/threads/"+strconv.Itoa(target)+"/report", daveToken, gin.H{"reason": "spam"}); code != http.StatusNotFound {
+			t.Fatalf("expected 404 reporting removed post %d, got %d", target, code)
+		}
+	}
+}
+
+func TestSoftDeleteTombstones(t *testing.T) {
+	s := newTestServer(t)
+	adminToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	s.store.PromoteUser("alice")
+
+	id := s.createThread(bobToken, "Thread with tombstones")
+	path := "/threads/" + strconv.Itoa(id)
+	comment := func(parent int, content string) int {
+		_, body := s.do("POST", "/threads/"+strconv.Itoa(parent)+"/comment", bobToken, gin.H{"content": content})
+		return int(body["id"].(float64))
+	}
+	parentID := comment(id, "Parent reply")
+	survivorID := comment(parentID, "Surviving reply")
+	leafID := comment(id, "Lonely reply")
+
+	// A deleted comment with replies becomes a tombstone; one without disappears
+	s.do("DELETE", "/threads/"+strconv.Itoa(parentID), bobToken, nil)
+	s.do("DELETE", "/threads/"+strconv.Itoa(leafID), bobToken, nil)
+	if code, _ := s.do("DELETE", "/threads/"+strconv.Itoa(leafID), bobToken, nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 deleting twice, got %d", code)
+	}
+	_, body := s.do("GET", path, "", nil)
+	byContent := map[string]map[string]interface{}{}
+	for _, item := range body["comments"].([]interface{}) {
+		c := item.(map[string]interface{})
+		byContent[c["content"].(string)] = c
+	}
+	tombstone := byContent[models.DeletedPlaceholder]
+	if len(byContent) != 2 || tombstone == nil || tombstone["author"] != models.DeletedPlaceholder || byContent["Surviving reply"] == nil {
+		t.Fatalf("expected a tombstone and its reply, got %v", body["comments"])
+	}
+	if body["thread"].(map[string]interface{})["commentsCount"] != float64(0) {
+		t.Fatalf("expected deleted replies to leave the count, got %v", body["thread"])
+	}
+	if code, _ := s.do("POST", "/threads/"+strconv.Itoa(parentID)+"/comment", bobToken, gin.H{"content": "Too late"}); code != http.StatusNotFound {
+		t.Fatalf("expected 404 replying to a deleted comment, got %d", code)
+	}
+
+	// Deleted threads leave listings but keep their replies reachable
+	s.do("DELETE", path, bobToken, nil)
+	if _, body := s.do("GET", "/threads", "", nil); len(body["threads"].([]interface{})) != 0 {
+		t.Fatalf("expected no listed threads, got %v", body["threads"])
+	}
+	_, body = s.do("GET", path, "", nil)
+	if thread := body["thread"].(map[string]interface{}); thread["title"] != models.DeletedPlaceholder || thread["deleted"] != true {
+		t.Fatalf("expected a thread tombstone, got %v", thread)
+	}
+	if code, _ := s.do("POST", "/threads/"+strconv.Itoa(survivorID)+"/comment", bobToken, gin.H{"content": "Too late"}); code != http.StatusNotFound {
+		t.Fatalf("expected 404 replying below a deleted thread, got %d", code)
+	}
+
+	// Only admins restore and purge
+	restorePath := "/moderation/threads/" + strconv.Itoa(id) + "/restore"
+	if code, _ := s.do("POST", restorePath, bobToken, nil); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin restore, got %d", code)
+	}
+	if code, _ := s.do("POST", restorePath, adminToken, nil); code != http.StatusOK {
+		t.Fatalf("expected 200 restoring, got %d", code)
+	}
+	if code, _ := s.do("POST", restorePath, adminToken, nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 restoring a live thread, got %d", code)
+	}
+	if _, body := s.do("GET", "/threads", "", nil); len(body["threads"].([]interface{})) != 1 {
+		t.Fatalf("expected the restored thread listed, got %v", body["threads"])
+	}
+	if code, _ := s.do("DELETE", "/moderation/threads/"+strconv.Itoa(id), adminToken, nil); code != http.StatusOK {
+		t.Fatalf("expected 200 purging, got %d", code)
+	}
+	if code, _ := s.do("GET", "/threads/"+strconv.Itoa(parentID), "", nil); code != http.StatusNotFound {
+		t.Fatalf("expected purged replies to be gone, got %d", code)
+	}
+}
+
+func TestThreadRevisions(t *testing.T) {
+	s := newTestServer(t)
+	adminToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	s.store.PromoteUser("alice")
+
+	id := s.createThread(bobToken, "Original title")
+	path := "/threads/" + strconv.Itoa(id)
+	if _, body := s.do("GET", path, "", nil); body["thread"].(map[string]interface{})["edited"] != false {
+		t.Fatalf("expected a fresh thread to be unedited, got %v", body["thread"])
+	}
+
+	s.do("PUT", path, bobToken, gin.H{"content": "Some content that is now longer."})
+	s.do("PUT", path, bobToken, gin.H{"content": "Some content that is now longer."}) // No change, no revision
+	s.do("PUT", path, adminToken, gin.H{"title": "Edited title", "content": "Some content that is now longer."})
+
+	_, body := s.do("GET", path, "", nil)
+	if thread := body["thread"].(map[string]interface{}); thread["edited"] != true || thread["updatedAt"] == nil {
+		t.Fatalf("expected the thread to be marked edited, got %v", thread)
+	}
+	_, body = s.do("GET", path+"/revisions", "", nil)
+	revisions := body["revisions"].([]interface{})
+	if len(revisions) != 2 {
+		t.Fatalf("expected 2 revisions, got %v", body)
+	}
+	latest, first := revisions[0].(map[string]interface{}), revisions[1].(map[string]interface{})
+	if latest["editor"] != "alice" || latest["title"] != "Original title" || first["editor"] != "bob" {
+		t.Fatalf("unexpected revisions %v", revisions)
+	}
+	contentDiff, _ := json.Marshal(first["contentDiff"])
+	if string(contentDiff) != `[{"op":"equal","text":"Some content that is "},{"op":"delete","text":"long enough."},{"op":"insert","text":"now longer."}]` {
+		t.Fatalf("unexpected content diff %s", contentDiff)
+	}
+	titleDiff, _ := json.Marshal(latest["titleDiff"])
+	if string(titleDiff) != `[{"op":"delete","text":"Original"},{"op":"insert","text":"Edited"},{"op":"equal","text":" title"}]` {
+		t.Fatalf("unexpected title diff %s", titleDiff)
+	}
+
+	// Only admins roll back, and the rollback is itself a revision
+	rollback := path + "/revisions/" + strconv.Itoa(int(first["id"].(float64))) + "/rollback"
+	if code, _ := s.do("POST", rollback, bobToken, nil); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin rollback, got %d", code)
+	}
+	if code, _ := s.do("POST", path+"/revisions/9999/rollback", adminToken, nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown revision, got %d", code)
+	}
+	if code, _ := s.do("POST", rollback, adminToken, nil); code != http.StatusOK {
+		t.Fatalf("expected 200 rolling back, got %d", code)
+	}
+	_, body = s.do("GET", path, "", nil)
+	thread := body["thread"].(map[string]interface{})
+	if thread["title"] != "Original title" || thread["content"] != "Some content that is long enough." {
+		t.Fatalf("expected the original version back, got %v", thread)
+	}
+	_, body = s.do("GET", path+"/revisions", "", nil)
+	revisions = body["revisions"].([]interface{})
+	if len(revisions) != 3 {
+		t.Fatalf("expected the rollback to add a revision, got %v", body)
+	}
+
+	// A title taken by another thread since cannot be restored
+	s.createThread(bobToken, "Edited title")
+	rollback = path + "/revisions/" + strconv.Itoa(int(revisions[0].(map[string]interface{})["id"].(float64))) + "/rollback"
+	if code, body := s.do("POST", rollback, adminToken, nil); code != http.StatusBadRequest || fmt.Sprint(body["errors"]) != "[Title must be unique]" {
+		t.Fatalf("expected a taken title to be refused, got %d %v", code, body)
+	}
+}
+
+func TestCommentTree(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.signUp("alice")
+	id := s.createThread(token, "Thread with a tree")
+	comment := func(parent int, content string) int {
+		_, body := s.do("POST", "/threads/"+strconv.Itoa(parent)+"/comment", token, gin.H{"content": content})
+		return int(body["id"].(float64))
+	}
+	first := comment(id, "First top-level reply")
+	comment(id, "Second top-level reply")
+	comment(id, "Third top-level reply")
+	older := comment(first, "Older nested reply")
+	newer := comment(first, "Newer nested reply")
+	deepest := comment(older, "Deepest nested reply")
+	s.do("POST", "/threads/"+strconv.Itoa(newer)+"/like", token, nil)
+
+	node := func(item interface{}) (map[string]interface{}, []interface{}) {
+		n := item.(map[string]interface{})
+		return n, n["replies"].([]interface{})
+	}
+	path := "/threads/" + strconv.Itoa(id)
+
+	// Newest first by default, paged with a count of what is left
+	_, body := s.do("GET", path+"?format=tree&limit=2", "", nil)
+	comments := body["comments"].([]interface{})
+	if len(comments) != 2 || body["moreReplies"] != float64(1) {
+		t.Fatalf("expected 2 of 3 top-level replies, got %v", body)
+	}
+	if top, _ := node(comments[0]); top["content"] != "Third top-level reply" {
+		t.Fatalf("expected the newest reply first, got %v", top)
+	}
+
+	// Loading more continues where the page stopped, with nested replies
+	_, body = s.do("GET", path+"/replies?offset=2&limit=2", "", nil)
+	replies := body["replies"].([]interface{})
+	if len(replies) != 1 || body["moreReplies"] != float64(0) {
+		t.Fatalf("expected the last top-level reply, got %v", body)
+	}
+	if top, nested := node(replies[0]); top["id"] != float64(first) || len(nested) != 2 {
+		t.Fatalf("expected the first reply with both nested replies, got %v", top)
+	}
+
+	// Sorting and paging apply within each sibling group
+	_, body = s.do("GET", path+"?format=tree&sortBy=likes&order=asc&limit=1", "", nil)
+	top, nested := node(body["comments"].([]interface{})[0])
+	if top["id"] != float64(first) || top["moreReplies"] != float64(1) || len(nested) != 1 {
+		t.Fatalf("expected one page of nested replies, got %v", top)
+	}
+	if reply, _ := node(nested[0]); reply["id"] != float64(older) {
+		t.Fatalf("expected the unliked reply first in ascending order, got %v", reply)
+	}
+
+	// Past the depth cutoff, replies are left for "continue this thread"
+	_, body = s.do("GET", path+"?format=tree&order=asc&depth=2", "", nil)
+	_, nested = node(body["comments"].([]interface{})[0])
+	reply, deeper := node(nested[0])
+	if reply["continueThread"] != true || len(deeper) != 0 {
+		t.Fatalf("expected a continue link instead of deeper replies, got %v", reply)
+	}
+	_, body = s.do("GET", "/threads/"+strconv.Itoa(older)+"/replies", "", nil)
+	if replies := body["replies"].([]interface{}); len(replies) != 1 || replies[0].(map[string]interface{})["id"] != float64(deepest) {
+		t.Fatalf("expected the continued thread, got %v", body)
+	}
+	if code, _ := s.do("GET", path+"/replies?order=sideways", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid order, got %d", code)
+	}
+
+	// A chain of tombstones stays in place above a live reply
+	chained := s.createThread(token, "Thread with a deleted chain")
+	outer := comment(chained, "Outer reply, deleted later")
+	inner := comment(outer, "Inner reply, deleted later")
+	live := comment(inner, "Live reply at the bottom")
+	s.do("DELETE", "/threads/"+strconv.Itoa(inner), token, nil)
+	s.do("DELETE", "/threads/"+strconv.Itoa(outer), token, nil)
+	_, body = s.do("GET", "/threads/"+strconv.Itoa(chained)+"?format=tree", "", nil)
+	comments = body["comments"].([]interface{})
+	if len(comments) != 1 {
+		t.Fatalf("expected the outer tombstone, got %v", body)
+	}
+	top, nested = node(comments[0])
+	if top["id"] != float64(outer) || top["deleted"] != true || len(nested) != 1 {
+		t.Fatalf("expected the outer tombstone with the inner one below, got %v", top)
+	}
+	reply, deeper = node(nested[0])
+	if reply["id"] != float64(inner) || len(deeper) != 1 || deeper[0].(map[string]interface{})["id"] != float64(live) {
+		t.Fatalf("expected the live reply below both tombstones, got %v", reply)
+	}
+
+	// Replies below a hidden thread are gone with it
+	shallow := comment(chained, "Shallow reply")
+	bobToken, _ := s.signUp("bob")
+	s.store.PromoteUser("bob")
+	s.do("POST", "/threads/"+strconv.Itoa(chained)+"/report", bobToken, gin.H{"reason": "spam"})
+	s.do("POST", "/moderation/reports/"+strconv.Itoa(chained)+"/resolve", bobToken, gin.H{"action": "hide"})
+	if code, _ := s.do("GET", "/threads/"+strconv.Itoa(inner)+"/replies", "", nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for replies under a hidden thread, got %d", code)
+	}
+	if code, _ := s.do("POST", "/threads/"+strconv.Itoa(shallow)+"/comment", token, gin.H{"content": "Nobody will see this"}); code != http.StatusNotFound {
+		t.Fatalf("expected 404 replying below a hidden thread, got %d", code)
+	}
+	if code, _ := s.do("GET", "/threads/999/replies", "", nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing post, got %d", code)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	s := newTestServerWithLimits(t, map[string]ratelimit.Policy{
+		ratelimit.Login:          {Burst: 2, Every: time.Minute},
+		ratelimit.Vote:           {Burst: 1, Every: time.Hour},
+		ratelimit.NewAccountPost: {Burst: 1, Every: time.Minute, AccountAge: time.Hour},
+		ratelimit.Comment:        {Burst: 5, Every: time.Minute},
+	})
+	token, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+
+	// Logins are throttled per address, with the state in headers
+	payload, _ := json.Marshal(gin.H{"username": "alice", "password": "password123"})
+	req := httptest.NewRequest("POST", "/users/login", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after the burst, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "60" || rec.Header().Get("X-RateLimit-Limit") != "2" || rec.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("unexpected rate limit headers %v", rec.Header())
+	}
+
+	// Votes are throttled per account
+	id := s.createThread(token, "Throttled thread")
+	path := "/threads/" + strconv.Itoa(id)
+	if code, _ := s.do("POST", path+"/like", token, nil); code != http.StatusOK {
+		t.Fatalf("expected the first vote through, got %d", code)
+	}
+	if code, _ := s.do("POST", path+"/dislike", token, nil); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for the second vote, got %d", code)
+	}
+	if code, _ := s.do("DELETE", path+"/like", token, nil); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 taking the vote back, got %d", code)
+	}
+	if code, _ := s.do("POST", path+"/like", bobToken, nil); code != http.StatusOK {
+		t.Fatalf("expected another account's vote through, got %d", code)
+	}
+
+	// New accounts wait between posts, threads and comments alike
+	code, body := s.do("POST", path+"/comment", token, gin.H{"content": "Too soon after posting"})
+	if code != http.StatusTooManyRequests || body["retryAfter"] != float64(60) {
+		t.Fatalf("expected 429 posting again right away, got %d %v", code, body)
+	}
+
+	// The headers follow whichever limit has the fewest requests left
+	payload, _ = json.Marshal(gin.H{"content": "First post by bob"})
+	req = httptest.NewRequest("POST", path+"/comment", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+bobToken)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected bob's first post through, got %d", rec.Code)
+	}
+	if rec.Header().Get("X-RateLimit-Limit") != "1" || rec.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("expected the new account limit in the headers, got %v", rec.Header())
+	}
+}
+
+func TestThreadTags(t *testing.T) {
+	s := newTestServer(t)
+	adminToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	s.store.PromoteUser("alice")
+
+	post := func(token, title string, tags ...string) (int, map[string]interface{}) {
+		return s.do("POST", "/threads", token, gin.H{
+			"title":    title,
+			"content":  "Some content that is long enough.",
+			"category": "Community",
+			"tags":     tags,
+		})
+	}
+	post(bobToken, "Go and SQL", "go", "sql", "go")
+	post(bobToken, "Only Go", " go ")
+	post(bobToken, "Only Rust", "rust")
+	if code, _ := post(bobToken, "Too many tags", "a", "b", "c", "d", "e", "f"); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for too many tags, got %d", code)
+	}
+
+	titles := func(query string) []string {
+		t.Helper()
+		code, body := s.do("GET", "/threads?sortBy=created_at&order=asc&"+query, "", nil)
+		if code != http.StatusOK {
+			t.Fatalf("list %s: got %d %v", query, code, body)
+		}
+		var titles []string
+		for _, item := range body["threads"].([]interface{}) {
+			titles = append(titles, item.(map[string]interface{})["title"].(string))
+		}
+		return titles
+	}
+	if got := titles("tags=sql,rust"); !slices.Equal(got, []string{"Go and SQL", "Only Rust"}) {
+		t.Fatalf("expected threads with any of the tags, got %v", got)
+	}
+	if got := titles("tag=go&tag=sql&tagMatch=all"); !slices.Equal(got, []string{"Go and SQL"}) {
+		t.Fatalf("expected threads with all of the tags, got %v", got)
+	}
+	if code, _ := s.do("GET", "/threads?tags=go&tagMatch=some", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown tagMatch, got %d", code)
+	}
+
+	_, body := s.do("GET", "/threads?tags=sql", "", nil)
+	thread := body["threads"].([]interface{})[0].(map[string]interface{})
+	if thread["tag"] != "go" || fmt.Sprint(thread["tags"]) != "[go sql]" {
+		t.Fatalf("expected tags in the order given, got %v", thread)
+	}
+
+	// Admins curate tags
+	tagIDs := map[string]int{}
+	_, body = s.do("GET", "/threads/tags", "", nil)
+	for _, item := range body["tags"].([]interface{}) {
+		tag := item.(map[string]interface{})
+		tagIDs[tag["name"].(string)] = int(tag["id"].(float64))
+	}
+	tagPath := func(name string) string { return "/tags/" + strconv.Itoa(tagIDs[name]) }
+	if code, _ := s.do("PUT", tagPath("go"), bobToken, gin.H{"name": "golang"}); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin rename, got %d", code)
+	}
+	if code, _ := s.do("PUT", tagPath("go"), adminToken, gin.H{"name": "rust"}); code != http.StatusConflict {
+		t.Fatalf("expected 409 renaming onto an existing tag, got %d", code)
+	}
+	if code, _ := s.do("PUT", tagPath("go"), adminToken, gin.H{"name": "golang"}); code != http.StatusOK {
+		t.Fatalf("expected 200 renaming a tag, got %d", code)
+	}
+	if got := titles("tags=golang"); len(got) != 2 {
+		t.Fatalf("expected the renamed tag on both threads, got %v", got)
+	}
+	if code, _ := s.do("POST", tagPath("sql")+"/merge", adminToken, gin.H{"into": tagIDs["go"]}); code != http.StatusOK {
+		t.Fatalf("expected 200 merging tags, got %d", code)
+	}
+	_, body = s.do("GET", "/threads?tags=golang&sortBy=created_at&order=asc", "", nil)
+	if tags := body["threads"].([]interface{})[0].(map[string]interface{})["tags"]; fmt.Sprint(tags) != "[golang]" {
+		t.Fatalf("expected the merged tag once, got %v", tags)
+	}
+	if code, _ := s.do("DELETE", tagPath("rust"), adminToken, nil); code != http.StatusOK {
+		t.Fatalf("expected 200 deleting a tag, got %d", code)
+	}
+	if got := titles("tags=rust"); len(got) != 0 {
+		t.Fatalf("expected no threads under a deleted tag, got %v", got)
+	}
+	if code, _ := s.do("DELETE", tagPath("rust"), adminToken, nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 deleting a missing tag, got %d", code)
+	}
+
+	// With creation restricted, only admins introduce new tags
+	s.handler.RestrictTagCreation = true
+	if code, _ := post(bobToken, "New tag by bob", "python"); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a new tag from a user, got %d", code)
+	}
+	if code, _ := post(bobToken, "Existing tag by bob", "golang"); code != http.StatusCreated {
+		t.Fatalf("expected existing tags to stay open, got %d", code)
+	}
+	if code, _ := s.do("POST", "/tags", adminToken, gin.H{"name": "python"}); code != http.StatusCreated {
+		t.Fatalf("expected 201 creating a tag, got %d", code)
+	}
+	if code, _ := post(bobToken, "New tag by bob", "python"); code != http.StatusCreated {
+		t.Fatalf("expected the admin-created tag to be usable, got %d", code)
+	}
+}
+
+func TestCategoryTree(t *testing.T) {
+	s := newTestServer(t)
+	adminToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	s.store.PromoteUser("alice")
+
+	post := func(token, title, category string) int {
+		code, _ := s.do("POST", "/threads", token, gin.H{
+			"title":    title,
+			"content":  "Some content that is long enough.",
+			"category": category,
+			"tag":      "Rules",
+		})
+		return code
+	}
+
+	code, body := s.do("POST", "/categories", adminToken, gin.H{"name": "Study Groups", "parentId": 4, "position": 1, "description": "Find people to study with"})
+	if code != http.StatusCreated || body["slug"] != "study-groups" {
+		t.Fatalf("expected a category with a derived slug, got %d %v", code, body)
+	}
+	studyID := int(body["id"].(float64))
+	if code, _ := s.do("POST", "/categories", bobToken, gin.H{"name": "Off Topic"}); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin, got %d", code)
+	}
+	if code, _ := s.do("POST", "/categories", adminToken, gin.H{"name": "Events"}); code != http.StatusConflict {
+		t.Fatalf("expected 409 for a duplicate name, got %d", code)
+	}
+	if code, _ := s.do("PUT", "/categories/4", adminToken, gin.H{"name": "Community", "parentId": studyID}); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 placing a category below its own subcategory, got %d", code)
+	}
+
+	// Threads go into existing categories only, by name or slug
+	if code := post(bobToken, "In a subforum", "study-groups"); code != http.StatusCreated {
+		t.Fatalf("expected 201 posting by slug, got %d", code)
+	}
+	if code := post(bobToken, "In the parent", "Community"); code != http.StatusCreated {
+		t.Fatalf("expected 201 posting by name, got %d", code)
+	}
+	if code := post(bobToken, "Nowhere", "Made Up"); code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an unknown category, got %d", code)
+	}
+	if code := post(bobToken, "Featured by bob", "Featured"); code != http.StatusForbidden {
+		t.Fatalf("expected 403 posting in an admin-only category, got %d", code)
+	}
+	if code := post(adminToken, "Featured by alice", "featured"); code != http.StatusCreated {
+		t.Fatalf("expected admins to post in an admin-only category, got %d", code)
+	}
+
+	_, body = s.do("GET", "/threads/categories", "", nil)
+	categories := body["categories"].([]interface{})
+	if len(categories) != 4 {
+		t.Fatalf("expected the 4 top-level categories, got %v", categories)
+	}
+	community := categories[3].(map[string]interface{})
+	children := community["children"].([]interface{})
+	if community["slug"] != "community" || community["threadCount"] != float64(2) || community["latestActivity"] == nil || len(children) != 1 {
+		t.Fatalf("expected community to roll up its subforum, got %v", community)
+	}
+	if study := children[0].(map[string]interface{}); study["threadCount"] != float64(1) || study["description"] != "Find people to study with" {
+		t.Fatalf("unexpected subforum %v", study)
+	}
+
+	// Listing a category includes its subcategories
+	if _, body := s.do("GET", "/threads?category=community", "", nil); len(body["threads"].([]interface{})) != 2 {
+		t.Fatalf("expected both community threads, got %v", body["threads"])
+	}
+
+	if code, _ := s.do("DELETE", "/categories/"+strconv.Itoa(studyID), adminToken, nil); code != http.StatusConflict {
+		t.Fatalf("expected 409 deleting a category with threads, got %d", code)
+	}
+	if code, _ := s.do("DELETE", "/categories/2", adminToken, nil); code != http.StatusOK {
+		t.Fatalf("expected 200 deleting an empty category, got %d", code)
+	}
+	if code, _ := s.do("PUT", "/categories/2", adminToken, gin.H{"name": "Coursework"}); code != http.StatusNotFound {
+		t.Fatalf("expected 404 updating a deleted category, got %d", code)
+	}
+}
+
+func TestPinnedAndLockedThreads(t *testing.T) {
+	s := newTestServer(t)
+	adminToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	s.store.PromoteUser("alice")
+
+	rules := s.createThread(adminToken, "Welcome to the Forum")
+	older := s.createThread(bobToken, "Older discussion")
+	s.createThread(bobToken, "Newest discussion")
+	s.do("POST", "/threads", bobToken, gin.H{"title": "Event news", "content": "Some content that is long enough.", "category": "Events"})
+	_, body := s.do("GET", "/threads?category=Events", "", nil)
+	events := int(body["threads"].([]interface{})[0].(map[string]interface{})["id"].(float64))
+	path := func(id int, action string) string { return "/moderation/threads/" + strconv.Itoa(id) + "/" + action }
+
+	if code, _ := s.do("PUT", path(rules, "pin"), bobToken, nil); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-admin pin, got %d", code)
+	}
+	if code, _ := s.do("PUT", path(rules, "pin"), adminToken, gin.H{"scope": "sideways"}); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown scope, got %d", code)
+	}
+	if code, _ := s.do("PUT", path(rules, "pin"), adminToken, nil); code != http.StatusOK {
+		t.Fatalf("expected 200 pinning, got %d", code)
+	}
+	s.do("PUT", path(events, "pin"), adminToken, gin.H{"scope": "category"})
+	s.do("PUT", path(rules, "announcement"), adminToken, nil)
+
+	first := func(query string) map[string]interface{} {
+		t.Helper()
+		_, body := s.do("GET", "/threads?"+query, "", nil)
+		return body["threads"].([]interface{})[0].(map[string]interface{})
+	}
+	for _, query := range []string{"sortBy=created_at", "sortBy=created_at&order=asc", "sortBy=top"} {
+		if thread := first(query); thread["title"] != "Welcome to the Forum" || thread["pinned"] != "global" || thread["announcement"] != true {
+			t.Fatalf("expected the pinned announcement first for %s, got %v", query, thread)
+		}
+	}
+	// Category pins lead only their category's listing
+	if thread := first("category=Events"); thread["title"] != "Event news" {
+		t.Fatalf("expected the category pin first, got %v", thread)
+	}
+	// Cursor pages keep to the limit, with the pins alongside the first one
+	_, body = s.do("GET", "/threads?sortBy=created_at&order=asc&paginate=cursor&limit=1", "", nil)
+	if threads := body["threads"].([]interface{}); len(threads) != 1 || threads[0].(map[string]interface{})["id"] != float64(older) {
+		t.Fatalf("expected a single regular thread on the first cursor page, got %v", threads)
+	}
+	if pinned := body["pinned"].([]interface{}); len(pinned) != 1 || pinned[0].(map[string]interface{})["title"] != "Welcome to the Forum" {
+		t.Fatalf("expected the pin beside the first cursor page, got %v", pinned)
+	}
+	_, body = s.do("GET", "/threads?sortBy=created_at&order=asc&paginate=cursor&limit=1&cursor="+body["nextCursor"].(string), "", nil)
+	if threads := body["threads"].([]interface{}); len(threads) != 1 || threads[0].(map[string]interface{})["title"] == "Welcome to the Forum" || len(body["pinned"].([]interface{})) != 0 {
+		t.Fatalf("expected later cursor pages without the pin, got %v", body)
+	}
+	_, body = s.do("GET", "/threads?sortBy=created_at&order=asc&limit=1&cursor="+body["prevCursor"].(string), "", nil)
+	if threads := body["threads"].([]interface{}); len(threads) != 1 || len(body["pinned"].([]interface{})) != 1 {
+		t.Fatalf("expected the pin back on the first page, got %v", body)
+	}
+
+	s.do("DELETE", path(rules, "pin"), adminToken, nil)
+	if thread := first("sortBy=created_at"); thread["title"] == "Welcome to the Forum" {
+		t.Fatalf("expected an unpinned thread back in place, got %v", thread)
+	}
+
+	// Locked threads take no replies or votes, except an admin's closing note
+	threadPath := "/threads/" + strconv.Itoa(older)
+	_, body = s.do("POST", threadPath+"/comment", bobToken, gin.H{"content": "A reply before the lock"})
+	commentID := int(body["id"].(float64))
+	if code, _ := s.do("PUT", path(commentID, "lock"), adminToken, nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 locking a comment, got %d", code)
+	}
+	s.do("PUT", path(older, "lock"), adminToken, nil)
+	if code, body := s.do("POST", threadPath+"/comment", bobToken, gin.H{"content": "A reply after the lock"}); code != http.StatusForbidden || body["error"] != models.ErrThreadLocked.Error() {
+		t.Fatalf("expected 403 replying to a locked thread, got %d %v", code, body)
+	}
+	if code, _ := s.do("POST", "/threads/"+strconv.Itoa(commentID)+"/comment", bobToken, gin.H{"content": "A nested reply after the lock"}); code != http.StatusForbidden {
+		t.Fatalf("expected 403 replying within a locked thread, got %d", code)
+	}
+	if code, _ := s.do("PUT", "/threads/"+strconv.Itoa(commentID)+"/vote", bobToken, gin.H{"value": 1}); code != http.StatusForbidden {
+		t.Fatalf("expected 403 voting in a locked thread, got %d", code)
+	}
+	if code, _ := s.do("POST", threadPath+"/like", bobToken, nil); code != http.StatusForbidden {
+		t.Fatalf("expected 403 liking a locked thread, got %d", code)
+	}
+	if code, _ := s.do("POST", threadPath+"/comment", adminToken, gin.H{"content": "Closing this discussion"}); code != http.StatusCreated {
+		t.Fatalf("expected an admin's closing note through, got %d", code)
+	}
+	if _, body := s.do("GET", threadPath, "", nil); body["thread"].(map[string]interface{})["locked"] != true {
+		t.Fatalf("expected the thread marked locked, got %v", body["thread"])
+	}
+
+	s.do("DELETE", path(older, "lock"), adminToken, nil)
+	if code, _ := s.do("PUT", threadPath+"/vote", bobToken, gin.H{"value": 1}); code != http.StatusOK {
+		t.Fatalf("expected votes again once unlocked, got %d", code)
+	}
+}
+
+func TestThreadSubscriptions(t *testing.T) {
+	s := newTestServer(t)
+	aliceToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	carolToken, _ := s.signUp("carol")
+	id := s.createThread(aliceToken, "Follow me")
+	path := "/threads/" + strconv.Itoa(id)
+
+	subscriptions := func(token, username string) map[string]interface{} {
+		t.Helper()
+		code, body := s.do("GET", "/users/"+username+"/subscriptions", token, nil)
+		if code != http.StatusOK {
+			t.Fatalf("expected 200 fetching subscriptions, got %d", code)
+		}
+		return body
+	}
+	countNotifications := func(token, kind string) int {
+		t.Helper()
+		_, body := s.do("GET", "/notifications", token, nil)
+		count := 0
+		for _, item := range body["notifications"].([]interface{}) {
+			if item.(map[string]interface{})["type"] == kind {
+				count++
+			}
+		}
+		return count
+	}
+
+	// Authors and commenters follow the thread; carol follows it by hand
+	_, body := s.do("POST", path+"/comment", bobToken, gin.H{"content": "Bob joins in"})
+	bobComment := int(body["id"].(float64))
+	if code, body := s.do("POST", "/threads/"+strconv.Itoa(bobComment)+"/subscribe", carolToken, nil); code != http.StatusOK || body["threadId"] != float64(id) {
+		t.Fatalf("expected a comment to resolve to its thread, got %d %v", code, body)
+	}
+	s.do("POST", "/threads/"+strconv.Itoa(bobComment)+"/comment", aliceToken, gin.H{"content": "Alice answers bob"})
+	s.do("POST", path+"/comment", bobToken, gin.H{"content": "Bob again"})
+
+	// Commenting counts as reading, so alice has only bob's latest comment unread
+	threads := subscriptions(aliceToken, "alice")["threads"].([]interface{})
+	if len(threads) != 1 || threads[0].(map[string]interface{})["unreadCount"] != float64(1) {
+		t.Fatalf("expected alice to see 1 comment unread, got %v", threads)
+	}
+	if threads := subscriptions(carolToken, "carol")["threads"].([]interface{}); threads[0].(map[string]interface{})["unreadCount"] != float64(2) {
+		t.Fatalf("expected carol to see the 2 comments since subscribing unread, got %v", threads)
+	}
+	// Bob's second comment replied to alice's thread, so it came as a reply instead
+	if count := countNotifications(aliceToken, "thread_reply"); count != 0 {
+		t.Fatalf("expected no duplicate thread_reply for the parent's author, got %d", count)
+	}
+	if count := countNotifications(carolToken, "thread_reply"); count != 2 {
+		t.Fatalf("expected carol notified of 2 comments, got %d", count)
+	}
+
+	s.do("PUT", path+"/read", aliceToken, nil)
+	if threads := subscriptions(aliceToken, "alice")["threads"].([]interface{}); threads[0].(map[string]interface{})["unreadCount"] != float64(0) {
+		t.Fatalf("expected nothing unread after marking read, got %v", threads)
+	}
+	if code, _ := s.do("GET", "/users/alice/subscriptions", bobToken, nil); code != http.StatusForbidden {
+		t.Fatalf("expected 403 reading someone else's subscriptions, got %d", code)
+	}
+	if code, _ := s.do("DELETE", path+"/subscribe", carolToken, nil); code != http.StatusOK {
+		t.Fatalf("expected 200 unsubscribing, got %d", code)
+	}
+	if code, _ := s.do("PUT", path+"/read", carolToken, nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 marking an unfollowed thread read, got %d", code)
+	}
+
+	// Following a category covers threads in its subcategories
+	s.store.PromoteUser("alice")
+	code, body := s.do("POST", "/categories", aliceToken, gin.H{"name": "Meetups", "parentId": 3})
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201 creating a subcategory, got %d %v", code, body)
+	}
+	if code, _ := s.do("POST", "/categories/99/subscribe", carolToken, nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 following a missing category, got %d", code)
+	}
+	s.do("POST", "/categories/3/subscribe", carolToken, nil)
+	s.do("POST", "/threads", bobToken, gin.H{"title": "Meetup on Friday", "content": "Some content that is long enough.", "category": "Meetups"})
+	if count := countNotifications(carolToken, "category_post"); count != 1 {
+		t.Fatalf("expected carol notified of the new thread, got %d", count)
+	}
+	body = subscriptions(carolToken, "carol")
+	if categories := body["categories"].([]interface{}); len(categories) != 1 || categories[0].(map[string]interface{})["name"] != "Events" {
+		t.Fatalf("expected carol to follow Events, got %v", categories)
+	}
+	if threads := body["threads"].([]interface{}); len(threads) != 0 {
+		t.Fatalf("expected no followed threads left for carol, got %v", threads)
+	}
+}
+
+func TestPersonalizedFeed(t *testing.T) {
+	s := newTestServer(t)
+	aliceToken, _ := s.signUp("alice")
+	bobToken, _ := s.signUp("bob")
+	carolToken, _ := s.signUp("carol")
+
+	post := func(token, title, category, tag string) int {
+		t.Helper()
+		code, body := s.do("POST", "/threads", token, gin.H{
+			"title":    title,
+			"content":  "Some content that is long enough.",
+			"category": category,
+			"tag":      tag,
+		})
+		if code != http.StatusCreated {
+			t.Fatalf("create thread: got %d %v", code, body)
+		}
+		return int(body["id"].(float64))
+	}
+	bobEvent := post(bobToken, "Bob's party", "Events", "Party")
+	commented := post(bobToken, "Bob asks around", "Events", "Party")
+	carolGo := post(carolToken, "Go generics", "Community", "Go")
+	saved := post(carolToken, "Rust basics", "Community", "Rust")
+	post(carolToken, "Gardening", "Community", "Plants")
+	post(aliceToken, "Alice's own event", "Events", "Party")
+	carolRust := post(carolToken, "Rust lifetimes", "Community", "Rust")
+
+	// Alice follows bob, Events and the Go tag, and engages with two threads
+	if code, _ := s.do("POST", "/users/alice/follow", aliceToken, nil); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 following yourself, got %d", code)
+	}
+	if code, _ := s.do("POST", "/users/nobody/follow", aliceToken, nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 following a missing user, got %d", code)
+	}
+	s.do("POST", "/users/bob/follow", aliceToken, nil)
+	s.do("POST", "/categories/3/subscribe", aliceToken, nil)
+	_, body := s.do("GET", "/threads/tags", "", nil)
+	for _, item := range body["tags"].([]interface{}) {
+		if tag := item.(map[string]interface{}); tag["name"] == "Go" {
+			s.do("POST", "/tags/"+strconv.Itoa(int(tag["id"].(float64)))+"/subscribe", aliceToken, nil)
+		}
+	}
+	if code, _ := s.do("POST", "/tags/999/subscribe", aliceToken, nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 following a missing tag, got %d", code)
+	}
+	s.do("POST", "/threads/"+strconv.Itoa(saved)+"/save", aliceToken, nil)
+	s.do("POST", "/threads/"+strconv.Itoa(commented)+"/comment", aliceToken, gin.H{"content": "Count me in"})
+
+	_, body = s.do("GET", "/users/alice/subscriptions", aliceToken, nil)
+	if following := body["following"].([]interface{}); len(following) != 1 || following[0] != "bob" {
+		t.Fatalf("expected alice to follow bob, got %v", following)
+	}
+	if tags := body["tags"].([]interface{}); len(tags) != 1 || tags[0].(map[string]interface{})["name"] != "Go" {
+		t.Fatalf("expected alice to follow Go, got %v", tags)
+	}
+
+	// Newest first, each thread once with all its reasons, seen threads left out
+	if code, _ := s.do("GET", "/feed", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a token, got %d", code)
+	}
+	code, body := s.do("GET", "/feed?limit=2", aliceToken, nil)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 fetching the feed, got %d %v", code, body)
+	}
+	feed := body["threads"].([]interface{})
+	if len(feed) != 2 || body["nextCursor"] == nil {
+		t.Fatalf("expected a full first page with a cursor, got %v", body)
+	}
+	_, body = s.do("GET", "/feed?limit=2&cursor="+body["nextCursor"].(string), aliceToken, nil)
+	feed = append(feed, body["threads"].([]interface{})...)
+	if body["nextCursor"] != nil {
+		t.Fatalf("expected the second page to be the last, got %v", body["nextCursor"])
+	}
+	want := []struct {
+		id      int
+		reasons string
+	}{
+		{carolRust, "[similar]"},
+		{carolGo, "[tag]"},
+		{bobEvent, "[followed_user category]"},
+	}
+	if len(feed) != len(want) {
+		t.Fatalf("expected %d feed threads, got %v", len(want), feed)
+	}
+	for i, item := range feed {
+		thread := item.(map[string]interface{})
+		if thread["id"] != float64(want[i].id) || fmt.Sprint(thread["reasons"]) != want[i].reasons {
+			t.Fatalf("unexpected feed thread %d: %v", i, thread)
+		}
+	}
+
+	// Cursors from other listings are refused
+	_, body = s.do("GET", "/threads?paginate=cursor&sortBy=likes&limit=1", "", nil)
+	if code, _ := s.do("GET", "/feed?cursor="+body["nextCursor"].(string), aliceToken, nil); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a listing cursor, got %d", code)
+	}
+
+	s.do("DELETE", "/users/bob/follow", aliceToken, nil)
+	s.do("DELETE", "/categories/3/subscribe", aliceToken, nil)
+	_, body = s.do("GET", "/feed", aliceToken, nil)
+	if feed := body["threads"].([]interface{}); len(feed) != 2 {
+		t.Fatalf("expected bob's thread gone after unfollowing, got %v", feed)
+	}
+}
@@ -1,75 +1,173 @@
 package controllers
 
 import (
-	"database/sql"
+	"backend/middleware"
+	"backend/models"
+	"backend/moderation"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	_ "github.com/joho/godotenv/autoload"
 )
 
-// Helper function to generate username-based authentication tokens (JWT)
-func generateJWT(username string) (string, error) {
-	claims := jwt.MapClaims{
-		"username": username,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(), // Token expires in 24 hours
+// Access tokens are short-lived; refresh tokens are rotated on every use
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Helper function to generate random URL-safe identifiers and secrets
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Helper function to hash refresh tokens before they are stored or looked up
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Helper function to generate username-based access tokens (JWT)
+func generateJWT(username string) (string, error) {
 	secretKey := []byte(os.Getenv("JWT_SECRET_KEY"))
 	if len(secretKey) == 0 {
 		return "", fmt.Errorf("JWT_SECRET_KEY environment variable not set")
 	}
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"username": username,
+		"jti":      jti, // Lets a single token be revoked on logout
+		"iat":      now.Unix(),
+		"iat_us":   now.UnixMicro(), // Orders the token against a revocation in the same second
+		"exp":      now.Add(accessTokenTTL).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(secretKey)
 }
 
+// Helper function to issue a fresh access token and a stored refresh token
+func issueTokens(users models.UserStore, userID int, username string) (gin.H, error) {
+	accessToken, err := generateJWT(username)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := users.CreateRefreshToken(userID, hashToken(refreshToken), refreshTokenTTL); err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Helper function to resolve the acting user from the verified token.
+// A non-empty claimed username (from the path, query or body) must match it.
+func actingUser(c *gin.Context, claimed string) (*models.Principal, bool) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return nil, false
+	}
+	if claimed != "" && claimed != principal.Username {
+		log.Printf("User '%s' attempted to act as '%s' on %s %s", principal.Username, claimed, c.Request.Method, c.FullPath())
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot act on behalf of another user"})
+		return nil, false
+	}
+	return principal, true
+}
+
+// Helper function to run user-written fields through the moderation rules,
+// the content check shared by every write path. Mask rules rewrite the fields
+// in place. Responds with an error and returns false if the rules are unavailable.
+func (h *Handler) moderate(c *gin.Context, fields ...moderation.Field) (moderation.Verdict, bool) {
+	verdict, err := h.Moderator.Review(fields...)
+	if err != nil {
+		log.Printf("Error reviewing content: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check content"})
+		return verdict, false
+	}
+	return verdict, true
+}
+
+// Helper function to review fields that are stored exactly as given, such as
+// usernames and restored revisions. Text a mask rule would rewrite is refused
+// instead, and the fields are left untouched.
+func (h *Handler) moderateVerbatim(c *gin.Context, fields ...moderation.Field) (moderation.Verdict, bool) {
+	reviewed := make([]moderation.Field, len(fields))
+	for i, field := range fields {
+		reviewed[i] = field
+		if field.Text != nil {
+			text := *field.Text
+			reviewed[i].Text = &text
+		}
+	}
+	verdict, ok := h.moderate(c, reviewed...)
+	if !ok {
+		return verdict, false
+	}
+	for i, field := range fields {
+		if field.Text != nil && *reviewed[i].Text != *field.Text {
+			verdict.Violations = append(verdict.Violations, field.Name+" contains masked words")
+		}
+	}
+	return verdict, true
+}
+
+// Helper function to measure content for length limits. Limits apply to the
+// Markdown source, in characters, never to the rendered HTML.
+func sourceLength(content string) int {
+	return utf8.RuneCountInString(strings.TrimSpace(content))
+}
+
 // Helper function to validate content of created thread
-func validateThread(db *sql.DB, isEdit bool, thread *struct {
+func validateThread(threads models.ThreadStore, isEdit bool, thread *struct {
 	Title   *string `json:"title"`   // Title is nullable
 	Content *string `json:"content"` // Content is nullable
 }) []string {
 	var errors []string
 
-	// List of prohibited words
-	var prohibitedWords = []string{"mother", "child"}
-
 	// Validate content
 	if thread.Content == nil || len(*thread.Content) == 0 {
 		errors = append(errors, "Content is required")
 	} else {
-		content := strings.ToLower(*thread.Content) // Dereference pointer
-		// Check for prohibited words in the content
-		for _, word := range prohibitedWords {
-			if strings.Contains(content, word) {
-				errors = append(errors, fmt.Sprintf("Content contains prohibited word: %s", word))
-			}
-		}
+		content := *thread.Content // Dereference pointer
 
 		// Validate content length
-		if len(content) < 10 {
+		if sourceLength(content) < 10 {
 			errors = append(errors, "Content must be at least 10 characters long")
 		}
-		if len(content) > 1000 {
+		if sourceLength(content) > 1000 {
 			errors = append(errors, "Content must be no more than 1000 characters long")
 		}
 	}
 
 	// Validate title
 	if thread.Title != nil {
-		title := strings.ToLower(*thread.Title) // Dereference pointer
-
-		// Check for prohibited words in the content (whole word match)
-		words := strings.Fields(*thread.Content)
-		for _, word := range words {
-			for _, prohibited := range prohibitedWords {
-				if word == prohibited {
-					errors = append(errors, fmt.Sprintf("Content contains prohibited word: %s", prohibited))
-				}
-			}
-		}
+		title := *thread.Title // Dereference pointer
 
 		// Validate title length
 		if len(title) < 5 {
@@ -81,12 +179,11 @@ func validateThread(db *sql.DB, isEdit bool, thread *struct {
 
 		// If it's not an edit, check title uniqueness
 		if !isEdit {
-			var count int
-			err := db.QueryRow("SELECT COUNT(*) FROM threads WHERE title = $1", *thread.Title).Scan(&count)
+			exists, err := threads.TitleExists(*thread.Title)
 			if err != nil {
 				log.Printf("Error checking title uniqueness: %v", err)
 				errors = append(errors, "Error checking title uniqueness")
-			} else if count > 0 {
+			} else if exists {
 				errors = append(errors, "Title must be unique")
 			}
 		}
@@ -95,36 +192,38 @@ func validateThread(db *sql.DB, isEdit bool, thread *struct {
 	return errors
 }
 
+// Helper function to validate the tags given to a new thread
+func validateTags(tags []string) []string {
+	var errors []string
+	if len(tags) > models.MaxTagsPerThread {
+		errors = append(errors, fmt.Sprintf("A thread can have at most %d tags", models.MaxTagsPerThread))
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > models.MaxTagLength {
+			errors = append(errors, fmt.Sprintf("Tags must be no more than %d characters long", models.MaxTagLength))
+			break
+		}
+	}
+	return errors
+}
+
 // Helper function to validate content of created thread
 func validateComment(comment *struct {
 	Content *string `json:"content"` // Content is nullable
 }) []string {
 	var errors []string
 
-	// List of prohibited words
-	var prohibitedWords = []string{"mother", "child"}
-
 	// Validate content
 	if comment.Content == nil || len(*comment.Content) == 0 {
 		errors = append(errors, "Content is required")
 	} else {
-		content := strings.ToLower(*comment.Content) // Dereference pointer
-
-		// Check for prohibited words in the content (whole word match)
-		words := strings.Fields(content) // Tokenize content into words
-		for _, word := range words {
-			for _, prohibited := range prohibitedWords {
-				if word == prohibited {
-					errors = append(errors, fmt.Sprintf("Content contains prohibited word: %s", prohibited))
-				}
-			}
-		}
+		content := *comment.Content // Dereference pointer
 
 		// Validate content length
-		if len(content) < 5 {
+		if sourceLength(content) < 5 {
 			errors = append(errors, "Content must be at least 5 characters long")
 		}
-		if len(content) > 500 {
+		if sourceLength(content) > 500 {
 			errors = append(errors, "Content must be no more than 500 characters long")
 		}
 	}
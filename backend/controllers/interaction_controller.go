@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"backend/models"
-	"database/sql"
 	"log"
 	"net/http"
 	"strconv"
@@ -11,7 +10,7 @@ import (
 )
 
 // Get user's interaction state (liked/disliked)
-func GetInteractionState(c *gin.Context, db *sql.DB) {
+func (h *Handler) GetInteractionState(c *gin.Context) {
 	threadID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(400, gin.H{"error": "Invalid thread ID"})
@@ -19,13 +18,13 @@ func GetInteractionState(c *gin.Context, db *sql.DB) {
 	}
 
 	username := c.Query("username")
-	userID, err := models.GetUserIDFromUsername(db, username)
+	userID, err := h.Users.GetUserIDFromUsername(username)
 	if err != nil {
 		c.JSON(400, gin.H{"error": "Invalid username"})
 		return
 	}
 
-	liked, disliked, err := models.GetInteractionState(db, threadID, userID)
+	liked, disliked, err := h.Interactions.GetInteractionState(threadID, userID)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Failed to fetch interaction state"})
 		return
@@ -38,19 +37,19 @@ func GetInteractionState(c *gin.Context, db *sql.DB) {
 }
 
 // Get likes count
-func GetLikesCount(c *gin.Context, db *sql.DB) {
+func (h *Handler) GetLikesCount(c *gin.Context) {
 	threadID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
 		return
 	}
 
-	if err := models.CheckThreadExists(db, threadID); err != nil {
+	if err := h.Threads.CheckThreadExists(threadID); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	count, err := models.GetLikesCount(db, threadID)
+	count, err := h.Interactions.GetLikesCount(threadID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch likes count"})
 		return
@@ -60,19 +59,19 @@ func GetLikesCount(c *gin.Context, db *sql.DB) {
 }
 
 // Get dislikes count
-func GetDislikesCount(c *gin.Context, db *sql.DB) {
+func (h *Handler) GetDislikesCount(c *gin.Context) {
 	threadID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
 		return
 	}
 
-	if err := models.CheckThreadExists(db, threadID); err != nil {
+	if err := h.Threads.CheckThreadExists(threadID); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	count, err := models.GetDislikesCount(db, threadID)
+	count, err := h.Interactions.GetDislikesCount(threadID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dislikes count"})
 		return
@@ -81,119 +80,191 @@ func GetDislikesCount(c *gin.Context, db *sql.DB) {
 	c.JSON(http.StatusOK, gin.H{"dislikes_count": count})
 }
 
+// Set, change or clear the caller's vote on a thread in one step
+func (h *Handler) VoteThread(c *gin.Context) {
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
+		return
+	}
+
+	principal, ok := actingUser(c, c.Query("username"))
+	if !ok {
+		return
+	}
+	if !h.checkUnlocked(c, threadID) {
+		return
+	}
+
+	var input struct {
+		Value *int `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.Value == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vote value is required"})
+		return
+	}
+
+	err = h.Interactions.Vote(threadID, principal.UserID, *input.Value)
+	if err == models.ErrInvalidVote {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vote must be 1, 0 or -1"})
+		return
+	} else if err == models.ErrThreadNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		log.Printf("Failed to vote (Thread ID: %d, User ID: %d): %v", threadID, principal.UserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record vote"})
+		return
+	}
+
+	thread, err := h.Threads.FetchThreadByID(threadID)
+	if err != nil || thread == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch updated score"})
+		return
+	}
+	if *input.Value == 1 {
+		h.Notifier.LikeMilestone(thread.UserID, thread.ID, thread.LikesCount)
+	}
+	h.publishVotes(thread)
+
+	c.JSON(http.StatusOK, gin.H{
+		"vote":          *input.Value,
+		"score":         thread.Score,
+		"likesCount":    thread.LikesCount,
+		"dislikesCount": thread.DislikesCount,
+	})
+}
+
 // Like a thread
-func LikeThread(c *gin.Context, db *sql.DB) {
+func (h *Handler) LikeThread(c *gin.Context) {
 	threadID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(400, gin.H{"error": "Invalid thread ID"})
 		return
 	}
 
-	username := c.DefaultQuery("username", "")
-	userID, err := models.GetUserIDFromUsername(db, username)
-	if err != nil {
-		c.JSON(400, gin.H{"error": "Invalid username"})
+	principal, ok := actingUser(c, c.Query("username"))
+	if !ok {
+		return
+	}
+	if !h.checkUnlocked(c, threadID) {
 		return
 	}
 
-	if err := models.CheckThreadExists(db, threadID); err != nil {
+	if err := h.Threads.CheckThreadExists(threadID); err != nil {
 		c.JSON(404, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := models.AddLike(db, threadID, userID); err != nil {
+	if err := h.Interactions.AddLike(threadID, principal.UserID); err != nil {
 		c.JSON(500, gin.H{"error": "Failed to like thread"})
 		return
 	}
+	if thread, err := h.Threads.FetchThreadByID(threadID); err == nil && thread != nil {
+		h.Notifier.LikeMilestone(thread.UserID, thread.ID, thread.LikesCount)
+		h.publishVotes(thread)
+	}
 
 	c.JSON(200, gin.H{"message": "Thread liked successfully!"})
 }
 
 // Dislike a thread
-func DislikeThread(c *gin.Context, db *sql.DB) {
+func (h *Handler) DislikeThread(c *gin.Context) {
 	threadID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(400, gin.H{"error": "Invalid thread ID"})
 		return
 	}
 
-	username := c.DefaultQuery("username", "")
-	userID, err := models.GetUserIDFromUsername(db, username)
-	if err != nil {
-		c.JSON(400, gin.H{"error": "Invalid username"})
+	principal, ok := actingUser(c, c.Query("username"))
+	if !ok {
+		return
+	}
+	if !h.checkUnlocked(c, threadID) {
 		return
 	}
 
-	if err := models.CheckThreadExists(db, threadID); err != nil {
+	if err := h.Threads.CheckThreadExists(threadID); err != nil {
 		c.JSON(404, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := models.AddDislike(db, threadID, userID); err != nil {
+	if err := h.Interactions.AddDislike(threadID, principal.UserID); err != nil {
 		c.JSON(500, gin.H{"error": "Failed to dislike thread"})
 		return
 	}
+	if thread, err := h.Threads.FetchThreadByID(threadID); err == nil && thread != nil {
+		h.publishVotes(thread)
+	}
 
 	c.JSON(200, gin.H{"message": "Thread disliked successfully!"})
 }
 
 // Remove a like
-func RemoveLike(c *gin.Context, db *sql.DB) {
+func (h *Handler) RemoveLike(c *gin.Context) {
 	threadID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
 		return
 	}
 
-	username := c.DefaultQuery("username", "")
-	userID, err := models.GetUserIDFromUsername(db, username)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid username"})
+	principal, ok := actingUser(c, c.Query("username"))
+	if !ok {
+		return
+	}
+	if !h.checkUnlocked(c, threadID) {
 		return
 	}
 
-	if err := models.CheckThreadExists(db, threadID); err != nil {
+	if err := h.Threads.CheckThreadExists(threadID); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := models.RemoveLike(db, threadID, userID); err != nil {
+	if err := h.Interactions.RemoveLike(threadID, principal.UserID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove like"})
 		return
 	}
+	if thread, err := h.Threads.FetchThreadByID(threadID); err == nil && thread != nil {
+		h.publishVotes(thread)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Like removed successfully!"})
 }
 
 // Remove a dislike
-func RemoveDislike(c *gin.Context, db *sql.DB) {
+func (h *Handler) RemoveDislike(c *gin.Context) {
 	threadID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
 		return
 	}
 
-	username := c.DefaultQuery("username", "")
-	userID, err := models.GetUserIDFromUsername(db, username)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid username"})
+	principal, ok := actingUser(c, c.Query("username"))
+	if !ok {
+		return
+	}
+	if !h.checkUnlocked(c, threadID) {
 		return
 	}
 
-	if err := models.CheckThreadExists(db, threadID); err != nil {
+	if err := h.Threads.CheckThreadExists(threadID); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := models.RemoveDislike(db, threadID, userID); err != nil {
+	if err := h.Interactions.RemoveDislike(threadID, principal.UserID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove dislike"})
 		return
 	}
+	if thread, err := h.Threads.FetchThreadByID(threadID); err == nil && thread != nil {
+		h.publishVotes(thread)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Dislike removed successfully!"})
 }
 
-func GetSaveState(c *gin.Context, db *sql.DB) {
+func (h *Handler) GetSaveState(c *gin.Context) {
 	threadID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
@@ -201,14 +272,14 @@ func GetSaveState(c *gin.Context, db *sql.DB) {
 	}
 
 	username := c.Query("username")
-	userID, err := models.GetUserIDFromUsername(db, username)
+	userID, err := h.Users.GetUserIDFromUsername(username)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid username"})
 		return
 	}
 
 	var saveState bool
-	saveState, err = models.FetchSaveState(db, threadID, userID)
+	saveState, err = h.Interactions.FetchSaveState(threadID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check save state"})
 		return
@@ -217,7 +288,7 @@ func GetSaveState(c *gin.Context, db *sql.DB) {
 	c.JSON(http.StatusOK, gin.H{"isSaved": saveState})
 }
 
-func SaveThread(c *gin.Context, db *sql.DB) {
+func (h *Handler) SaveThread(c *gin.Context) {
 	threadID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		log.Printf("Invalid thread ID: %v", c.Param("id"))
@@ -225,23 +296,14 @@ func SaveThread(c *gin.Context, db *sql.DB) {
 		return
 	}
 
-	username := c.Query("username")
-	if username == "" {
-		log.Println("Username query parameter is missing")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Username is required"})
-		return
-	}
-
-	userID, err := models.GetUserIDFromUsername(db, username)
-	if err != nil {
-		log.Printf("Failed to fetch user ID for username '%s': %v", username, err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid username"})
+	principal, ok := actingUser(c, c.Query("username"))
+	if !ok {
 		return
 	}
 
-	err = models.SaveThread(db, threadID, userID)
+	err = h.Interactions.SaveThread(threadID, principal.UserID)
 	if err != nil {
-		log.Printf("Failed to save thread (Thread ID: %d, User ID: %d): %v", threadID, userID, err)
+		log.Printf("Failed to save thread (Thread ID: %d, User ID: %d): %v", threadID, principal.UserID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save thread"})
 		return
 	}
@@ -249,21 +311,19 @@ func SaveThread(c *gin.Context, db *sql.DB) {
 	c.JSON(http.StatusOK, gin.H{"message": "Thread saved successfully"})
 }
 
-func UnsaveThread(c *gin.Context, db *sql.DB) {
+func (h *Handler) UnsaveThread(c *gin.Context) {
 	threadID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(400, gin.H{"error": "Invalid thread ID"})
 		return
 	}
 
-	username := c.Query("username")
-	userID, err := models.GetUserIDFromUsername(db, username)
-	if err != nil {
-		c.JSON(400, gin.H{"error": "Invalid username"})
+	principal, ok := actingUser(c, c.Query("username"))
+	if !ok {
 		return
 	}
 
-	if err := models.UnsaveThread(db, threadID, userID); err != nil {
+	if err := h.Interactions.UnsaveThread(threadID, principal.UserID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsave thread"})
 		return
 	}
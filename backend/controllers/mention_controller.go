@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"backend/models"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Links a post to the users it mentions and notifies the newly mentioned ones
+func (h *Handler) recordMentions(threadID, actorID int, content string) {
+	added, err := h.Mentions.SaveMentions(threadID, models.ParseMentions(content))
+	if err != nil {
+		log.Printf("Failed to save mentions of thread %d: %v", threadID, err)
+		return
+	}
+	for _, userID := range added {
+		h.Notifier.Mention(userID, actorID, threadID)
+	}
+}
+
+// Splits the content of threads or comments into text and mention tokens
+func (h *Handler) tokenize(threads ...*models.Thread) {
+	ids := make([]int, len(threads))
+	for i, thread := range threads {
+		ids[i] = thread.ID
+	}
+
+	// Without stored mentions the content still renders as plain text
+	mentions, err := h.Mentions.FetchMentions(ids)
+	if err != nil {
+		log.Printf("Error fetching mentions: %v", err)
+	}
+	for _, thread := range threads {
+		thread.Tokens = models.TokenizeContent(thread.Content, mentions[thread.ID])
+	}
+}
+
+// Suggest usernames starting with a prefix, for mention autocomplete
+func (h *Handler) SuggestUsernames(c *gin.Context) {
+	prefix := c.Query("prefix")
+	if prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Prefix is required"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 25 {
+		limit = 10
+	}
+
+	usernames, err := h.Users.FetchUsernamesByPrefix(prefix, limit)
+	if err != nil {
+		log.Printf("Error suggesting usernames: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": usernames})
+}
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"backend/events"
+	"backend/models"
+	"backend/moderation"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Binds and validates a moderation rule from the request body
+func bindModerationRule(c *gin.Context) (models.ModerationRule, bool) {
+	var input struct {
+		Pattern string `json:"pattern"`
+		Kind    string `json:"kind"`
+		Action  string `json:"action"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return models.ModerationRule{}, false
+	}
+
+	rule := models.ModerationRule{Pattern: input.Pattern, Kind: input.Kind, Action: input.Action}
+	if err := moderation.ValidateRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return rule, false
+	}
+	return rule, true
+}
+
+// List every moderation rule
+func (h *Handler) GetModerationRules(c *gin.Context) {
+	rules, err := h.Moderation.FetchModerationRules()
+	if err != nil {
+		log.Printf("Error fetching moderation rules: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch moderation rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// Add a moderation rule
+func (h *Handler) CreateModerationRule(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	rule, ok := bindModerationRule(c)
+	if !ok {
+		return
+	}
+
+	ruleID, err := h.Moderation.CreateModerationRule(rule, admin.UserID)
+	if err != nil {
+		log.Printf("Error creating moderation rule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create moderation rule"})
+		return
+	}
+
+	log.Printf("Admin '%s' added %s rule %d (%s)", admin.Username, rule.Action, ruleID, rule.Kind)
+	c.JSON(http.StatusCreated, gin.H{"message": "Moderation rule created", "id": ruleID})
+}
+
+// Replace a moderation rule's pattern, kind and action
+func (h *Handler) UpdateModerationRule(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	rule, ok := bindModerationRule(c)
+	if !ok {
+		return
+	}
+	rule.ID = ruleID
+
+	found, err := h.Moderation.UpdateModerationRule(rule)
+	if err != nil {
+		log.Printf("Error updating moderation rule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update moderation rule"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Moderation rule not found"})
+		return
+	}
+
+	log.Printf("Admin '%s' updated moderation rule %d", admin.Username, ruleID)
+	c.JSON(http.StatusOK, gin.H{"message": "Moderation rule updated"})
+}
+
+// Remove a moderation rule
+func (h *Handler) DeleteModerationRule(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	found, err := h.Moderation.DeleteModerationRule(ruleID)
+	if err != nil {
+		log.Printf("Error deleting moderation rule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete moderation rule"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Moderation rule not found"})
+		return
+	}
+
+	log.Printf("Admin '%s' deleted moderation rule %d", admin.Username, ruleID)
+	c.JSON(http.StatusOK, gin.H{"message": "Moderation rule deleted"})
+}
+
+// List posts flagged for review, newest first
+func (h *Handler) GetModerationFlags(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	// Validate pagination inputs
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	flags, err := h.Moderation.FetchModerationFlags(limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("Error fetching moderation flags: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch flagged posts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"flags": flags, "currentPage": page})
+}
+
+// Mark a flagged post as reviewed
+func (h *Handler) ClearModerationFlags(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
+		return
+	}
+
+	found, err := h.Moderation.ClearModerationFlags(threadID)
+	if err != nil {
+		log.Printf("Error clearing moderation flags: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear flags"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread is not flagged"})
+		return
+	}
+
+	log.Printf("Admin '%s' cleared moderation flags on thread %d", admin.Username, threadID)
+	c.JSON(http.StatusOK, gin.H{"message": "Flags cleared"})
+}
+
+// Bring back a soft-deleted thread or comment
+func (h *Handler) RestoreThread(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
+		return
+	}
+
+	restored, err := h.Threads.RestoreThread(threadID)
+	if err != nil {
+		log.Printf("Error restoring thread %d: %v", threadID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore thread"})
+		return
+	}
+	if !restored {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No deleted thread with that ID"})
+		return
+	}
+	if thread, err := h.Threads.FetchThreadByID(threadID); err == nil && thread != nil {
+		thread.Redact()
+		h.tokenize(thread)
+		h.publish(threadID, events.ThreadEdited, thread)
+	}
+
+	log.Printf("Admin '%s' restored thread %d", admin.Username, threadID)
+	c.JSON(http.StatusOK, gin.H{"message": "Thread restored"})
+}
+
+// Permanently remove a thread or comment together with its replies
+func (h *Handler) PurgeThread(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
+		return
+	}
+
+	// Resolve the stream to notify before the thread is gone
+	rootID, rootErr := h.Threads.FetchRootThreadID(threadID)
+
+	purged, err := h.Threads.PurgeThread(threadID)
+	if err != nil {
+		log.Printf("Error purging thread %d: %v", threadID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge thread"})
+		return
+	}
+	if !purged {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+	if rootErr == nil {
+		h.Events.Publish(events.Event{Type: events.ThreadDeleted, ThreadID: rootID, Data: gin.H{"id": threadID}})
+	}
+
+	log.Printf("Admin '%s' purged thread %d", admin.Username, threadID)
+	c.JSON(http.StatusOK, gin.H{"message": "Thread purged"})
+}
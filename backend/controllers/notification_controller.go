@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"backend/models"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// List the caller's notifications, newest first
+func (h *Handler) GetNotifications(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	unreadOnly := c.Query("unread") == "true"
+
+	// Validate pagination inputs
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	notifications, err := h.Notifications.FetchNotifications(principal.UserID, unreadOnly, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("Error fetching notifications: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	unread, err := h.Notifications.CountUnreadNotifications(principal.UserID)
+	if err != nil {
+		log.Printf("Error counting notifications: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"unreadCount":   unread,
+		"currentPage":   page,
+	})
+}
+
+// Count the caller's unread notifications
+func (h *Handler) GetUnreadNotificationCount(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	unread, err := h.Notifications.CountUnreadNotifications(principal.UserID)
+	if err != nil {
+		log.Printf("Error counting notifications: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unreadCount": unread})
+}
+
+// Mark one of the caller's notifications as read
+func (h *Handler) MarkNotificationRead(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	notificationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	found, err := h.Notifications.MarkNotificationRead(principal.UserID, notificationID)
+	if err != nil {
+		log.Printf("Error marking notification read: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notification as read"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+// Mark all of the caller's notifications as read
+func (h *Handler) MarkAllNotificationsRead(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	updated, err := h.Notifications.MarkAllNotificationsRead(principal.UserID)
+	if err != nil {
+		log.Printf("Error marking notifications read: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All notifications marked as read", "updated": updated})
+}
+
+// Show which event types the caller receives
+func (h *Handler) GetNotificationPreferences(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	preferences, err := h.Notifications.FetchNotificationPreferences(principal.UserID)
+	if err != nil {
+		log.Printf("Error fetching notification preferences: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preferences": preferences})
+}
+
+// Turn event types on or off for the caller, e.g. {"reply": false}
+func (h *Handler) UpdateNotificationPreferences(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	var preferences map[string]bool
+	if err := c.ShouldBindJSON(&preferences); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	err := h.Notifications.UpdateNotificationPreferences(principal.UserID, preferences)
+	if err == models.ErrUnknownNotificationType {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification type", "types": models.NotificationTypes})
+		return
+	} else if err != nil {
+		log.Printf("Error updating notification preferences: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+		return
+	}
+
+	h.GetNotificationPreferences(c)
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"backend/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Report a thread or comment to the moderators
+func (h *Handler) ReportThread(c *gin.Context) {
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
+		return
+	}
+
+	principal, ok := actingUser(c, c.Query("username"))
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Reason  string  `json:"reason"`
+		Details *string `json:"details"` // Required for the "other" reason
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if !contains(models.ReportReasons, input.Reason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown report reason", "reasons": models.ReportReasons})
+		return
+	}
+	if input.Details != nil {
+		details := strings.TrimSpace(*input.Details)
+		input.Details = &details
+		if details == "" {
+			input.Details = nil
+		} else if sourceLength(details) > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Details must be no more than 500 characters long"})
+			return
+		}
+	}
+	if input.Reason == models.ReportOther && input.Details == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Details are required for this reason"})
+		return
+	}
+
+	thread, err := h.Threads.FetchThreadByID(threadID)
+	if err != nil {
+		log.Printf("Error fetching thread: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thread"})
+		return
+	}
+	// Removed posts have nothing left to moderate
+	if thread == nil || thread.Deleted || thread.Hidden {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+	if thread.UserID == principal.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot report your own post"})
+		return
+	}
+
+	reportID, err := h.Reports.CreateReport(threadID, principal.UserID, input.Reason, input.Details)
+	if err == models.ErrDuplicateReport {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reported this post"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to report thread (Thread ID: %d, User ID: %d): %v", threadID, principal.UserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Report submitted", "id": reportID})
+}
+
+// List reported posts, most reported first
+func (h *Handler) GetReportQueue(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	// Validate pagination inputs
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	items, err := h.Reports.FetchReportQueue(limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("Error fetching report queue: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items, "currentPage": page})
+}
+
+// Act on a reported post, closing its open reports
+func (h *Handler) ResolveReports(c *gin.Context) {
+	moderator, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
+		return
+	}
+
+	var input struct {
+		Action string  `json:"action"`
+		Note   *string `json:"note"` // Optional, for other moderators
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if !contains(models.ReportActions, input.Action) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown action", "actions": models.ReportActions})
+		return
+	}
+
+	action, err := h.Reports.ResolveReports(threadID, moderator.UserID, input.Action, input.Note)
+	if err == models.ErrThreadNotFound || err == models.ErrNoOpenReports {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No open reports for this post"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to resolve reports (Thread ID: %d): %v", threadID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve reports"})
+		return
+	}
+
+	// The decision is recorded; carry out what the store leaves to us
+	switch input.Action {
+	case models.ReportDelete:
+		// Already deleted by its author is as good as deleted here
+		if err := h.removeThread(threadID, moderator.UserID); err != nil && err != models.ErrThreadNotFound {
+			log.Printf("Failed to delete reported thread %d: %v", threadID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete thread"})
+			return
+		}
+	case models.ReportWarn:
+		if thread, err := h.Threads.FetchThreadByID(threadID); err == nil && thread != nil {
+			h.Notifier.Warning(thread.UserID, moderator.UserID, threadID)
+		}
+	}
+
+	log.Printf("Moderator '%s' resolved %d reports on thread %d: %s", moderator.Username, action.ReportsResolved, threadID, input.Action)
+	c.JSON(http.StatusOK, gin.H{"action": action})
+}
+
+// List moderator decisions, newest first
+func (h *Handler) GetModerationActions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	// Validate pagination inputs
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	actions, err := h.Reports.FetchModerationActions(limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("Error fetching moderation actions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch moderation actions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"actions": actions, "currentPage": page})
+}
+
+// Helper function to check a value against a list of codes
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"backend/diff"
+	"backend/events"
+	"backend/models"
+	"backend/moderation"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A revision with what the edit that replaced it changed
+type revisionEntry struct {
+	models.Revision
+	TitleDiff   []diff.Change `json:"titleDiff,omitempty"` // Set for threads
+	ContentDiff []diff.Change `json:"contentDiff"`
+}
+
+// List a thread's or comment's earlier versions, newest first, each with a
+// diff against the version that replaced it
+func (h *Handler) GetThreadRevisions(c *gin.Context) {
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
+		return
+	}
+
+	thread, err := h.Threads.FetchThreadByID(threadID)
+	if err != nil {
+		log.Printf("Error fetching thread: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thread"})
+		return
+	}
+	if thread == nil || thread.Hidden || thread.Deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+
+	revisions, err := h.Revisions.FetchRevisions(threadID)
+	if err != nil {
+		log.Printf("Error fetching revisions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+
+	// Walk back from the current version, diffing each revision against its successor
+	entries := make([]revisionEntry, len(revisions))
+	title, content := thread.Title, thread.Content
+	for i, revision := range revisions {
+		entries[i] = revisionEntry{Revision: revision, ContentDiff: diff.Words(revision.Content, content)}
+		if revision.Title != nil && title != nil {
+			entries[i].TitleDiff = diff.Words(*revision.Title, *title)
+		}
+		title, content = revision.Title, revision.Content
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": entries})
+}
+
+// Restore a thread or comment to one of its earlier versions
+func (h *Handler) RollbackThread(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
+		return
+	}
+	revisionID, err := strconv.Atoi(c.Param("revisionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision ID"})
+		return
+	}
+
+	// Restored text must pass the rules in force now, not those it was written under
+	revisions, err := h.Revisions.FetchRevisions(threadID)
+	if err != nil {
+		log.Printf("Error fetching revisions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+	index := slices.IndexFunc(revisions, func(r models.Revision) bool { return r.ID == revisionID })
+	if index < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	revision := revisions[index]
+	verdict, ok := h.moderateVerbatim(c,
+		moderation.Field{Name: "Title", Text: revision.Title},
+		moderation.Field{Name: "Content", Text: &revision.Content},
+	)
+	if !ok {
+		return
+	}
+	if len(verdict.Violations) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": verdict.Violations})
+		return
+	}
+
+	found, err := h.Revisions.RollbackThread(threadID, revisionID, admin.UserID)
+	if err == models.ErrDuplicateTitle {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Title must be unique"}})
+		return
+	} else if err != nil {
+		log.Printf("Error rolling back thread %d: %v", threadID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to roll back thread"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	h.Moderator.Flag(threadID, verdict)
+	if thread, err := h.Threads.FetchThreadByID(threadID); err == nil && thread != nil {
+		h.recordMentions(threadID, admin.UserID, thread.Content)
+		thread.Redact()
+		h.tokenize(thread)
+		h.publish(threadID, events.ThreadEdited, thread)
+	}
+
+	log.Printf("Admin '%s' rolled back thread %d to revision %d", admin.Username, threadID, revisionID)
+	c.JSON(http.StatusOK, gin.H{"message": "Thread rolled back"})
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"backend/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Search threads, comments and users with ranked, highlighted results
+func (h *Handler) Search(c *gin.Context) {
+	query := c.DefaultQuery("q", "")
+	types := c.DefaultQuery("type", "threads,comments,users")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	// Validate pagination inputs
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+	offset := (page - 1) * limit
+
+	tsQuery := models.BuildTSQuery(query)
+	if tsQuery == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query must contain at least one word"})
+		return
+	}
+
+	response := gin.H{"query": query, "currentPage": page}
+	for _, kind := range strings.Split(types, ",") {
+		var results []models.SearchResult
+		var err error
+		switch strings.TrimSpace(kind) {
+		case "threads":
+			results, err = h.Threads.SearchThreads(tsQuery, false, limit, offset)
+		case "comments":
+			results, err = h.Threads.SearchThreads(tsQuery, true, limit, offset)
+		case "users":
+			results, err = h.Users.SearchUsers(tsQuery, limit, offset)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown search type: " + kind})
+			return
+		}
+		if err != nil {
+			log.Printf("Error searching %s: %v", kind, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search " + kind})
+			return
+		}
+		response[strings.TrimSpace(kind)] = results
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,363 @@
+package controllers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Subscribes the author to their new thread and tells the followers of its
+// category about it
+func (h *Handler) announceThread(threadID, authorID, categoryID int) {
+	if err := h.Subscriptions.SubscribeThread(authorID, threadID); err != nil {
+		log.Printf("Failed to subscribe user %d to thread %d: %v", authorID, threadID, err)
+	}
+	subscribers, err := h.Subscriptions.FetchCategorySubscribers(categoryID)
+	if err != nil {
+		log.Printf("Failed to fetch subscribers of category %d: %v", categoryID, err)
+		return
+	}
+	h.Notifier.CategoryPost(subscribers, authorID, threadID)
+}
+
+// Tells the followers of a thread about a new comment in it, then subscribes
+// the commenter. The parent's author is skipped as they get a reply
+// notification already.
+func (h *Handler) announceComment(commentID, authorID, parentAuthorID int) {
+	rootID, err := h.Threads.FetchRootThreadID(commentID)
+	if err != nil {
+		log.Printf("Failed to find the thread of comment %d: %v", commentID, err)
+		return
+	}
+	subscribers, err := h.Subscriptions.FetchThreadSubscribers(rootID)
+	if err != nil {
+		log.Printf("Failed to fetch subscribers of thread %d: %v", rootID, err)
+		return
+	}
+	recipients := subscribers[:0]
+	for _, userID := range subscribers {
+		if userID != parentAuthorID {
+			recipients = append(recipients, userID)
+		}
+	}
+	h.Notifier.ThreadReply(recipients, authorID, commentID)
+
+	if err := h.Subscriptions.SubscribeThread(authorID, rootID); err != nil {
+		log.Printf("Failed to subscribe user %d to thread %d: %v", authorID, rootID, err)
+	}
+}
+
+// Resolves the :id parameter to the visible top-level thread it belongs to.
+// Responds with an error and returns false if there is none.
+func (h *Handler) subscriptionTarget(c *gin.Context) (int, bool) {
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
+		return 0, false
+	}
+	rootID, err := h.Threads.FetchRootThreadID(threadID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return 0, false
+	} else if err != nil {
+		log.Printf("Error fetching thread: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thread"})
+		return 0, false
+	}
+	thread, err := h.Threads.FetchThreadByID(rootID)
+	if err != nil {
+		log.Printf("Error fetching thread: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thread"})
+		return 0, false
+	}
+	if thread == nil || thread.Deleted || thread.Hidden {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return 0, false
+	}
+	return rootID, true
+}
+
+// Follow a thread for new comments; comments resolve to their thread
+func (h *Handler) SubscribeThread(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+	threadID, ok := h.subscriptionTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Subscriptions.SubscribeThread(principal.UserID, threadID); err != nil {
+		log.Printf("Error subscribing to thread: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe to thread"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Subscribed to thread", "threadId": threadID})
+}
+
+// Stop following a thread
+func (h *Handler) UnsubscribeThread(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
+		return
+	}
+	if rootID, err := h.Threads.FetchRootThreadID(threadID); err == nil {
+		threadID = rootID
+	}
+
+	found, err := h.Subscriptions.UnsubscribeThread(principal.UserID, threadID)
+	if err != nil {
+		log.Printf("Error unsubscribing from thread: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe from thread"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed from thread"})
+}
+
+// Mark everything in a subscribed thread as read
+func (h *Handler) MarkThreadRead(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+	threadID, ok := h.subscriptionTarget(c)
+	if !ok {
+		return
+	}
+
+	found, err := h.Subscriptions.MarkThreadRead(principal.UserID, threadID)
+	if err != nil {
+		log.Printf("Error marking thread read: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark thread read"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Thread marked as read"})
+}
+
+// Follow a category, and every category below it, for new threads
+func (h *Handler) SubscribeCategory(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	found, err := h.Subscriptions.SubscribeCategory(principal.UserID, categoryID)
+	if err != nil {
+		log.Printf("Error subscribing to category: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe to category"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Subscribed to category"})
+}
+
+// Stop following a category
+func (h *Handler) UnsubscribeCategory(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	found, err := h.Subscriptions.UnsubscribeCategory(principal.UserID, categoryID)
+	if err != nil {
+		log.Printf("Error unsubscribing from category: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe from category"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed from category"})
+}
+
+// Follow a tag to see its threads in the feed
+func (h *Handler) SubscribeTag(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+	tagID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	found, err := h.Subscriptions.SubscribeTag(principal.UserID, tagID)
+	if err != nil {
+		log.Printf("Error subscribing to tag: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe to tag"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Subscribed to tag"})
+}
+
+// Stop following a tag
+func (h *Handler) UnsubscribeTag(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+	tagID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	found, err := h.Subscriptions.UnsubscribeTag(principal.UserID, tagID)
+	if err != nil {
+		log.Printf("Error unsubscribing from tag: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe from tag"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed from tag"})
+}
+
+// Follow a user to see the threads they start in the feed
+func (h *Handler) FollowUser(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+	followeeID, err := h.Users.GetUserIDFromUsername(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if followeeID == principal.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot follow yourself"})
+		return
+	}
+
+	if err := h.Subscriptions.FollowUser(principal.UserID, followeeID); err != nil {
+		log.Printf("Error following user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Following " + c.Param("username")})
+}
+
+// Stop following a user
+func (h *Handler) UnfollowUser(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+	followeeID, err := h.Users.GetUserIDFromUsername(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	found, err := h.Subscriptions.UnfollowUser(principal.UserID, followeeID)
+	if err != nil {
+		log.Printf("Error unfollowing user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow user"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You do not follow this user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unfollowed " + c.Param("username")})
+}
+
+// Retrieves the caller's followed threads, most recently active first with
+// their unread counts, along with the categories, tags and users they follow
+func (h *Handler) GetUserSubscriptions(c *gin.Context) {
+	principal, ok := actingUser(c, c.Param("username"))
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	// Validate pagination inputs
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	threads, err := h.Subscriptions.FetchThreadSubscriptions(principal.UserID, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("Error fetching thread subscriptions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subscriptions"})
+		return
+	}
+	categories, err := h.Subscriptions.FetchCategorySubscriptions(principal.UserID)
+	if err != nil {
+		log.Printf("Error fetching category subscriptions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subscriptions"})
+		return
+	}
+	tags, err := h.Subscriptions.FetchTagSubscriptions(principal.UserID)
+	if err != nil {
+		log.Printf("Error fetching tag subscriptions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subscriptions"})
+		return
+	}
+	following, err := h.Subscriptions.FetchFollowedUsers(principal.UserID)
+	if err != nil {
+		log.Printf("Error fetching followed users: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subscriptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"threads":     threads,
+		"categories":  categories,
+		"tags":        tags,
+		"following":   following,
+		"currentPage": page,
+	})
+}
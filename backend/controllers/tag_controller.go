@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"backend/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Helper function to read and validate a tag name from the request body
+func bindTagName(c *gin.Context) (string, bool) {
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return "", false
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name is required"})
+		return "", false
+	}
+	if utf8.RuneCountInString(name) > models.MaxTagLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Tags must be no more than %d characters long", models.MaxTagLength)})
+		return "", false
+	}
+	return name, true
+}
+
+// Add a tag to the list threads pick from
+func (h *Handler) CreateTag(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	name, ok := bindTagName(c)
+	if !ok {
+		return
+	}
+
+	tagID, err := h.Tags.CreateTag(name)
+	if err == models.ErrDuplicateTag {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		log.Printf("Error creating tag: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag"})
+		return
+	}
+
+	log.Printf("Admin '%s' created tag %d (%s)", admin.Username, tagID, name)
+	c.JSON(http.StatusCreated, gin.H{"message": "Tag created", "id": tagID})
+}
+
+// Rename a tag on every thread carrying it
+func (h *Handler) RenameTag(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	tagID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	name, ok := bindTagName(c)
+	if !ok {
+		return
+	}
+
+	found, err := h.Tags.RenameTag(tagID, name)
+	if err == models.ErrDuplicateTag {
+		c.JSON(http.StatusConflict, gin.H{"error": "A tag with this name already exists; merge the tags instead"})
+		return
+	} else if err != nil {
+		log.Printf("Error renaming tag: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename tag"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	log.Printf("Admin '%s' renamed tag %d to '%s'", admin.Username, tagID, name)
+	c.JSON(http.StatusOK, gin.H{"message": "Tag renamed"})
+}
+
+// Move every thread from one tag into another and remove the first
+func (h *Handler) MergeTags(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	tagID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	var input struct {
+		Into int `json:"into"` // The tag that remains
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.Into == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if input.Into == tagID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A tag cannot be merged into itself"})
+		return
+	}
+
+	found, err := h.Tags.MergeTags(tagID, input.Into)
+	if err != nil {
+		log.Printf("Error merging tags: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge tags"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	log.Printf("Admin '%s' merged tag %d into %d", admin.Username, tagID, input.Into)
+	c.JSON(http.StatusOK, gin.H{"message": "Tags merged"})
+}
+
+// Remove a tag from every thread and from the tag list
+func (h *Handler) DeleteTag(c *gin.Context) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	tagID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return
+	}
+
+	found, err := h.Tags.DeleteTag(tagID)
+	if err != nil {
+		log.Printf("Error deleting tag: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	log.Printf("Admin '%s' deleted tag %d", admin.Username, tagID)
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted"})
+}
@@ -1,24 +1,34 @@
 package controllers
 
 import (
+	"backend/events"
 	"backend/models"
+	"backend/moderation"
 	"database/sql"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Retrieves threads with optional filters for category, and tag
-func GetThreads(c *gin.Context, db *sql.DB) {
+// Retrieves threads with optional filters for category, and tags. Tags come
+// as repeated tag parameters or a comma-separated tags list; tagMatch=all
+// requires every one of them instead of any.
+func (h *Handler) GetThreads(c *gin.Context) {
 	// Extract query parameters
-	query := c.DefaultQuery("query", "")
-	sortBy := c.DefaultQuery("sortBy", "created_at")
+	listing := models.ThreadListing{
+		Query:    c.DefaultQuery("query", ""),
+		SortBy:   c.DefaultQuery("sortBy", "created_at"),
+		Window:   c.Query("window"),
+		Order:    c.Query("order"),
+		Tags:     append(c.QueryArray("tag"), strings.Split(c.Query("tags"), ",")...),
+		TagMatch: c.Query("tagMatch"),
+		Category: c.Query("category"),
+	}
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	category := c.Query("category")
-	tag := c.Query("tag")
 
 	// Validate pagination inputs
 	if page < 1 {
@@ -29,16 +39,25 @@ func GetThreads(c *gin.Context, db *sql.DB) {
 	}
 	offset := (page - 1) * limit
 
+	// Cursor mode skips the total count; page numbers remain the default
+	if cursorToken := c.Query("cursor"); cursorToken != "" || c.Query("paginate") == "cursor" {
+		h.getThreadsByCursor(c, cursorToken, listing, limit)
+		return
+	}
+
 	// Fetch threads with the appropriate filters
-	threads, err := models.FetchThreads(db, query, sortBy, limit, offset, tag, category)
-	if err != nil {
+	threads, err := h.Threads.FetchThreads(listing, limit, offset)
+	if err == models.ErrInvalidWindow || err == models.ErrInvalidOrder || err == models.ErrInvalidMatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
 		log.Printf("Error fetching threads: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch threads"})
 		return
 	}
 
 	// Count threads for pagination
-	totalCount := models.GetThreadCount(db, query, tag, category)
+	totalCount := h.Threads.GetThreadCount(listing)
 	totalPages := (totalCount + limit - 1) / limit
 
 	// Check if no threads are returned
@@ -59,8 +78,58 @@ func GetThreads(c *gin.Context, db *sql.DB) {
 	})
 }
 
+// Responds with one keyset-paginated page of threads
+func (h *Handler) getThreadsByCursor(c *gin.Context, cursorToken string, listing models.ThreadListing, limit int) {
+	var cursor *models.ThreadCursor
+	if cursorToken != "" {
+		var err error
+		cursor, err = models.DecodeCursor(cursorToken)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
+
+	threads, next, prev, err := h.Threads.FetchThreadsByCursor(listing, limit, cursor)
+	if err == models.ErrCursorMismatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor does not match the requested sort order"})
+		return
+	} else if err == models.ErrCursorUnsupportedSort {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor pagination does not support sorting by " + listing.SortBy})
+		return
+	} else if err == models.ErrInvalidWindow || err == models.ErrInvalidOrder || err == models.ErrInvalidMatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		log.Printf("Error fetching threads: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch threads"})
+		return
+	}
+
+	// Pinned threads come apart from the first page so it keeps to the limit
+	pinned := []models.Thread{}
+	if prev == "" && (cursor == nil || cursor.Backward) {
+		pinned, err = h.Threads.FetchPinnedThreads(listing)
+		if err != nil {
+			log.Printf("Error fetching pinned threads: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch threads"})
+			return
+		}
+	}
+
+	// Missing cursors are returned as null
+	response := gin.H{"threads": threads, "pinned": pinned, "nextCursor": nil, "prevCursor": nil}
+	if next != "" {
+		response["nextCursor"] = next
+	}
+	if prev != "" {
+		response["prevCursor"] = prev
+	}
+	c.JSON(http.StatusOK, response)
+}
+
 // Check thread authorization
-func GetThreadAuthorization(c *gin.Context, db *sql.DB) {
+func (h *Handler) GetThreadAuthorization(c *gin.Context) {
 	// Get username from query
 	username := c.DefaultQuery("username", "")
 
@@ -70,7 +139,7 @@ func GetThreadAuthorization(c *gin.Context, db *sql.DB) {
 	}
 
 	// Fetch user ID
-	userID, err := models.GetUserIDFromUsername(db, username)
+	userID, err := h.Users.GetUserIDFromUsername(username)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid userID"})
 		return
@@ -84,7 +153,7 @@ func GetThreadAuthorization(c *gin.Context, db *sql.DB) {
 	}
 
 	// Check if the user is authorized (owner or admin)
-	authorized := models.CheckThreadOwnershipOrAdmin(db, username, userID, threadID)
+	authorized := h.Users.CheckThreadOwnershipOrAdmin(username, userID, threadID)
 
 	// If the user is not authorized
 	if !authorized {
@@ -96,7 +165,7 @@ func GetThreadAuthorization(c *gin.Context, db *sql.DB) {
 }
 
 // view single thread and ALL its child comments (Recursive)
-func GetThreadDetails(c *gin.Context, db *sql.DB) {
+func (h *Handler) GetThreadDetails(c *gin.Context) {
 	// Get thread ID from URL parameters
 	threadID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -105,27 +174,46 @@ func GetThreadDetails(c *gin.Context, db *sql.DB) {
 	}
 
 	// Fetch thread details
-	thread, err := models.FetchThreadByID(db, threadID)
+	thread, err := h.Threads.FetchThreadByID(threadID)
 	if err != nil {
 		log.Printf("Error fetching thread: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thread"})
 		return
 	}
-	if thread == nil {
+	if thread == nil || thread.Hidden {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
 		return
 	}
 
-	// Fetch comments
+	// Nested replies, paged per sibling group; searching keeps the flat list
 	query := c.DefaultQuery("query", "")
+	if c.Query("format") == "tree" && query == "" {
+		h.getThreadTree(c, thread)
+		return
+	}
+
+	// Fetch comments
 	sortBy := c.DefaultQuery("sortBy", "created_at")
-	comments, err := models.FetchCommentsByThreadID(db, threadID, query, sortBy)
+	comments, err := h.Threads.FetchCommentsByThreadID(threadID, query, sortBy)
 	if err != nil {
 		log.Printf("Error fetching comments: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
 		return
 	}
 
+	// A deleted post stays up as a tombstone only while replies hang off it
+	if thread.Deleted && len(comments) == 0 && query == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+	thread.Redact()
+
+	posts := []*models.Thread{thread}
+	for i := range comments {
+		posts = append(posts, &comments[i])
+	}
+	h.tokenize(posts...)
+
 	//Return empty array instead of null
 	if len(comments) == 0 {
 		c.JSON(http.StatusOK, gin.H{
@@ -142,10 +230,11 @@ func GetThreadDetails(c *gin.Context, db *sql.DB) {
 	})
 }
 
-// GetCategories handles the request to fetch all categories
-func GetCategories(c *gin.Context, db *sql.DB) {
+// GetCategories handles the request to fetch all categories as a tree of
+// sub-forums, with thread counts and latest activity
+func (h *Handler) GetCategories(c *gin.Context) {
 	// Call the model to fetch categories
-	categories, err := models.FetchCategories(db)
+	categories, err := h.Categories.FetchCategoryTree()
 	if err != nil {
 		log.Printf("Error fetching categories: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
@@ -157,9 +246,9 @@ func GetCategories(c *gin.Context, db *sql.DB) {
 }
 
 // GetCategories handles the request to fetch all tags
-func GetTags(c *gin.Context, db *sql.DB) {
+func (h *Handler) GetTags(c *gin.Context) {
 	// Call the model to fetch tags
-	tags, err := models.FetchTags(db)
+	tags, err := h.Threads.FetchTags()
 	if err != nil {
 		log.Printf("Error fetching tags: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags"})
@@ -171,14 +260,15 @@ func GetTags(c *gin.Context, db *sql.DB) {
 }
 
 // Create a thread (+ Tags + Category)
-func CreateThread(c *gin.Context, db *sql.DB) {
+func (h *Handler) CreateThread(c *gin.Context) {
 	// Bind the incoming JSON request to the struct for thread data
 	var requestBody struct {
-		Username string  `json:"username"` // Add username to the request body
-		Title    *string `json:"title"`
-		Content  *string `json:"content"`
-		Category string  `json:"category"`
-		Tag      string  `json:"tag"`
+		Username string   `json:"username"` // Optional, must match the token
+		Title    *string  `json:"title"`
+		Content  *string  `json:"content"`
+		Category string   `json:"category"`
+		Tag      string   `json:"tag"`  // A single tag, as older clients send it
+		Tags     []string `json:"tags"` // Up to models.MaxTagsPerThread, joined with Tag
 	}
 
 	if err := c.ShouldBindJSON(&requestBody); err != nil {
@@ -186,16 +276,21 @@ func CreateThread(c *gin.Context, db *sql.DB) {
 		return
 	}
 
-	// Check if username is provided
-	if requestBody.Username == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Username is required"})
+	// Resolve the author from the verified token
+	principal, ok := actingUser(c, requestBody.Username)
+	if !ok {
 		return
 	}
 
-	// Validate and get the user ID using the helper function
-	userID, err := models.GetUserIDFromUsername(db, requestBody.Username)
+	// Categories are looked up by name or slug; some only take admins' threads
+	category, err := h.Categories.FetchCategory(requestBody.Category)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username"})
+		log.Printf("Error fetching category: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create thread"})
+		return
+	}
+	if category != nil && category.Posting == models.PostingAdmins && !principal.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can post in this category"})
 		return
 	}
 
@@ -208,31 +303,57 @@ func CreateThread(c *gin.Context, db *sql.DB) {
 		Content: requestBody.Content,
 	}
 
-	errors := validateThread(db, false, &thread)
+	// Moderate first so that the title is checked for uniqueness as it will be stored
+	tags := models.CleanTags(append([]string{requestBody.Tag}, requestBody.Tags...))
+	fields := []moderation.Field{
+		{Name: "Title", Text: requestBody.Title},
+		{Name: "Content", Text: requestBody.Content},
+	}
+	for i := range tags {
+		fields = append(fields, moderation.Field{Name: "Tag", Text: &tags[i]})
+	}
+	verdict, ok := h.moderate(c, fields...)
+	if !ok {
+		return
+	}
+	errors := validateThread(h.Threads, false, &thread)
+	if category == nil {
+		errors = append(errors, "Category must be an existing category")
+	}
+	errors = append(errors, validateTags(tags)...)
+	errors = append(errors, verdict.Violations...)
 	if len(errors) > 0 {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": errors})
 		return
 	}
 
 	// Use the model function to create the thread
-	err = models.CreateThread(db, requestBody.Title, requestBody.Content, userID, requestBody.Category, requestBody.Tag)
-	if err != nil {
+	// New tags may be restricted to admins, leaving others to pick existing ones
+	createTags := !h.RestrictTagCreation || principal.IsAdmin
+	threadID, err := h.Threads.CreateThread(requestBody.Title, requestBody.Content, principal.UserID, category.ID, tags, createTags)
+	if err == models.ErrUnknownTag {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": []string{"Tags must be chosen from the existing ones"}})
+		return
+	} else if err == models.ErrDuplicateTitle {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": []string{"Title must be unique"}})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create thread"})
 		return
 	}
+	h.Moderator.Flag(threadID, verdict)
+	h.recordMentions(threadID, principal.UserID, *requestBody.Content)
+	h.announceThread(threadID, principal.UserID, category.ID)
 
 	// Return a success message
-	c.JSON(http.StatusCreated, gin.H{"message": "Thread created successfully!"})
+	c.JSON(http.StatusCreated, gin.H{"message": "Thread created successfully!", "id": threadID})
 }
 
 // Update a thread
-func UpdateThread(c *gin.Context, db *sql.DB) {
-	username := c.DefaultQuery("username", "")
-
-	// Validate user
-	userID, err := models.GetUserIDFromUsername(db, username)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to validate user"})
+func (h *Handler) UpdateThread(c *gin.Context) {
+	// Resolve the editor from the verified token
+	principal, ok := actingUser(c, c.Query("username"))
+	if !ok {
 		return
 	}
 
@@ -244,7 +365,7 @@ func UpdateThread(c *gin.Context, db *sql.DB) {
 	}
 
 	// Check ownership or admin privileges
-	authorized := models.CheckThreadOwnershipOrAdmin(db, username, userID, threadID)
+	authorized := h.Users.CheckThreadOwnershipOrAdmin(principal.Username, principal.UserID, threadID)
 	if !authorized {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to edit this thread"})
 		return
@@ -261,41 +382,61 @@ func UpdateThread(c *gin.Context, db *sql.DB) {
 	}
 
 	// Check if it's a comment
-	var existingTitle sql.NullString
-	err = db.QueryRow(`SELECT title FROM threads WHERE id = $1`, threadID).Scan(&existingTitle)
+	existing, err := h.Threads.FetchThreadByID(threadID)
 	if err != nil {
+		log.Printf("Error fetching thread: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thread"})
+		return
+	}
+	if existing == nil || existing.Deleted {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
 		return
 	}
 
-	isComment := !existingTitle.Valid
+	isComment := existing.Title == nil
 
-	// Validate input
-	errors := validateThread(db, !isComment, &threadUpdate)
+	// Validate input as it will be stored, after masking
+	verdict, ok := h.moderate(c,
+		moderation.Field{Name: "Title", Text: threadUpdate.Title},
+		moderation.Field{Name: "Content", Text: threadUpdate.Content},
+	)
+	if !ok {
+		return
+	}
+	errors := validateThread(h.Threads, !isComment, &threadUpdate)
+	errors = append(errors, verdict.Violations...)
 	if len(errors) > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
 		return
 	}
 
 	// Execute the update using the model
-	err = models.UpdateThread(db, threadID, threadUpdate.Title, threadUpdate.Content)
-	if err != nil {
+	err = h.Threads.UpdateThread(threadID, principal.UserID, threadUpdate.Title, threadUpdate.Content)
+	if err == models.ErrDuplicateTitle {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []string{"Title must be unique"}})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update thread"})
 		return
 	}
+	h.Moderator.Flag(threadID, verdict)
+	if threadUpdate.Content != nil {
+		h.recordMentions(threadID, principal.UserID, *threadUpdate.Content)
+	}
+	if updated, err := h.Threads.FetchThreadByID(threadID); err == nil && updated != nil {
+		updated.Redact()
+		h.tokenize(updated)
+		h.publish(threadID, events.ThreadEdited, updated)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Thread updated successfully"})
 }
 
 // Delete a thread
-func DeleteThread(c *gin.Context, db *sql.DB) {
-	// Get the username from the query or header
-	username := c.DefaultQuery("username", "")
-
-	// Validate and get the user ID using the helper function
-	userID, err := models.GetUserIDFromUsername(db, username)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get User ID"})
+func (h *Handler) DeleteThread(c *gin.Context) {
+	// Resolve the acting user from the verified token
+	principal, ok := actingUser(c, c.Query("username"))
+	if !ok {
 		return
 	}
 
@@ -307,15 +448,18 @@ func DeleteThread(c *gin.Context, db *sql.DB) {
 	}
 
 	// Check ownership or admin status
-	authorized := models.CheckThreadOwnershipOrAdmin(db, username, userID, threadID)
+	authorized := h.Users.CheckThreadOwnershipOrAdmin(principal.Username, principal.UserID, threadID)
 	if !authorized {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to delete this thread"})
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to delete this thread"})
 		return
 	}
 
-	// Delete the thread from the database
-	err = models.DeleteThread(db, threadID)
-	if err != nil {
+	// Leave a tombstone in place of the thread
+	err = h.removeThread(threadID, principal.UserID)
+	if err == models.ErrThreadNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete thread"})
 		return
 	}
@@ -323,23 +467,27 @@ func DeleteThread(c *gin.Context, db *sql.DB) {
 	c.JSON(http.StatusOK, gin.H{"message": "Thread deleted!"})
 }
 
-// Create a comment as a thread
-func CommentThread(c *gin.Context, db *sql.DB) {
-	// Get thread ID and username
-	threadIDStr := c.Param("id")
-	username := c.DefaultQuery("username", "")
+// Soft-deletes a thread or comment, keeping its replies, and tells anyone watching
+func (h *Handler) removeThread(threadID, deletedBy int) error {
+	if err := h.Threads.DeleteThread(threadID, deletedBy); err != nil {
+		return err
+	}
+	h.publish(threadID, events.ThreadDeleted, gin.H{"id": threadID})
+	return nil
+}
 
+// Create a comment as a thread
+func (h *Handler) CommentThread(c *gin.Context) {
 	// Parse thread ID
-	threadID, err := strconv.Atoi(threadIDStr)
+	threadID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
 		return
 	}
 
-	// Validate username and get user ID
-	userID, err := models.GetUserIDFromUsername(db, username)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid username"})
+	// Resolve the commenter from the verified token
+	principal, ok := actingUser(c, c.Query("username"))
+	if !ok {
 		return
 	}
 
@@ -353,14 +501,18 @@ func CommentThread(c *gin.Context, db *sql.DB) {
 	}
 
 	errors := validateComment(&comment)
+	verdict, ok := h.moderate(c, moderation.Field{Name: "Content", Text: comment.Content})
+	if !ok {
+		return
+	}
+	errors = append(errors, verdict.Violations...)
 	if len(errors) > 0 {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": errors})
 		return
 	}
 
 	// Get parent depth
-	var parentDepth int
-	err = db.QueryRow(`SELECT depth FROM threads WHERE id = $1`, threadID).Scan(&parentDepth)
+	parentDepth, err := h.Threads.GetThreadDepth(threadID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
@@ -369,11 +521,34 @@ func CommentThread(c *gin.Context, db *sql.DB) {
 		}
 		return
 	}
+	parent, err := h.Threads.FetchThreadByID(threadID)
+	if err != nil {
+		log.Printf("Error fetching thread: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thread"})
+		return
+	}
+	if parent == nil || parent.Deleted || parent.Hidden {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+	// Nothing can be added anywhere below a hidden or deleted thread
+	root, ok := h.fetchRootThread(c, threadID)
+	if !ok {
+		return
+	}
+	if root.Deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+	// Admins may still leave a closing note on a locked thread
+	if !principal.IsAdmin && !h.checkUnlocked(c, threadID) {
+		return
+	}
 
 	// Use the model to create the comment
-	err = models.CreateComment(db, *comment.Content, userID, threadID, parentDepth+1)
+	commentID, err := h.Threads.CreateComment(*comment.Content, principal.UserID, threadID, parentDepth+1)
 	if err != nil {
-		if err.Error() == "maximum nesting depth reached" {
+		if err == models.ErrMaxDepthReached {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Maximum nesting depth reached"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
@@ -381,6 +556,16 @@ func CommentThread(c *gin.Context, db *sql.DB) {
 		return
 	}
 
+	// Let the parent's author know, then anyone mentioned and the thread's followers
+	h.Notifier.Reply(parent.UserID, principal.UserID, commentID)
+	h.Moderator.Flag(commentID, verdict)
+	h.recordMentions(commentID, principal.UserID, *comment.Content)
+	h.announceComment(commentID, principal.UserID, parent.UserID)
+	if created, err := h.Threads.FetchThreadByID(commentID); err == nil && created != nil {
+		h.tokenize(created)
+		h.publish(commentID, events.CommentCreated, created)
+	}
+
 	// Respond with success
-	c.JSON(http.StatusCreated, gin.H{"message": "Comment created successfully!"})
+	c.JSON(http.StatusCreated, gin.H{"message": "Comment created successfully!", "id": commentID})
 }
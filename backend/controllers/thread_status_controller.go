@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"backend/events"
+	"backend/models"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Helper function to apply an admin control to the thread in the path and
+// tell the thread's watchers about it
+func (h *Handler) setThreadStatus(c *gin.Context, change string, apply func(threadID int) (bool, error)) {
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	threadID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thread ID"})
+		return
+	}
+
+	found, err := apply(threadID)
+	if err != nil {
+		log.Printf("Error changing thread %d (%s): %v", threadID, change, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update thread"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+	if thread, err := h.Threads.FetchThreadByID(threadID); err == nil && thread != nil {
+		thread.Redact()
+		h.tokenize(thread)
+		h.publish(threadID, events.ThreadEdited, thread)
+	}
+
+	log.Printf("Admin '%s' changed thread %d: %s", admin.Username, threadID, change)
+	c.JSON(http.StatusOK, gin.H{"message": "Thread " + change})
+}
+
+// Pin a thread to the top of every listing, or of its category's listing
+// with {"scope": "category"}
+func (h *Handler) PinThread(c *gin.Context) {
+	var input struct {
+		Scope string `json:"scope"` // global (default) or category
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+	switch input.Scope {
+	case "":
+		input.Scope = models.PinGlobal
+	case models.PinGlobal, models.PinCategory:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Scope must be global or category"})
+		return
+	}
+
+	h.setThreadStatus(c, "pinned", func(threadID int) (bool, error) {
+		return h.ThreadStatus.PinThread(threadID, input.Scope)
+	})
+}
+
+// Let a pinned thread fall back into place
+func (h *Handler) UnpinThread(c *gin.Context) {
+	h.setThreadStatus(c, "unpinned", func(threadID int) (bool, error) {
+		return h.ThreadStatus.PinThread(threadID, "")
+	})
+}
+
+// Close a thread to new replies and votes
+func (h *Handler) LockThread(c *gin.Context) {
+	h.setThreadStatus(c, "locked", func(threadID int) (bool, error) {
+		return h.ThreadStatus.LockThread(threadID, true)
+	})
+}
+
+// Reopen a locked thread
+func (h *Handler) UnlockThread(c *gin.Context) {
+	h.setThreadStatus(c, "unlocked", func(threadID int) (bool, error) {
+		return h.ThreadStatus.LockThread(threadID, false)
+	})
+}
+
+// Mark a thread as an announcement
+func (h *Handler) MarkAnnouncement(c *gin.Context) {
+	h.setThreadStatus(c, "marked as an announcement", func(threadID int) (bool, error) {
+		return h.ThreadStatus.SetAnnouncement(threadID, true)
+	})
+}
+
+// Clear a thread's announcement mark
+func (h *Handler) UnmarkAnnouncement(c *gin.Context) {
+	h.setThreadStatus(c, "no longer an announcement", func(threadID int) (bool, error) {
+		return h.ThreadStatus.SetAnnouncement(threadID, false)
+	})
+}
+
+// Helper function to turn away replies and votes on locked threads.
+// Responds and returns false if the post's thread is locked.
+func (h *Handler) checkUnlocked(c *gin.Context, threadID int) bool {
+	locked, err := h.ThreadStatus.IsThreadLocked(threadID)
+	if err != nil {
+		log.Printf("Error checking whether thread %d is locked: %v", threadID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check thread status"})
+		return false
+	}
+	if locked {
+		c.JSON(http.StatusForbidden, gin.H{"error": models.ErrThreadLocked.Error()})
+		return false
+	}
+	return true
+}
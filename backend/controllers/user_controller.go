@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"backend/middleware"
 	"backend/models"
+	"backend/moderation"
 	"database/sql"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -15,7 +18,7 @@ type PasswordChangeRequest struct {
 }
 
 // Register a new user
-func Register(c *gin.Context, db *sql.DB) {
+func (h *Handler) Register(c *gin.Context) {
 	type UserInput struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
@@ -28,8 +31,18 @@ func Register(c *gin.Context, db *sql.DB) {
 		return
 	}
 
+	// Usernames are shown as chosen, so masked words refuse them too
+	verdict, ok := h.moderateVerbatim(c, moderation.Field{Name: "Username", Text: &input.Username})
+	if !ok {
+		return
+	}
+	if len(verdict.Violations) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": verdict.Violations})
+		return
+	}
+
 	// Check if the username already exists
-	exists, err := models.CheckUsernameExists(db, input.Username)
+	exists, err := h.Users.CheckUsernameExists(input.Username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
@@ -47,7 +60,7 @@ func Register(c *gin.Context, db *sql.DB) {
 	}
 
 	// Insert the new user into the database
-	err = models.CreateUser(db, input.Username, string(hashedPassword))
+	err = h.Users.CreateUser(input.Username, string(hashedPassword))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
@@ -57,7 +70,7 @@ func Register(c *gin.Context, db *sql.DB) {
 }
 
 // Log in a user by username and generate JWT
-func Login(c *gin.Context, db *sql.DB) {
+func (h *Handler) Login(c *gin.Context) {
 	type LoginInput struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
@@ -72,7 +85,7 @@ func Login(c *gin.Context, db *sql.DB) {
 
 	// Retrieve the user from the database
 	var hashedPassword string
-	hashedPassword, err := models.GetPassword(db, input.Username)
+	hashedPassword, err := h.Users.GetPassword(input.Username)
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
 		return
@@ -88,24 +101,102 @@ func Login(c *gin.Context, db *sql.DB) {
 		return
 	}
 
-	// Generate JWT token for the authenticated user
-	token, err := generateJWT(input.Username)
+	userID, err := h.Users.GetUserIDFromUsername(input.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database"})
+		return
+	}
+
+	// Generate access and refresh tokens for the authenticated user
+	tokens, err := issueTokens(h.Users, userID, input.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Exchange a refresh token for a new access and refresh token pair
+func (h *Handler) RefreshToken(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token is required"})
+		return
+	}
+
+	newRefreshToken, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	username, err := h.Users.RotateRefreshToken(hashToken(input.RefreshToken), hashToken(newRefreshToken), refreshTokenTTL)
+	if err == models.ErrRefreshTokenReused {
+		log.Printf("Refresh token reuse detected; all sessions revoked")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used"})
+		return
+	} else if err == models.ErrRefreshTokenInvalid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	accessToken, err := generateJWT(username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, gin.H{
+		"token":        accessToken,
+		"refreshToken": newRefreshToken,
+		"expiresIn":    int(accessTokenTTL.Seconds()),
+	})
+}
+
+// Revoke the current access token and, if given, its refresh token
+func (h *Handler) Logout(c *gin.Context) {
+	principal, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	// The body is optional
+	_ = c.ShouldBindJSON(&input)
+
+	if input.RefreshToken != "" {
+		if err := h.Users.RevokeRefreshToken(principal.UserID, hashToken(input.RefreshToken)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+	}
+
+	if token, ok := middleware.CurrentToken(c); ok {
+		if err := h.Users.RevokeAccessToken(token.JTI, token.ExpiresAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
-func GetAuthorization(c *gin.Context, db *sql.DB) {
+func (h *Handler) GetAuthorization(c *gin.Context) {
 	username := c.Param("username")
 	if username == "" {
 		c.JSON(http.StatusBadRequest, false)
 		return
 	}
 
-	isAdmin, err := models.IsAdmin(db, username)
+	isAdmin, err := h.Users.IsAdmin(username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check admin status"})
 		return
@@ -114,14 +205,14 @@ func GetAuthorization(c *gin.Context, db *sql.DB) {
 	c.JSON(http.StatusOK, isAdmin)
 }
 
-func GetUserScores(c *gin.Context, db *sql.DB) {
+func (h *Handler) GetUserScores(c *gin.Context) {
 	username := c.Param("username")
 	if username == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Username is required"})
 		return
 	}
 
-	scores, err := models.FetchUserScores(db, username)
+	scores, err := h.Users.FetchUserScores(username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user scores"})
 		return
@@ -130,8 +221,8 @@ func GetUserScores(c *gin.Context, db *sql.DB) {
 	c.JSON(http.StatusOK, scores)
 }
 
-func GetLeaderboard(c *gin.Context, db *sql.DB) {
-	leaderboard, err := models.FetchLeaderboard(db)
+func (h *Handler) GetLeaderboard(c *gin.Context) {
+	leaderboard, err := h.Users.FetchLeaderboard()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check leaderboard"})
 		return
@@ -140,14 +231,14 @@ func GetLeaderboard(c *gin.Context, db *sql.DB) {
 	c.JSON(http.StatusOK, leaderboard)
 }
 
-func GetUserInfo(c *gin.Context, db *sql.DB) {
+func (h *Handler) GetUserInfo(c *gin.Context) {
 	username := c.Param("username")
 	if username == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Username is required"})
 		return
 	}
 
-	info, err := models.FetchUserInfo(db, username)
+	info, err := h.Users.FetchUserInfo(username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user information"})
 		return
@@ -156,10 +247,10 @@ func GetUserInfo(c *gin.Context, db *sql.DB) {
 	c.JSON(http.StatusOK, info)
 }
 
-func GetUserMetrics(c *gin.Context, db *sql.DB) {
+func (h *Handler) GetUserMetrics(c *gin.Context) {
 	username := c.Param("username")
 
-	metrics, err := models.FetchUserMetrics(db, username)
+	metrics, err := h.Users.FetchUserMetrics(username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to fetch metrics",
@@ -177,11 +268,11 @@ func GetUserMetrics(c *gin.Context, db *sql.DB) {
 	c.JSON(http.StatusOK, metrics)
 }
 
-func GetUserActivity(c *gin.Context, db *sql.DB) {
+func (h *Handler) GetUserActivity(c *gin.Context) {
 	username := c.Param("username")
 
 	// Fetch threads and comments for the user
-	userActivity, err := models.FetchUserActivity(db, username)
+	userActivity, err := h.Users.FetchUserActivity(username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user activity"})
 		return
@@ -191,16 +282,16 @@ func GetUserActivity(c *gin.Context, db *sql.DB) {
 }
 
 // Retrieves a user's saved threads for SideBar
-func GetUserSavedThreads(c *gin.Context, db *sql.DB) {
+func (h *Handler) GetUserSavedThreads(c *gin.Context) {
 	username := c.Param("username")
 
-	userID, err := models.GetUserIDFromUsername(db, username)
+	userID, err := h.Users.GetUserIDFromUsername(username)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username"})
 		return
 	}
 
-	savedThreads, err := models.FetchUserSavedThreads(db, userID)
+	savedThreads, err := h.Users.FetchUserSavedThreads(userID)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to fetch saved threads"})
 		return
@@ -209,7 +300,24 @@ func GetUserSavedThreads(c *gin.Context, db *sql.DB) {
 	c.JSON(http.StatusOK, gin.H{"savedThreads": savedThreads})
 }
 
-func UpdatePasswordHandler(c *gin.Context, db *sql.DB) {
+// Retrieves the caller's vote history, most recent first
+func (h *Handler) GetUserVotes(c *gin.Context) {
+	principal, ok := actingUser(c, c.Param("username"))
+	if !ok {
+		return
+	}
+
+	votes, err := h.Interactions.FetchUserVotes(principal.UserID)
+	if err != nil {
+		log.Printf("Error fetching votes: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch votes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"votes": votes})
+}
+
+func (h *Handler) UpdatePasswordHandler(c *gin.Context) {
 	var req PasswordChangeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
@@ -218,7 +326,7 @@ func UpdatePasswordHandler(c *gin.Context, db *sql.DB) {
 
 	username := c.Param("username")
 
-	hashedPassword, err := models.GetPassword(db, username)
+	hashedPassword, err := h.Users.GetPassword(username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
@@ -235,15 +343,28 @@ func UpdatePasswordHandler(c *gin.Context, db *sql.DB) {
 		return
 	}
 
-	if err := models.UpdatePassword(db, username, string(newHashedPassword)); err != nil {
+	// Changing the password signs out every existing session
+	if err := h.Users.UpdatePassword(username, string(newHashedPassword)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
+	// Keep the caller signed in with a fresh pair of tokens
+	principal, ok := actingUser(c, username)
+	if !ok {
+		return
+	}
+	tokens, err := issueTokens(h.Users, principal.UserID, principal.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	tokens["message"] = "Password updated successfully"
+
+	c.JSON(http.StatusOK, tokens)
 }
 
-func UpdateUserBio(c *gin.Context, db *sql.DB) {
+func (h *Handler) UpdateUserBio(c *gin.Context) {
 	username := c.Param("username")
 
 	var requestBody struct {
@@ -260,7 +381,17 @@ func UpdateUserBio(c *gin.Context, db *sql.DB) {
 		return
 	}
 
-	err := models.UpdateBio(db, requestBody.Bio, username)
+	// Bios are not posts, so flag rules do not apply
+	verdict, ok := h.moderate(c, moderation.Field{Name: "Bio", Text: &requestBody.Bio})
+	if !ok {
+		return
+	}
+	if len(verdict.Violations) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"errors": verdict.Violations})
+		return
+	}
+
+	err := h.Users.UpdateBio(requestBody.Bio, username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bio"})
 		return
@@ -269,26 +400,43 @@ func UpdateUserBio(c *gin.Context, db *sql.DB) {
 	c.JSON(http.StatusOK, gin.H{"message": "Bio updated successfully"})
 }
 
-func PromoteUserHandler(c *gin.Context, db *sql.DB) {
+func (h *Handler) PromoteUserHandler(c *gin.Context) {
 	username := c.Param("username")
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
 
-	err := models.PromoteUser(db, username)
+	userID, err := h.Users.GetUserIDFromUsername(username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err = h.Users.PromoteUser(username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to promote user"})
 		return
 	}
+	h.Notifier.Promotion(userID, admin.UserID)
 
+	log.Printf("Admin '%s' promoted user '%s' to admin", admin.Username, username)
 	c.JSON(http.StatusOK, gin.H{"message": "User promoted to admin successfully"})
 }
 
-func DemoteUserHandler(c *gin.Context, db *sql.DB) {
+func (h *Handler) DemoteUserHandler(c *gin.Context) {
 	username := c.Param("username")
+	admin, ok := actingUser(c, "")
+	if !ok {
+		return
+	}
 
-	err := models.DemoteUser(db, username)
+	err := h.Users.DemoteUser(username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to demote user"})
 		return
 	}
 
+	log.Printf("Admin '%s' demoted user '%s'", admin.Username, username)
 	c.JSON(http.StatusOK, gin.H{"message": "User demoted successfully"})
 }
@@ -0,0 +1,140 @@
+// Package diff compares two versions of a post word by word, for showing
+// what an edit changed.
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kinds of change between two texts
+const (
+	Equal  = "equal"
+	Insert = "insert"
+	Delete = "delete"
+)
+
+// Change is a run of text that was kept, inserted or deleted
+type Change struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Texts whose token counts multiply past this are compared as a whole,
+// keeping the comparison table small
+const maxCells = 4_000_000
+
+// Words returns the changes that turn before into after. Words and the
+// whitespace between them are compared as units, and adjacent changes of the
+// same kind are merged. Joining the equal and delete runs gives back before;
+// joining the equal and insert runs gives after.
+func Words(before, after string) []Change {
+	if before == after {
+		if before == "" {
+			return []Change{}
+		}
+		return []Change{{Equal, before}}
+	}
+
+	a, b := tokenize(before), tokenize(after)
+	if len(a)*len(b) > maxCells {
+		return merge(nil, []Change{{Delete, before}, {Insert, after}})
+	}
+
+	// lcs[i][j] is the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var changes []Change
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			changes = merge(changes, []Change{{Equal, a[i]}})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			changes = merge(changes, []Change{{Delete, a[i]}})
+			i++
+		default:
+			changes = merge(changes, []Change{{Insert, b[j]}})
+			j++
+		}
+	}
+	changes = merge(changes, []Change{{Delete, strings.Join(a[i:], "")}})
+	changes = merge(changes, []Change{{Insert, strings.Join(b[j:], "")}})
+	return absorbSpaces(changes)
+}
+
+// Folds whitespace kept between two edits into them, so that replacing
+// several words reads as one deletion and one insertion
+func absorbSpaces(changes []Change) []Change {
+	var result []Change
+	var deleted, inserted string
+	flush := func() {
+		result = merge(result, []Change{{Delete, deleted}, {Insert, inserted}})
+		deleted, inserted = "", ""
+	}
+	for i, change := range changes {
+		switch {
+		case change.Op == Delete:
+			deleted += change.Text
+		case change.Op == Insert:
+			inserted += change.Text
+		case i > 0 && i < len(changes)-1 && strings.TrimSpace(change.Text) == "":
+			deleted += change.Text
+			inserted += change.Text
+		default:
+			flush()
+			result = merge(result, []Change{change})
+		}
+	}
+	flush()
+	return result
+}
+
+// Appends changes, joining each to the last one when they are of the same
+// kind and dropping empty ones
+func merge(changes []Change, more []Change) []Change {
+	for _, change := range more {
+		if change.Text == "" {
+			continue
+		}
+		if last := len(changes) - 1; last >= 0 && changes[last].Op == change.Op {
+			changes[last].Text += change.Text
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// Splits text into alternating runs of whitespace and non-whitespace
+func tokenize(text string) []string {
+	var tokens []string
+	start := 0
+	space := false
+	for i, r := range text {
+		if isSpace := unicode.IsSpace(r); i == 0 || isSpace != space {
+			if i > start {
+				tokens = append(tokens, text[start:i])
+			}
+			start, space = i, isSpace
+		}
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
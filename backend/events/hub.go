@@ -0,0 +1,85 @@
+package events
+
+import (
+	"log"
+	"sync"
+)
+
+// Kinds of thread events
+const (
+	CommentCreated = "comment"
+	ThreadEdited   = "edit"
+	ThreadDeleted  = "delete"
+	VotesChanged   = "vote"
+)
+
+// Event is a change to a thread or one of its comments, delivered to
+// everyone watching the root thread. Data must be JSON-serializable.
+type Event struct {
+	Type     string      `json:"type"`
+	ThreadID int         `json:"threadId"` // Root thread the change belongs to
+	Data     interface{} `json:"data"`
+}
+
+// Broker fans events out to the subscribers of a thread. Hub serves a single
+// process; a broker built on Postgres LISTEN/NOTIFY can take its place to keep
+// several backend instances in sync.
+type Broker interface {
+	Publish(event Event)
+	Subscribe(threadID int) (<-chan Event, func())
+}
+
+// Events buffered per subscriber before further events are dropped
+const subscriberBuffer = 32
+
+// Hub is an in-process Broker
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[int]map[chan Event]struct{}
+}
+
+// NewHub returns a hub with no subscribers
+func NewHub() *Hub {
+	return &Hub{subscribers: map[int]map[chan Event]struct{}{}}
+}
+
+// Publish delivers an event to every subscriber of its thread without
+// blocking; subscribers that fall behind miss events rather than stall writers
+func (h *Hub) Publish(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers[event.ThreadID] {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("Dropped %s event for a slow subscriber of thread %d", event.Type, event.ThreadID)
+		}
+	}
+}
+
+// Subscribe returns a channel of a thread's events and a function that
+// unsubscribes and closes it
+func (h *Hub) Subscribe(threadID int) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[threadID] == nil {
+		h.subscribers[threadID] = map[chan Event]struct{}{}
+	}
+	h.subscribers[threadID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[threadID], ch)
+			if len(h.subscribers[threadID]) == 0 {
+				delete(h.subscribers, threadID)
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
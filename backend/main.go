@@ -2,14 +2,29 @@ package main
 
 import (
 	"backend/config"
+	"backend/controllers"
+	"backend/models"
+	"backend/ratelimit"
 	"backend/routes"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "recount":
+			os.Exit(runRecount())
+		}
+	}
+
 	db, err := config.InitializeDatabase()
 	if err != nil {
 		log.Fatalf("Error initializing database: %v", err)
@@ -17,6 +32,12 @@ func main() {
 	defer db.Close()
 
 	router := gin.Default()
+
+	// Only believe X-Forwarded-For from the proxies listed in TRUSTED_PROXIES,
+	// comma separated; otherwise clients could pick their own rate limit key
+	if err := router.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 	router.Use(cors.New(cors.Config{
 		// Change to your deployment app URL if deployed
 		AllowOrigins:     []string{"http://localhost:3000", "https://forum2025.netlify.app"},
@@ -25,11 +46,32 @@ func main() {
 		AllowCredentials: true,
 	}))
 
+	handler := controllers.NewHandler(models.NewPostgresStore(db))
+
+	// Share rate limits between instances when running more than one
+	if os.Getenv("RATE_LIMIT_STORE") == "postgres" {
+		handler.Limiter = ratelimit.NewPostgresLimiter(db)
+	}
+
+	// Let only admins introduce new tags; others choose from existing ones
+	handler.RestrictTagCreation = os.Getenv("RESTRICT_TAG_CREATION") == "true"
+
 	// Register routes
-	routes.RegisterRoutes(router, db)
+	routes.RegisterRoutes(router, handler)
 
 	// Start the server
 	if err := router.Run(":8080"); err != nil {
 		log.Fatalf("Failed to start the server: %v", err)
 	}
 }
+
+// Reads the proxy addresses or CIDR ranges to trust; none by default
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
@@ -0,0 +1,388 @@
+// Package markdown renders the Markdown subset accepted in threads and
+// comments: paragraphs, fenced code blocks, block quotes, ordered and
+// unordered lists, links, inline code, and strong and emphasized text.
+//
+// Output is sanitized by construction. Every piece of source text is
+// HTML-escaped, raw HTML is never passed through, and only the tags and
+// attributes in AllowedTags are emitted. Links must use an allowed scheme.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AllowedTags lists every tag Render can emit, with its permitted attributes
+var AllowedTags = map[string][]string{
+	"p":          nil,
+	"br":         nil,
+	"strong":     nil,
+	"em":         nil,
+	"code":       {"class"}, // language-* on fenced code blocks
+	"pre":        nil,
+	"blockquote": nil,
+	"ul":         nil,
+	"ol":         {"start"},
+	"li":         nil,
+	"a":          {"href", "rel"},
+}
+
+// Link schemes that may appear in href attributes
+var allowedSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// Blocks nested deeper than this are rendered as plain paragraphs
+const maxNesting = 8
+
+var (
+	fencePattern    = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})\\s*([^`\\s]*)\\s*$")
+	quotePattern    = regexp.MustCompile(`^ {0,3}> ?`)
+	listPattern     = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])( +|$)`)
+	languagePattern = regexp.MustCompile(`^[A-Za-z0-9_+-]+$`)
+)
+
+// Render converts Markdown source to sanitized HTML
+func Render(source string) string {
+	source = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(source)
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(source, "\n"), false, 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Renders a sequence of block-level lines. Tight blocks, such as the items of
+// a list without blank lines, leave paragraphs unwrapped.
+func renderBlocks(b *strings.Builder, lines []string, tight bool, depth int) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+
+		case fencePattern.MatchString(line):
+			i = renderFence(b, lines, i)
+
+		case depth < maxNesting && quotePattern.MatchString(line):
+			var quoted []string
+			for ; i < len(lines) && quotePattern.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quotePattern.ReplaceAllString(lines[i], ""))
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted, false, depth+1)
+			b.WriteString("</blockquote>\n")
+
+		case depth < maxNesting && listPattern.MatchString(line):
+			i = renderList(b, lines, i, depth)
+
+		default:
+			var paragraph []string
+			for ; i < len(lines) && !isBlank(lines[i]) && (len(paragraph) == 0 || !startsBlock(lines[i], depth)); i++ {
+				paragraph = append(paragraph, strings.TrimSpace(lines[i]))
+			}
+			if !tight {
+				b.WriteString("<p>")
+			}
+			for j, text := range paragraph {
+				if j > 0 {
+					b.WriteString("<br>\n")
+				}
+				b.WriteString(renderInline(text, false))
+			}
+			if !tight {
+				b.WriteString("</p>")
+			}
+			b.WriteString("\n")
+		}
+	}
+}
+
+// Renders the fenced code block opening at lines[start] and returns the index
+// of the line after it. An unclosed fence runs to the end of the content.
+func renderFence(b *strings.Builder, lines []string, start int) int {
+	match := fencePattern.FindStringSubmatch(lines[start])
+	fence, language := match[1], match[2]
+
+	var code []string
+	i := start + 1
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+		code = append(code, lines[i])
+	}
+
+	b.WriteString("<pre><code")
+	if languagePattern.MatchString(language) {
+		b.WriteString(` class="language-` + html.EscapeString(language) + `"`)
+	}
+	b.WriteString(">")
+	for _, line := range code {
+		b.WriteString(html.EscapeString(line) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+// Renders the list starting at lines[start] and returns the index of the line
+// after it. Items continue on lines indented past their marker.
+func renderList(b *strings.Builder, lines []string, start int, depth int) int {
+	first := listPattern.FindStringSubmatch(lines[start])
+	ordered := isOrdered(first[2])
+
+	var items [][]string
+	tight := true
+	i := start
+	for i < len(lines) {
+		match := listPattern.FindStringSubmatch(lines[i])
+		if match == nil || isOrdered(match[2]) != ordered {
+			break
+		}
+		width := len(match[0])
+		if match[3] == "" || len(match[3]) > 4 {
+			width = len(match[1]) + len(match[2]) + 1
+		}
+		item := []string{strings.TrimLeft(lines[i][min(width, len(lines[i])):], " ")}
+
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if isBlank(line) {
+				// A blank line continues the item only if indented content follows
+				next := i + 1
+				for next < len(lines) && isBlank(lines[next]) {
+					next++
+				}
+				if next < len(lines) && indentation(lines[next]) >= width {
+					tight = false
+					item = append(item, "")
+					continue
+				}
+				if next < len(lines) && listPattern.MatchString(lines[next]) {
+					tight = false
+				}
+				i = next
+				break
+			}
+			if indentation(line) >= width {
+				item = append(item, line[width:])
+			} else if !startsBlock(line, depth) && !isBlank(item[len(item)-1]) {
+				item = append(item, strings.TrimSpace(line)) // Lazy paragraph continuation
+			} else {
+				break
+			}
+		}
+		items = append(items, item)
+	}
+
+	if ordered {
+		number, _ := strconv.Atoi(strings.TrimRight(first[2], ".)"))
+		if number != 1 {
+			b.WriteString(`<ol start="` + strconv.Itoa(number) + `">` + "\n")
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+	for _, item := range items {
+		b.WriteString("<li>")
+		var inner strings.Builder
+		renderBlocks(&inner, item, tight, depth+1)
+		b.WriteString(strings.TrimSuffix(inner.String(), "\n"))
+		b.WriteString("</li>\n")
+	}
+	if ordered {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+	return i
+}
+
+// Renders inline markup within a single line of a paragraph
+func renderInline(s string, inLink bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			if end := codeSpanEnd(s, i); end > 0 {
+				run := backtickRun(s, i)
+				code := strings.TrimSpace(s[i+run : end-run])
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i = end
+				continue
+			}
+
+		case c == '[' && !inLink:
+			if text, href, end, ok := parseLink(s, i); ok {
+				b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer">`)
+				b.WriteString(renderInline(text, true))
+				b.WriteString("</a>")
+				i = end
+				continue
+			}
+
+		case c == '*' || c == '_':
+			if rendered, end, ok := renderEmphasis(s, i, inLink); ok {
+				b.WriteString(rendered)
+				i = end
+				continue
+			}
+		}
+
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// Renders strong or emphasized text opened at s[start] and returns the index
+// after its closing delimiter
+func renderEmphasis(s string, start int, inLink bool) (string, int, bool) {
+	for _, tag := range []struct{ delim, name string }{
+		{strings.Repeat(s[start:start+1], 2), "strong"},
+		{s[start : start+1], "em"},
+	} {
+		if end, ok := emphasisEnd(s, start, tag.delim); ok {
+			inner := renderInline(s[start+len(tag.delim):end], inLink)
+			return "<" + tag.name + ">" + inner + "</" + tag.name + ">", end + len(tag.delim), true
+		}
+	}
+	return "", 0, false
+}
+
+// Finds the closing delimiter of emphasis opened at s[start]. The content
+// may not begin or end with a space, and underscores inside words are literal.
+func emphasisEnd(s string, start int, delim string) (int, bool) {
+	open := start + len(delim)
+	if !strings.HasPrefix(s[start:], delim) || open >= len(s) || s[open] == ' ' {
+		return 0, false
+	}
+	if delim[0] == '_' && start > 0 && isWordByte(s[start-1]) {
+		return 0, false
+	}
+
+	for i := open + 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '`':
+			if end := codeSpanEnd(s, i); end > 0 {
+				i = end - 1
+			}
+		case s[i] == delim[0]:
+			run := len(s[i:]) - len(strings.TrimLeft(s[i:], delim[:1]))
+			// Single delimiters skip the doubled runs of nested strong text
+			if run < len(delim) || len(delim) == 1 && run > 1 || s[i-1] == ' ' {
+				i += run - 1
+				continue
+			}
+			if delim[0] == '_' && i+len(delim) < len(s) && isWordByte(s[i+len(delim)]) {
+				continue
+			}
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Parses [text](url) at s[start], accepting only allowed link schemes
+func parseLink(s string, start int) (text, href string, end int, ok bool) {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if i+1 >= len(s) || s[i+1] != '(' {
+				return "", "", 0, false
+			}
+			closing := strings.IndexByte(s[i+2:], ')')
+			if closing < 0 {
+				return "", "", 0, false
+			}
+			href = strings.TrimSpace(s[i+2 : i+2+closing])
+			if !safeURL(href) {
+				return "", "", 0, false
+			}
+			return s[start+1 : i], href, i + 3 + closing, true
+		}
+	}
+	return "", "", 0, false
+}
+
+// Reports whether a link target is an absolute URL with an allowed scheme
+func safeURL(href string) bool {
+	if href == "" || strings.ContainsAny(href, " \t\n\"'<>`") {
+		return false
+	}
+	parsed, err := url.Parse(href)
+	return err == nil && allowedSchemes[strings.ToLower(parsed.Scheme)] && (parsed.Host != "" || parsed.Opaque != "")
+}
+
+// Returns the index after the code span opened at s[start], or 0 if unclosed
+func codeSpanEnd(s string, start int) int {
+	run := backtickRun(s, start)
+	for i := start + run; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		closing := backtickRun(s, i)
+		if closing == run {
+			return i + closing
+		}
+		i += closing
+	}
+	return 0
+}
+
+func backtickRun(s string, start int) int {
+	n := 0
+	for start+n < len(s) && s[start+n] == '`' {
+		n++
+	}
+	return n
+}
+
+// Reports whether a line interrupts a paragraph by opening another block
+func startsBlock(line string, depth int) bool {
+	if fencePattern.MatchString(line) {
+		return true
+	}
+	return depth < maxNesting && (quotePattern.MatchString(line) || listPattern.MatchString(line))
+}
+
+func isOrdered(marker string) bool {
+	return marker[0] >= '0' && marker[0] <= '9'
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
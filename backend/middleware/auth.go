@@ -1,18 +1,33 @@
 package middleware
 
 import (
+	"backend/models"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	_ "github.com/joho/godotenv/autoload"
 )
 
-// Middleware to verify JWT tokens
-func AuthMiddleware() gin.HandlerFunc {
+// Context keys under which the verified principal and token are stored
+const (
+	principalKey = "principal"
+	tokenKey     = "token"
+)
+
+// TokenInfo identifies the access token used for the current request
+type TokenInfo struct {
+	JTI       string
+	ExpiresAt time.Time
+}
+
+// Middleware to verify JWT tokens and load the acting user
+func AuthMiddleware(users models.UserStore) gin.HandlerFunc {
 	var secretKey = []byte(os.Getenv("JWT_SECRET_KEY"))
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -38,18 +53,75 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Extract claims from the token
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			username, exists := claims["username"].(string)
-			if !exists || username == "" {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-				return
-			}
-			c.Set("username", username)
-		} else {
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || !token.Valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			return
+		}
+		username, exists := claims["username"].(string)
+		if !exists || username == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
 			return
 		}
+		jti, hasJTI := claims["jti"].(string)
+		issuedAt, hasIAT := claims["iat"].(float64)
+		expiresAt, hasExp := claims["exp"].(float64)
+		if !hasJTI || !hasIAT || !hasExp {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			return
+		}
+
+		// Tokens from before iat_us was added only carry whole seconds
+		issued := time.Unix(int64(issuedAt), 0)
+		if issuedMicros, ok := claims["iat_us"].(float64); ok {
+			issued = time.UnixMicro(int64(issuedMicros))
+		}
+
+		// Reject tokens revoked by logout or by a password change
+		revoked, err := users.IsAccessTokenRevoked(jti, username, issued)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify token"})
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			return
+		}
+
+		// Load the user once so handlers never trust client-supplied identities
+		principal, err := users.FetchPrincipal(username)
+		if err == sql.ErrNoRows {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User no longer exists"})
+			return
+		} else if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+			return
+		}
+
+		c.Set("username", principal.Username)
+		c.Set(principalKey, principal)
+		c.Set(tokenKey, TokenInfo{JTI: jti, ExpiresAt: time.Unix(int64(expiresAt), 0)})
 
 		c.Next()
 	}
 }
+
+// CurrentPrincipal returns the user verified by AuthMiddleware, if any
+func CurrentPrincipal(c *gin.Context) (*models.Principal, bool) {
+	value, exists := c.Get(principalKey)
+	if !exists {
+		return nil, false
+	}
+	principal, ok := value.(*models.Principal)
+	return principal, ok
+}
+
+// CurrentToken returns the access token verified by AuthMiddleware, if any
+func CurrentToken(c *gin.Context) (TokenInfo, bool) {
+	value, exists := c.Get(tokenKey)
+	if !exists {
+		return TokenInfo{}, false
+	}
+	info, ok := value.(TokenInfo)
+	return info, ok
+}
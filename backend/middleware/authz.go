@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"backend/models"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rejects the request with a 403 and records who tried to do what
+func forbid(c *gin.Context, principal *models.Principal, reason string) {
+	log.Printf("Forbidden: user '%s' (id %d) denied %s %s: %s",
+		principal.Username, principal.UserID, c.Request.Method, c.Request.URL.Path, reason)
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"error":  "Forbidden",
+		"reason": reason,
+		"user":   principal.Username,
+		"action": c.Request.Method + " " + c.FullPath(),
+	})
+}
+
+// Middleware allowing only admins through. Must run after AuthMiddleware.
+func RequireAdmin(users models.UserStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		// Re-check the role so a demotion takes effect immediately
+		isAdmin, err := users.IsAdmin(principal.Username)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check admin status"})
+			return
+		}
+		if !isAdmin {
+			forbid(c, principal, "admin role required")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Middleware allowing only the user named by the given path parameter.
+// Must run after AuthMiddleware.
+func RequireSelf(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		if c.Param(param) != principal.Username {
+			forbid(c, principal, "only the account owner may do this")
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"backend/ratelimit"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context key of the decision behind the X-RateLimit-* headers sent so far
+const rateLimitKey = "rateLimit"
+
+// Middleware throttling a route group under the given policy. Signed-in users
+// are limited per account, everyone else per IP address. Place it after
+// AuthMiddleware on protected routes. If the limiter fails, requests are let
+// through rather than locking everyone out. When several limits apply to a
+// route, the headers describe the one with the fewest requests left.
+func RateLimit(limiter ratelimit.Limiter, name string, policy ratelimit.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if policy.Burst <= 0 {
+			c.Next()
+			return
+		}
+
+		subject := "ip:" + c.ClientIP()
+		if principal, ok := CurrentPrincipal(c); ok {
+			if policy.AccountAge > 0 && time.Since(principal.CreatedAt) >= policy.AccountAge {
+				c.Next()
+				return
+			}
+			subject = "user:" + strconv.Itoa(principal.UserID)
+		}
+
+		decision, err := limiter.Take(name+":"+subject, policy)
+		if err != nil {
+			log.Printf("Rate limiter failed for %s: %v", name, err)
+			c.Next()
+			return
+		}
+
+		if previous, ok := c.Get(rateLimitKey); !ok || !decision.Allowed || decision.Remaining < previous.(ratelimit.Decision).Remaining {
+			c.Set(rateLimitKey, decision)
+			c.Header("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+			c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			c.Header("X-RateLimit-Reset", strconv.Itoa(seconds(decision.Reset)))
+		}
+		if !decision.Allowed {
+			retryAfter := seconds(decision.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":      fmt.Sprintf("Too many requests, try again in %d seconds", retryAfter),
+				"retryAfter": retryAfter,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Whole seconds, rounded up so clients never retry too early
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
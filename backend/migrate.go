@@ -0,0 +1,65 @@
+package main
+
+import (
+	"backend/config"
+	"backend/migrations"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = `Usage: main migrate <command>
+
+Commands:
+  up          Apply all pending migrations
+  down [n]    Roll back the last n migrations (default 1)
+  status      Show applied and pending migrations`
+
+// Handles `main migrate ...` and returns the process exit code
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(migrateUsage)
+		return 2
+	}
+
+	db, err := config.ConnectDatabase()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		err = migrations.Up(db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, "down expects a positive number of steps")
+				return 2
+			}
+		}
+		err = migrations.Down(db, steps)
+	case "status":
+		var statuses []migrations.Status
+		statuses, err = migrations.GetStatus(db)
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		fmt.Println(migrateUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
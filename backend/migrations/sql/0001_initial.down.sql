@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS dislikes;
+DROP TABLE IF EXISTS likes;
+DROP TABLE IF EXISTS saved_threads;
+DROP TABLE IF EXISTS threads;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,63 @@
+-- Baseline schema. IF NOT EXISTS lets deployments created before
+-- versioned migrations adopt this version without losing data.
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	username TEXT UNIQUE NOT NULL,
+	password TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	bio TEXT DEFAULT 'This user has not added a bio yet.',
+	is_admin BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+	id SERIAL PRIMARY KEY,
+	name TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+	id SERIAL PRIMARY KEY,
+	name TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS threads (
+	id SERIAL PRIMARY KEY,
+	title TEXT UNIQUE,
+	content TEXT NOT NULL,
+	category_id INTEGER,
+	tag_id INTEGER,
+	user_id INTEGER NOT NULL,
+	parent_id INTEGER,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	depth INTEGER DEFAULT 0,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (parent_id) REFERENCES threads(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS saved_threads (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	thread_id INTEGER NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (thread_id) REFERENCES threads(id) ON DELETE CASCADE,
+	UNIQUE (user_id, thread_id)
+);
+
+CREATE TABLE IF NOT EXISTS likes (
+	id SERIAL PRIMARY KEY,
+	thread_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (thread_id) REFERENCES threads(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	UNIQUE (thread_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS dislikes (
+	id SERIAL PRIMARY KEY,
+	thread_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (thread_id) REFERENCES threads(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	UNIQUE (thread_id, user_id)
+);
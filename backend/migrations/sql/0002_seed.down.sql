@@ -0,0 +1,4 @@
+DELETE FROM threads WHERE title = 'Welcome to the Forum' AND user_id = 1;
+DELETE FROM users WHERE id = 1 AND username = 'admin_user';
+DELETE FROM tags WHERE name = 'Rules';
+DELETE FROM categories WHERE name IN ('Featured', 'Coursework', 'Events', 'Community');
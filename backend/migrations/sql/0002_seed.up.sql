@@ -0,0 +1,18 @@
+INSERT INTO categories (name)
+VALUES ('Featured'), ('Coursework'), ('Events'), ('Community')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO tags (name)
+VALUES ('Rules')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO users (id, username, password, bio, is_admin)
+VALUES (1, 'admin_user', '$2y$10$rEbQNZucaJ3pgh.qq/WzLujs7F97Zm24ODYam41gcSw1cc4DbiWwK', 'I am the king.', TRUE)
+ON CONFLICT (username) DO NOTHING;
+
+-- The admin row uses an explicit id, so move the sequence past it
+SELECT setval('users_id_seq', (SELECT MAX(id) FROM users));
+
+INSERT INTO threads (title, content, category_id, tag_id, user_id, created_at)
+VALUES ('Welcome to the Forum', 'Please follow the rules.', 1, 1, 1, CURRENT_TIMESTAMP)
+ON CONFLICT (title) DO NOTHING;
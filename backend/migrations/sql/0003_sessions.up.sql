@@ -0,0 +1,23 @@
+-- Server-side refresh tokens, stored as SHA-256 hashes and rotated on use
+CREATE TABLE refresh_tokens (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	token_hash TEXT UNIQUE NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP,
+	replaced_by INTEGER,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (replaced_by) REFERENCES refresh_tokens(id) ON DELETE SET NULL
+);
+
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+-- Access tokens revoked before expiry (logout), keyed by their jti claim
+CREATE TABLE revoked_tokens (
+	jti TEXT PRIMARY KEY,
+	expires_at TIMESTAMP NOT NULL
+);
+
+-- Access tokens issued before this instant are rejected (password change)
+ALTER TABLE users ADD COLUMN sessions_valid_after TIMESTAMP;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS users_search_vector_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
+DROP INDEX IF EXISTS threads_search_vector_idx;
+ALTER TABLE threads DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,17 @@
+-- Full-text search over threads (titles weighted above content) and comments
+ALTER TABLE threads ADD COLUMN search_vector tsvector
+	GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('english', content), 'B')
+	) STORED;
+
+CREATE INDEX threads_search_vector_idx ON threads USING GIN (search_vector);
+
+-- Users are matched on name and bio without stemming
+ALTER TABLE users ADD COLUMN search_vector tsvector
+	GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', username), 'A') ||
+		setweight(to_tsvector('simple', coalesce(bio, '')), 'B')
+	) STORED;
+
+CREATE INDEX users_search_vector_idx ON users USING GIN (search_vector);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS threads_parent_id_idx;
+DROP INDEX IF EXISTS threads_comments_count_idx;
+DROP INDEX IF EXISTS threads_dislikes_count_idx;
+DROP INDEX IF EXISTS threads_likes_count_idx;
+DROP INDEX IF EXISTS threads_created_at_idx;
+
+ALTER TABLE threads
+	DROP COLUMN IF EXISTS comments_count,
+	DROP COLUMN IF EXISTS dislikes_count,
+	DROP COLUMN IF EXISTS likes_count;
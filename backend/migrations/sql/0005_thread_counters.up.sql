@@ -0,0 +1,18 @@
+-- Counters maintained alongside likes, dislikes and replies so listings
+-- no longer aggregate over joins
+ALTER TABLE threads
+	ADD COLUMN likes_count INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN dislikes_count INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN comments_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE threads SET
+	likes_count = (SELECT COUNT(*) FROM likes WHERE likes.thread_id = threads.id),
+	dislikes_count = (SELECT COUNT(*) FROM dislikes WHERE dislikes.thread_id = threads.id),
+	comments_count = (SELECT COUNT(*) FROM threads AS comments WHERE comments.parent_id = threads.id);
+
+-- Keyset-friendly indexes for each listing sort order
+CREATE INDEX threads_created_at_idx ON threads (created_at, id) WHERE title IS NOT NULL;
+CREATE INDEX threads_likes_count_idx ON threads (likes_count, id) WHERE title IS NOT NULL;
+CREATE INDEX threads_dislikes_count_idx ON threads (dislikes_count, id) WHERE title IS NOT NULL;
+CREATE INDEX threads_comments_count_idx ON threads (comments_count, id) WHERE title IS NOT NULL;
+CREATE INDEX threads_parent_id_idx ON threads (parent_id);
@@ -0,0 +1,30 @@
+DROP INDEX IF EXISTS threads_score_idx;
+ALTER TABLE threads DROP COLUMN IF EXISTS score;
+
+CREATE TABLE likes (
+	id SERIAL PRIMARY KEY,
+	thread_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (thread_id) REFERENCES threads(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	UNIQUE (thread_id, user_id)
+);
+
+CREATE TABLE dislikes (
+	id SERIAL PRIMARY KEY,
+	thread_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (thread_id) REFERENCES threads(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	UNIQUE (thread_id, user_id)
+);
+
+INSERT INTO likes (thread_id, user_id, created_at)
+SELECT thread_id, user_id, updated_at FROM votes WHERE value = 1;
+
+INSERT INTO dislikes (thread_id, user_id, created_at)
+SELECT thread_id, user_id, updated_at FROM votes WHERE value = -1;
+
+DROP TABLE votes;
@@ -0,0 +1,37 @@
+-- One row per (thread, user) replaces the separate likes and dislikes
+-- tables, so a user can never hold both reactions at once
+CREATE TABLE votes (
+	thread_id INTEGER NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (thread_id, user_id)
+);
+
+CREATE INDEX votes_user_id_idx ON votes (user_id, updated_at);
+
+-- Users caught in both tables keep whichever reaction they made last
+INSERT INTO votes (thread_id, user_id, value, created_at, updated_at)
+SELECT thread_id, user_id, -1, COALESCE(created_at, CURRENT_TIMESTAMP), COALESCE(created_at, CURRENT_TIMESTAMP)
+FROM dislikes;
+
+INSERT INTO votes (thread_id, user_id, value, created_at, updated_at)
+SELECT thread_id, user_id, 1, COALESCE(created_at, CURRENT_TIMESTAMP), COALESCE(created_at, CURRENT_TIMESTAMP)
+FROM likes
+ON CONFLICT (thread_id, user_id) DO UPDATE
+SET value = 1, created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at
+WHERE EXCLUDED.created_at >= votes.created_at;
+
+DROP TABLE likes;
+DROP TABLE dislikes;
+
+-- Resync the counters with the merged votes
+UPDATE threads SET
+	likes_count = (SELECT COUNT(*) FROM votes WHERE votes.thread_id = threads.id AND value = 1),
+	dislikes_count = (SELECT COUNT(*) FROM votes WHERE votes.thread_id = threads.id AND value = -1);
+
+ALTER TABLE threads
+	ADD COLUMN score INTEGER GENERATED ALWAYS AS (likes_count - dislikes_count) STORED;
+
+CREATE INDEX threads_score_idx ON threads (score, id) WHERE title IS NOT NULL;
@@ -0,0 +1,25 @@
+CREATE TABLE notifications (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- Recipient
+	type VARCHAR(32) NOT NULL,
+	actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	thread_id INTEGER REFERENCES threads(id) ON DELETE CASCADE,
+	milestone INTEGER, -- Like count reached, for like_milestone
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	read_at TIMESTAMP
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, created_at DESC, id DESC);
+CREATE INDEX notifications_unread_idx ON notifications (user_id) WHERE read_at IS NULL;
+
+-- Each milestone is announced once, even if likes are withdrawn and re-added
+CREATE UNIQUE INDEX notifications_milestone_idx ON notifications (user_id, thread_id, milestone)
+	WHERE type = 'like_milestone';
+
+-- Missing rows mean the event type is enabled
+CREATE TABLE notification_preferences (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	type VARCHAR(32) NOT NULL,
+	enabled BOOLEAN NOT NULL,
+	PRIMARY KEY (user_id, type)
+);
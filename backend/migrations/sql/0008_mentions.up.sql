@@ -0,0 +1,12 @@
+-- Users mentioned in a thread or comment, kept in sync with its content
+CREATE TABLE mentions (
+	thread_id INTEGER NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (thread_id, user_id)
+);
+
+CREATE INDEX mentions_user_id_idx ON mentions (user_id);
+
+-- Serves case-insensitive prefix lookups for username autocomplete
+CREATE INDEX users_username_prefix_idx ON users (lower(username) text_pattern_ops);
@@ -0,0 +1,24 @@
+-- Admin-managed content rules applied to every write
+CREATE TABLE moderation_rules (
+	id SERIAL PRIMARY KEY,
+	pattern TEXT NOT NULL,
+	kind VARCHAR(16) NOT NULL CHECK (kind IN ('word', 'substring', 'regex')),
+	action VARCHAR(16) NOT NULL CHECK (action IN ('reject', 'mask', 'flag')),
+	created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Posts that matched a flag rule, awaiting review
+CREATE TABLE moderation_flags (
+	thread_id INTEGER NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+	rule_id INTEGER NOT NULL REFERENCES moderation_rules(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (thread_id, rule_id)
+);
+
+CREATE INDEX moderation_flags_created_at_idx ON moderation_flags (created_at DESC);
+
+-- Carry over the words that used to be hard-coded in the validators
+INSERT INTO moderation_rules (pattern, kind, action) VALUES
+	('mother', 'substring', 'reject'),
+	('child', 'substring', 'reject');
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS reports;
+DROP TABLE IF EXISTS moderation_actions;
+ALTER TABLE threads DROP COLUMN IF EXISTS hidden_at;
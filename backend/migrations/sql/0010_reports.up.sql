@@ -0,0 +1,31 @@
+-- Set when a moderator hides a post from public view
+ALTER TABLE threads ADD COLUMN hidden_at TIMESTAMP;
+
+-- Moderator decisions, kept after the post itself is deleted
+CREATE TABLE moderation_actions (
+	id SERIAL PRIMARY KEY,
+	thread_id INTEGER NOT NULL, -- No foreign key, so the record outlives deletions
+	author_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	moderator_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	action VARCHAR(16) NOT NULL CHECK (action IN ('dismiss', 'hide', 'delete', 'warn')),
+	note TEXT,
+	reports_resolved INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX moderation_actions_thread_id_idx ON moderation_actions (thread_id);
+
+CREATE TABLE reports (
+	id SERIAL PRIMARY KEY,
+	thread_id INTEGER NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+	reporter_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	reason VARCHAR(32) NOT NULL,
+	details TEXT,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	resolved_at TIMESTAMP,
+	action_id INTEGER REFERENCES moderation_actions(id) ON DELETE SET NULL
+);
+
+-- One open report per user and post; the queue only reads open reports
+CREATE UNIQUE INDEX reports_open_reporter_idx ON reports (thread_id, reporter_id) WHERE resolved_at IS NULL;
+CREATE INDEX reports_open_idx ON reports (thread_id) WHERE resolved_at IS NULL;
@@ -0,0 +1,7 @@
+-- Tombstones become hard deletes again, taking their replies with them
+DELETE FROM threads WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS threads_deleted_at_idx;
+ALTER TABLE threads
+	DROP COLUMN IF EXISTS deleted_by,
+	DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,6 @@
+-- Deleted posts stay in place as tombstones so their replies remain visible
+ALTER TABLE threads
+	ADD COLUMN deleted_at TIMESTAMP,
+	ADD COLUMN deleted_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX threads_deleted_at_idx ON threads (deleted_at) WHERE deleted_at IS NOT NULL;
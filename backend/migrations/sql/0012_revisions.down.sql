@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS thread_revisions;
+ALTER TABLE threads DROP COLUMN IF EXISTS updated_at;
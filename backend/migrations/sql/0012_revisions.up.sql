@@ -0,0 +1,14 @@
+-- Set on every edit; NULL means the post was never edited
+ALTER TABLE threads ADD COLUMN updated_at TIMESTAMP;
+
+-- What a post said before each edit
+CREATE TABLE thread_revisions (
+	id SERIAL PRIMARY KEY,
+	thread_id INTEGER NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+	editor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	title TEXT, -- NULL for comments
+	content TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX thread_revisions_thread_id_idx ON thread_revisions (thread_id, id);
@@ -0,0 +1,8 @@
+-- Token buckets shared by every backend instance using the Postgres rate limiter
+CREATE TABLE rate_limits (
+	key TEXT PRIMARY KEY, -- Route group and the user or IP address it throttles
+	tokens DOUBLE PRECISION NOT NULL,
+	updated_at TIMESTAMPTZ -- NULL until the bucket is first used
+);
+
+CREATE INDEX rate_limits_updated_at_idx ON rate_limits (updated_at);
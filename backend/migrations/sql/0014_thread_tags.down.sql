@@ -0,0 +1,12 @@
+-- Each thread keeps only its first tag
+ALTER TABLE threads ADD COLUMN tag_id INTEGER;
+
+UPDATE threads SET tag_id = first.tag_id
+FROM (
+	SELECT DISTINCT ON (thread_id) thread_id, tag_id
+	FROM thread_tags
+	ORDER BY thread_id, position, tag_id
+) AS first
+WHERE threads.id = first.thread_id;
+
+DROP TABLE IF EXISTS thread_tags;
@@ -0,0 +1,19 @@
+-- Threads carry several tags, kept in the order the author gave them
+CREATE TABLE thread_tags (
+	thread_id INTEGER NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+	tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	position SMALLINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (thread_id, tag_id)
+);
+
+CREATE INDEX thread_tags_tag_id_idx ON thread_tags (tag_id);
+
+INSERT INTO thread_tags (thread_id, tag_id)
+SELECT threads.id, threads.tag_id
+FROM threads
+INNER JOIN tags ON threads.tag_id = tags.id;
+
+-- Empty names were created by threads posted without a tag
+DELETE FROM tags WHERE btrim(name) = '';
+
+ALTER TABLE threads DROP COLUMN tag_id;
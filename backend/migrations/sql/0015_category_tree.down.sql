@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS threads_category_id_idx;
+ALTER TABLE categories
+	DROP COLUMN IF EXISTS posting,
+	DROP COLUMN IF EXISTS parent_id,
+	DROP COLUMN IF EXISTS position,
+	DROP COLUMN IF EXISTS description,
+	DROP COLUMN IF EXISTS slug;
@@ -0,0 +1,28 @@
+-- Categories become admin-managed sub-forums
+ALTER TABLE categories
+	ADD COLUMN slug TEXT,
+	ADD COLUMN description TEXT NOT NULL DEFAULT '',
+	ADD COLUMN position INTEGER NOT NULL DEFAULT 0, -- Display order among siblings
+	ADD COLUMN parent_id INTEGER REFERENCES categories(id) ON DELETE RESTRICT,
+	ADD COLUMN posting TEXT NOT NULL DEFAULT 'everyone' CHECK (posting IN ('everyone', 'admins'));
+
+UPDATE categories SET
+	slug = COALESCE(NULLIF(btrim(lower(regexp_replace(name, '[^a-zA-Z0-9]+', '-', 'g')), '-'), ''), 'category') || '-' || id,
+	position = id;
+
+-- Keep the plain slug wherever it is not taken twice
+UPDATE categories SET slug = regexp_replace(slug, '-[0-9]+$', '')
+WHERE regexp_replace(slug, '-[0-9]+$', '') NOT IN (
+	SELECT regexp_replace(slug, '-[0-9]+$', '')
+	FROM categories
+	GROUP BY 1
+	HAVING COUNT(*) > 1
+);
+
+ALTER TABLE categories ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE categories ADD CONSTRAINT categories_slug_key UNIQUE (slug);
+
+CREATE INDEX categories_parent_id_idx ON categories (parent_id);
+CREATE INDEX threads_category_id_idx ON threads (category_id) WHERE parent_id IS NULL;
+
+UPDATE categories SET posting = 'admins' WHERE name = 'Featured';
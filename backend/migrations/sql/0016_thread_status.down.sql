@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS threads_pinned_idx;
+ALTER TABLE threads
+	DROP CONSTRAINT IF EXISTS threads_pin_check,
+	DROP COLUMN IF EXISTS announcement,
+	DROP COLUMN IF EXISTS locked_at,
+	DROP COLUMN IF EXISTS pin_scope,
+	DROP COLUMN IF EXISTS pinned_at;
@@ -0,0 +1,13 @@
+-- Admin controls on top-level threads
+ALTER TABLE threads
+	ADD COLUMN pinned_at TIMESTAMP, -- Set while pinned; newer pins come first
+	ADD COLUMN pin_scope TEXT CHECK (pin_scope IN ('global', 'category')),
+	ADD COLUMN locked_at TIMESTAMP, -- Set while replies and votes are closed
+	ADD COLUMN announcement BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD CONSTRAINT threads_pin_check CHECK ((pinned_at IS NULL) = (pin_scope IS NULL));
+
+CREATE INDEX threads_pinned_idx ON threads (pinned_at) WHERE pinned_at IS NOT NULL;
+
+-- Keep the rules at the top of every listing
+UPDATE threads SET pinned_at = CURRENT_TIMESTAMP, pin_scope = 'global'
+WHERE title = 'Welcome to the Forum' AND parent_id IS NULL;
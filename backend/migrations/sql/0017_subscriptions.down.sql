@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS category_subscriptions;
+DROP TABLE IF EXISTS thread_subscriptions;
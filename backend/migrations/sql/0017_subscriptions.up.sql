@@ -0,0 +1,31 @@
+-- Threads a user follows, with how far they have read
+CREATE TABLE thread_subscriptions (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	thread_id INTEGER NOT NULL REFERENCES threads(id) ON DELETE CASCADE, -- Always a top-level thread
+	last_read_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, thread_id)
+);
+
+CREATE INDEX thread_subscriptions_thread_id_idx ON thread_subscriptions (thread_id);
+
+-- Categories whose new threads a user wants to hear about, subcategories included
+CREATE TABLE category_subscriptions (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	category_id INTEGER NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, category_id)
+);
+
+CREATE INDEX category_subscriptions_category_id_idx ON category_subscriptions (category_id);
+
+-- Authors follow their own threads, and commenters the threads they joined
+INSERT INTO thread_subscriptions (user_id, thread_id)
+WITH RECURSIVE posts AS (
+	SELECT id AS root_id, id, user_id FROM threads WHERE parent_id IS NULL
+	UNION ALL
+	SELECT posts.root_id, threads.id, threads.user_id
+	FROM threads
+	INNER JOIN posts ON threads.parent_id = posts.id
+)
+SELECT DISTINCT user_id, root_id FROM posts;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tag_subscriptions;
+DROP TABLE IF EXISTS user_follows;
@@ -0,0 +1,20 @@
+-- Users whose new threads show up in their followers' feeds
+CREATE TABLE user_follows (
+	follower_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	followee_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (follower_id, followee_id),
+	CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX user_follows_followee_id_idx ON user_follows (followee_id);
+
+-- Tags whose threads a user wants in their feed
+CREATE TABLE tag_subscriptions (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, tag_id)
+);
+
+CREATE INDEX tag_subscriptions_tag_id_idx ON tag_subscriptions (tag_id);
@@ -0,0 +1,301 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Who may start threads in a category
+const (
+	PostingEveryone = "everyone"
+	PostingAdmins   = "admins"
+)
+
+var (
+	ErrDuplicateCategory = errors.New("a category with this name or slug already exists")
+	ErrUnknownParent     = errors.New("parent category does not exist")
+	ErrCategoryCycle     = errors.New("a category cannot be placed below itself")
+	ErrCategoryInUse     = errors.New("category still has threads or subcategories")
+)
+
+// Category is a sub-forum threads are filed under
+type Category struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	Position    int    `json:"position"` // Display order among siblings
+	ParentID    *int   `json:"parentId"`
+	Posting     string `json:"posting"` // everyone or admins
+}
+
+// CategoryNode is a category with its subcategories and what they hold.
+// Counts and activity include every subcategory below it.
+type CategoryNode struct {
+	Category
+	ThreadCount    int             `json:"threadCount"`
+	LatestActivity *time.Time      `json:"latestActivity"` // Newest visible thread or comment
+	Children       []*CategoryNode `json:"children"`
+}
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Slugify derives a URL-friendly slug from a category name
+func Slugify(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
+
+// ValidSlug reports whether a slug has only lowercase letters and digits,
+// in runs joined by single dashes
+func ValidSlug(slug string) bool {
+	return slugPattern.MatchString(slug)
+}
+
+// Nests categories below their parents, ordered by position then name, and
+// rolls thread counts and latest activity up to every ancestor
+func buildCategoryTree(nodes []*CategoryNode) []*CategoryNode {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Position != nodes[j].Position {
+			return nodes[i].Position < nodes[j].Position
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+
+	byID := map[int]*CategoryNode{}
+	for _, node := range nodes {
+		node.Children = []*CategoryNode{}
+		byID[node.ID] = node
+	}
+	roots := []*CategoryNode{}
+	for _, node := range nodes {
+		if node.ParentID != nil {
+			if parent, ok := byID[*node.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	var rollUp func(node *CategoryNode)
+	rollUp = func(node *CategoryNode) {
+		for _, child := range node.Children {
+			rollUp(child)
+			node.ThreadCount += child.ThreadCount
+			if child.LatestActivity != nil && (node.LatestActivity == nil || child.LatestActivity.After(*node.LatestActivity)) {
+				node.LatestActivity = child.LatestActivity
+			}
+		}
+	}
+	for _, root := range roots {
+		rollUp(root)
+	}
+	return roots
+}
+
+// Reports unique violations on category names and slugs as ErrDuplicateCategory
+func categoryError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrDuplicateCategory
+	}
+	return err
+}
+
+const categoryColumns = "id, name, slug, description, position, parent_id, posting"
+
+// FetchCategoryTree retrieves every category nested below its parent, with
+// counts of visible threads and the time of the latest post in each
+func (s *PostgresStore) FetchCategoryTree() ([]*CategoryNode, error) {
+	rows, err := s.db.Query(`
+		WITH RECURSIVE posts AS (
+			SELECT id, category_id, created_at
+			FROM threads
+			WHERE parent_id IS NULL AND hidden_at IS NULL AND deleted_at IS NULL
+			UNION ALL
+			SELECT t.id, posts.category_id, t.created_at
+			FROM threads t
+			INNER JOIN posts ON t.parent_id = posts.id
+			WHERE t.hidden_at IS NULL AND t.deleted_at IS NULL
+		)
+		SELECT
+			c.id, c.name, c.slug, c.description, c.position, c.parent_id, c.posting,
+			(SELECT COUNT(*) FROM threads
+				WHERE threads.category_id = c.id AND threads.parent_id IS NULL
+					AND threads.hidden_at IS NULL AND threads.deleted_at IS NULL),
+			(SELECT MAX(posts.created_at) FROM posts WHERE posts.category_id = c.id)
+		FROM categories c
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %v", err)
+	}
+	defer rows.Close()
+
+	var nodes []*CategoryNode
+	for rows.Next() {
+		var node CategoryNode
+		var latest sql.NullTime
+		if err := rows.Scan(
+			&node.ID,
+			&node.Name,
+			&node.Slug,
+			&node.Description,
+			&node.Position,
+			&node.ParentID,
+			&node.Posting,
+			&node.ThreadCount,
+			&latest,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %v", err)
+		}
+		if latest.Valid {
+			node.LatestActivity = &latest.Time
+		}
+		nodes = append(nodes, &node)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over categories: %v", err)
+	}
+	return buildCategoryTree(nodes), nil
+}
+
+// FetchCategory looks a category up by its name or slug; nil if missing
+func (s *PostgresStore) FetchCategory(ref string) (*Category, error) {
+	var category Category
+	err := s.db.QueryRow(
+		"SELECT "+categoryColumns+" FROM categories WHERE name = $1 OR slug = $1 ORDER BY name = $1 DESC LIMIT 1",
+		ref,
+	).Scan(
+		&category.ID,
+		&category.Name,
+		&category.Slug,
+		&category.Description,
+		&category.Position,
+		&category.ParentID,
+		&category.Posting,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &category, err
+}
+
+// Checks that a category's new parent exists and is not the category itself
+// or one of its subcategories
+func checkCategoryParent(tx *sql.Tx, categoryID int, parentID *int) error {
+	if parentID == nil {
+		return nil
+	}
+	var exists, cycle bool
+	err := tx.QueryRow(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM categories WHERE id = $1
+			UNION ALL
+			SELECT categories.id, categories.parent_id
+			FROM categories
+			INNER JOIN ancestors ON categories.id = ancestors.parent_id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors), EXISTS (SELECT 1 FROM ancestors WHERE id = $2)
+	`, *parentID, categoryID).Scan(&exists, &cycle)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUnknownParent
+	}
+	if cycle {
+		return ErrCategoryCycle
+	}
+	return nil
+}
+
+// CreateCategory adds a category and returns its ID
+func (s *PostgresStore) CreateCategory(category Category) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := checkCategoryParent(tx, 0, category.ParentID); err != nil {
+		return 0, err
+	}
+	var categoryID int
+	err = tx.QueryRow(`
+		INSERT INTO categories (name, slug, description, position, parent_id, posting)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, category.Name, category.Slug, category.Description, category.Position, category.ParentID, category.Posting).Scan(&categoryID)
+	if err != nil {
+		return 0, categoryError(err)
+	}
+	return categoryID, tx.Commit()
+}
+
+// UpdateCategory replaces a category's details. Returns false if the
+// category does not exist.
+func (s *PostgresStore) UpdateCategory(category Category) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if err := checkCategoryParent(tx, category.ID, category.ParentID); err != nil {
+		return false, err
+	}
+	result, err := tx.Exec(`
+		UPDATE categories
+		SET name = $2, slug = $3, description = $4, position = $5, parent_id = $6, posting = $7
+		WHERE id = $1
+	`, category.ID, category.Name, category.Slug, category.Description, category.Position, category.ParentID, category.Posting)
+	if err != nil {
+		return false, categoryError(err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// DeleteCategory removes an empty category. Returns ErrCategoryInUse while
+// threads or subcategories remain in it, and false if it does not exist.
+func (s *PostgresStore) DeleteCategory(categoryID int) (bool, error) {
+	var inUse bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM threads WHERE category_id = $1)
+			OR EXISTS (SELECT 1 FROM categories WHERE parent_id = $1)
+	`, categoryID).Scan(&inUse)
+	if err != nil {
+		return false, err
+	}
+	if inUse {
+		return false, ErrCategoryInUse
+	}
+
+	result, err := s.db.Exec("DELETE FROM categories WHERE id = $1", categoryID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
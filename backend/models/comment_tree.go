@@ -0,0 +1,186 @@
+package models
+
+import (
+	"backend/markdown"
+	"database/sql"
+	"fmt"
+)
+
+// CommentTreeQuery selects one page of replies below a thread or comment
+type CommentTreeQuery struct {
+	SortBy string // Any thread ranking but relevance; applied within each sibling group
+	Order  string // desc (default) or asc
+	Limit  int    // Replies shown per sibling group
+	Offset int    // Direct replies to skip, for loading more of them
+	Depth  int    // Levels of replies to include below the parent
+}
+
+// CommentNode is a comment with the first page of its own replies
+type CommentNode struct {
+	Thread
+	Replies        []*CommentNode `json:"replies"`
+	MoreReplies    int            `json:"moreReplies"`              // Direct replies not on this page
+	ContinueThread bool           `json:"continueThread,omitempty"` // Replies exist below the depth cutoff
+}
+
+// A comment placed in the tree with what its siblings need to be paged
+type commentTreeRow struct {
+	Thread
+	level      int  // 1 for direct replies to the parent
+	siblings   int  // Replies sharing this comment's parent
+	hasReplies bool // Visible replies exist, loaded or not
+}
+
+// Checks the query and fills in defaults, keeping limits in range
+func (q CommentTreeQuery) normalize() (CommentTreeQuery, error) {
+	if _, ok := rankings[q.SortBy]; !ok {
+		q.SortBy = "created_at"
+	}
+	switch q.Order {
+	case "":
+		q.Order = "desc"
+	case "asc", "desc":
+	default:
+		return q, ErrInvalidOrder
+	}
+	if q.Limit < 1 || q.Limit > 100 {
+		q.Limit = 20
+	}
+	q.Offset = max(q.Offset, 0)
+	if q.Depth < 1 || q.Depth > MaxCommentDepth {
+		q.Depth = MaxCommentDepth
+	}
+	return q, nil
+}
+
+// Assembles rows ordered by level, then by position among their siblings.
+// Returns the parent's direct replies and how many of them are left to load.
+func buildCommentTree(parentID int, rows []commentTreeRow, query CommentTreeQuery) ([]*CommentNode, int) {
+	top := []*CommentNode{}
+	more := 0
+	nodes := map[int]*CommentNode{}
+	for _, row := range rows {
+		node := &CommentNode{Thread: row.Thread, Replies: []*CommentNode{}}
+		node.ContinueThread = row.level == query.Depth && row.hasReplies
+		node.Edited = node.UpdatedAt != nil
+		node.Redact()
+		node.ContentHTML = markdown.Render(node.Content)
+
+		if *row.ParentID == parentID {
+			top = append(top, node)
+			more = row.siblings - query.Offset - len(top)
+		} else if parent, ok := nodes[*row.ParentID]; ok {
+			parent.Replies = append(parent.Replies, node)
+			parent.MoreReplies = row.siblings - len(parent.Replies)
+		} else {
+			continue // An ancestor fell outside its page
+		}
+		nodes[node.ID] = node
+	}
+	return top, more
+}
+
+// Shown in the tree: live comments, and tombstones with a live comment
+// anywhere below them, however many tombstones lie in between
+const visibleComment = `(t.deleted_at IS NULL OR EXISTS (
+	WITH RECURSIVE below AS (
+		SELECT id, deleted_at FROM threads WHERE parent_id = t.id
+		UNION ALL
+		SELECT c.id, c.deleted_at FROM threads c INNER JOIN below ON c.parent_id = below.id
+	)
+	SELECT 1 FROM below WHERE deleted_at IS NULL
+))`
+
+// FetchCommentTree retrieves a page of replies below a thread or comment as a
+// nested tree. Each sibling group is ranked and paged on its own; only the
+// parent's direct replies honour the offset.
+func (s *PostgresStore) FetchCommentTree(parentID int, query CommentTreeQuery) ([]*CommentNode, int, error) {
+	query, err := query.normalize()
+	if err != nil {
+		return nil, 0, err
+	}
+	listing := ThreadListing{Order: query.Order}
+
+	// The subtree is aliased as threads so that ranking expressions apply to it
+	rows, err := s.db.Query(fmt.Sprintf(`
+		WITH RECURSIVE subtree AS (
+			SELECT t.*, 1 AS level
+			FROM threads t
+			WHERE t.parent_id = $1 AND %[1]s
+			UNION ALL
+			SELECT t.*, subtree.level + 1
+			FROM threads t
+			INNER JOIN subtree ON t.parent_id = subtree.id
+			WHERE subtree.level < $2 AND %[1]s
+		), ranked AS (
+			SELECT
+				threads.*,
+				ROW_NUMBER() OVER (PARTITION BY threads.parent_id ORDER BY %[2]s %[3]s, threads.id %[3]s) AS position,
+				COUNT(*) OVER (PARTITION BY threads.parent_id) AS siblings
+			FROM subtree AS threads
+		)
+		SELECT
+			ranked.id,
+			ranked.content,
+			users.username AS author,
+			ranked.created_at,
+			ranked.updated_at,
+			ranked.user_id,
+			ranked.parent_id,
+			ranked.likes_count,
+			ranked.dislikes_count,
+			ranked.comments_count,
+			ranked.score,
+			ranked.depth,
+			ranked.hidden_at IS NOT NULL,
+			ranked.deleted_at IS NOT NULL,
+			ranked.level,
+			ranked.siblings,
+			EXISTS (SELECT 1 FROM threads t WHERE t.parent_id = ranked.id AND %[1]s)
+		FROM ranked
+		LEFT JOIN users ON ranked.user_id = users.id
+		WHERE ranked.position > CASE WHEN ranked.level = 1 THEN $3 ELSE 0 END
+			AND ranked.position <= CASE WHEN ranked.level = 1 THEN $3 ELSE 0 END + $4
+		ORDER BY ranked.level, ranked.position
+	`, visibleComment, rankings[query.SortBy].expr, listing.direction()),
+		parentID, query.Depth, query.Offset, query.Limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var treeRows []commentTreeRow
+	for rows.Next() {
+		var row commentTreeRow
+		var author sql.NullString
+		if err := rows.Scan(
+			&row.ID,
+			&row.Content,
+			&author,
+			&row.CreatedAt,
+			&row.UpdatedAt,
+			&row.UserID,
+			&row.ParentID,
+			&row.LikesCount,
+			&row.DislikesCount,
+			&row.CommentsCount,
+			&row.Score,
+			&row.Depth,
+			&row.Hidden,
+			&row.Deleted,
+			&row.level,
+			&row.siblings,
+			&row.hasReplies,
+		); err != nil {
+			return nil, 0, err
+		}
+		row.Author = author.String
+		treeRows = append(treeRows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	top, more := buildCommentTree(parentID, treeRows, query)
+	return top, more, nil
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidCursor         = errors.New("invalid cursor")
+	ErrCursorMismatch        = errors.New("cursor was issued for a different sort order")
+	ErrCursorUnsupportedSort = errors.New("sort order does not support cursor pagination")
+)
+
+// Sort options that support keyset pagination, mapped to listing columns
+var cursorSortColumns = map[string]string{
+	"created_at": "created_at",
+	"likes":      "likes_count",
+	"dislikes":   "dislikes_count",
+	"comments":   "comments_count",
+	"top":        "score",
+}
+
+// ThreadCursor marks a position in a sorted thread listing
+type ThreadCursor struct {
+	SortBy   string `json:"s"`
+	Window   string `json:"w,omitempty"`
+	Order    string `json:"o"`
+	Key      string `json:"k"` // Sort key of the boundary row, as text
+	ID       int    `json:"i"` // Tiebreaker for rows with equal keys
+	Backward bool   `json:"b,omitempty"`
+}
+
+// Reports whether the cursor was issued for the same ranking as a
+// normalized listing
+func (cursor *ThreadCursor) matches(listing ThreadListing) bool {
+	return cursor.SortBy == listing.SortBy && cursor.Window == listing.Window && cursor.Order == listing.Order
+}
+
+// Builds the cursors around a fetched page. Going forwards there is a previous
+// page whenever we started from a cursor; going backwards there is always a
+// next page (the one we came from).
+func pageCursors(listing ThreadListing, threads []Thread, cursor *ThreadCursor, hasMore bool) (string, string) {
+	if len(threads) == 0 {
+		return "", ""
+	}
+
+	backward := cursor != nil && cursor.Backward
+	boundary := func(thread Thread, backward bool) string {
+		return EncodeCursor(ThreadCursor{
+			SortBy:   listing.SortBy,
+			Window:   listing.Window,
+			Order:    listing.Order,
+			Key:      threadSortKey(thread, listing.SortBy),
+			ID:       thread.ID,
+			Backward: backward,
+		})
+	}
+
+	var next, prev string
+	if hasMore || backward {
+		next = boundary(threads[len(threads)-1], false)
+	}
+	if (backward && hasMore) || (!backward && cursor != nil) {
+		prev = boundary(threads[0], true)
+	}
+	return next, prev
+}
+
+// EncodeCursor serializes and signs a cursor so clients cannot forge positions
+func EncodeCursor(cursor ThreadCursor) string {
+	payload, _ := json.Marshal(cursor)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + signCursor(encoded)
+}
+
+// DecodeCursor verifies and parses a cursor produced by EncodeCursor
+func DecodeCursor(token string) (*ThreadCursor, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(signCursor(encoded))) {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor ThreadCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, ok := cursorSortColumns[cursor.SortBy]; !ok {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// Signs with CURSOR_SECRET, falling back to the JWT secret
+func signCursor(encoded string) string {
+	secret := os.Getenv("CURSOR_SECRET")
+	if secret == "" {
+		secret = os.Getenv("JWT_SECRET_KEY")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Extracts the value a thread is sorted by, as stored in a cursor
+func threadSortKey(thread Thread, sortBy string) string {
+	switch sortBy {
+	case "likes":
+		return strconv.Itoa(thread.LikesCount)
+	case "dislikes":
+		return strconv.Itoa(thread.DislikesCount)
+	case "comments":
+		return strconv.Itoa(thread.CommentsCount)
+	case "top":
+		return strconv.Itoa(thread.Score)
+	default:
+		return thread.CreatedAt
+	}
+}
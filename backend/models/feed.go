@@ -0,0 +1,141 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Why a thread made it into someone's feed
+const (
+	FeedFollowedUser = "followed_user" // Started by a user they follow
+	FeedCategory     = "category"      // In a category they subscribe to, or below it
+	FeedTag          = "tag"           // Under a tag they subscribe to
+	FeedSimilar      = "similar"       // Shares a tag with a thread they liked or saved
+)
+
+// FeedThread is a thread in a personalized feed with every reason it was
+// picked, in the order of the constants above
+type FeedThread struct {
+	Thread
+	Reasons []string `json:"reasons"`
+}
+
+// Feeds run newest first; their cursors share the format of the thread
+// listing cursors for this order
+var feedListing = ThreadListing{SortBy: "created_at", Order: "desc"}
+
+// Checks that a cursor was issued by a feed. Feeds only page forwards.
+func checkFeedCursor(cursor *ThreadCursor) error {
+	if cursor != nil && (!cursor.matches(feedListing) || cursor.Backward) {
+		return ErrCursorMismatch
+	}
+	return nil
+}
+
+// Builds the cursor to the page after one ending in the given thread
+func feedCursor(last FeedThread) string {
+	return EncodeCursor(ThreadCursor{
+		SortBy: feedListing.SortBy,
+		Order:  feedListing.Order,
+		Key:    threadSortKey(last.Thread, feedListing.SortBy),
+		ID:     last.ID,
+	})
+}
+
+// FetchFeed retrieves one page of visible threads picked for a user, newest
+// first, each once whatever the number of reasons. Threads the user has
+// already seen are left out: their own, and any they voted on, saved or
+// subscribed to, which covers those they commented in. Returns the cursor
+// to the next page, empty on the last one.
+func (s *PostgresStore) FetchFeed(userID, limit int, cursor *ThreadCursor) ([]FeedThread, string, error) {
+	if err := checkFeedCursor(cursor); err != nil {
+		return nil, "", err
+	}
+
+	conditions := []string{
+		"threads.hidden_at IS NULL",
+		"threads.deleted_at IS NULL",
+		"threads.user_id <> $1",
+		"NOT EXISTS (SELECT 1 FROM votes WHERE votes.thread_id = threads.id AND votes.user_id = $1)",
+		"NOT EXISTS (SELECT 1 FROM saved_threads WHERE saved_threads.thread_id = threads.id AND saved_threads.user_id = $1)",
+		"NOT EXISTS (SELECT 1 FROM thread_subscriptions WHERE thread_subscriptions.thread_id = threads.id AND thread_subscriptions.user_id = $1)",
+	}
+	params := []interface{}{userID}
+	keyset := ""
+	if cursor != nil {
+		keyset = "AND (listing.created_at, listing.id) < ($2, $3)"
+		params = append(params, cursor.Key, cursor.ID)
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	query := fmt.Sprintf(`
+		WITH RECURSIVE followed_categories AS (
+			SELECT category_id AS id FROM category_subscriptions WHERE user_id = $1
+			UNION
+			SELECT categories.id
+			FROM categories
+			INNER JOIN followed_categories ON categories.parent_id = followed_categories.id
+		),
+		liked_tags AS (
+			SELECT thread_tags.tag_id
+			FROM thread_tags
+			WHERE thread_tags.thread_id IN (
+				SELECT thread_id FROM votes WHERE user_id = $1 AND value = 1
+				UNION
+				SELECT thread_id FROM saved_threads WHERE user_id = $1
+			)
+		)
+		SELECT listing.*, feed.reasons
+		FROM (%s) AS listing
+		INNER JOIN threads t ON t.id = listing.id
+		CROSS JOIN LATERAL (
+			SELECT ARRAY_REMOVE(ARRAY[
+				CASE WHEN EXISTS (
+					SELECT 1 FROM user_follows WHERE follower_id = $1 AND followee_id = t.user_id
+				) THEN 'followed_user' END,
+				CASE WHEN t.category_id IN (SELECT id FROM followed_categories) THEN 'category' END,
+				CASE WHEN EXISTS (
+					SELECT 1
+					FROM thread_tags
+					INNER JOIN tag_subscriptions ON tag_subscriptions.tag_id = thread_tags.tag_id
+					WHERE thread_tags.thread_id = t.id AND tag_subscriptions.user_id = $1
+				) THEN 'tag' END,
+				CASE WHEN EXISTS (
+					SELECT 1 FROM thread_tags
+					WHERE thread_tags.thread_id = t.id AND thread_tags.tag_id IN (SELECT tag_id FROM liked_tags)
+				) THEN 'similar' END
+			], NULL) AS reasons
+		) AS feed
+		WHERE cardinality(feed.reasons) > 0
+		%s
+		ORDER BY listing.created_at DESC, listing.id DESC
+		LIMIT $%d
+	`, fmt.Sprintf(threadListQuery, joinConditions(conditions)), keyset, len(params)+1)
+	params = append(params, limit+1)
+
+	rows, err := s.db.Query(query, params...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	threads := []FeedThread{}
+	for rows.Next() {
+		var thread FeedThread
+		thread.Thread, err = scanThreadListRow(rows, pq.Array(&thread.Reasons))
+		if err != nil {
+			return nil, "", err
+		}
+		threads = append(threads, thread)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(threads) <= limit {
+		return threads, "", nil
+	}
+	threads = threads[:limit]
+	return threads, feedCursor(threads[limit-1]), nil
+}
@@ -5,91 +5,191 @@ import (
 	"errors"
 )
 
-// GetInteractionState retrieves whether the user liked or disliked a thread
-func GetInteractionState(db *sql.DB, threadID, userID int) (bool, bool, error) {
-	var liked, disliked bool
+var ErrThreadNotFound = errors.New("thread not found")
+
+// Vote is one user's current vote on a thread or comment
+type Vote struct {
+	ThreadID  int     `json:"threadId"`
+	Title     *string `json:"title,omitempty"` // Nullable for comments
+	ParentID  *int    `json:"parentId,omitempty"`
+	Value     int     `json:"value"` // +1 or -1
+	CreatedAt string  `json:"createdAt"`
+	UpdatedAt string  `json:"updatedAt"`
+}
 
-	// Check like state
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM likes WHERE thread_id = $1 AND user_id = $2)", threadID, userID).Scan(&liked)
-	if err != nil {
-		return false, false, err
-	}
+var ErrInvalidVote = errors.New("vote must be -1, 0 or 1")
 
-	// Check dislike state
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM dislikes WHERE thread_id = $1 AND user_id = $2)", threadID, userID).Scan(&disliked)
+// GetInteractionState retrieves whether the user liked or disliked a thread
+func (s *PostgresStore) GetInteractionState(threadID, userID int) (bool, bool, error) {
+	value, err := s.GetVote(threadID, userID)
 	if err != nil {
 		return false, false, err
 	}
+	return value == 1, value == -1, nil
+}
 
-	return liked, disliked, nil
+// GetVote retrieves a user's vote on a thread, 0 if they have not voted
+func (s *PostgresStore) GetVote(threadID, userID int) (int, error) {
+	var value int
+	err := s.db.QueryRow("SELECT value FROM votes WHERE thread_id = $1 AND user_id = $2", threadID, userID).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return value, err
 }
 
 // GetLikesCount retrieves the total number of likes for a thread
-func GetLikesCount(db *sql.DB, threadID int) (int, error) {
+func (s *PostgresStore) GetLikesCount(threadID int) (int, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM likes WHERE thread_id = $1", threadID).Scan(&count)
+	err := s.db.QueryRow("SELECT likes_count FROM threads WHERE id = $1", threadID).Scan(&count)
 	return count, err
 }
 
 // GetDislikesCount retrieves the total number of dislikes for a thread
-func GetDislikesCount(db *sql.DB, threadID int) (int, error) {
+func (s *PostgresStore) GetDislikesCount(threadID int) (int, error) {
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM dislikes WHERE thread_id = $1", threadID).Scan(&count)
+	err := s.db.QueryRow("SELECT dislikes_count FROM threads WHERE id = $1", threadID).Scan(&count)
 	return count, err
 }
 
 // AddLike adds a like for a thread and removes any existing dislike
-func AddLike(db *sql.DB, threadID, userID int) error {
-	// Remove dislike
-	_, err := db.Exec("DELETE FROM dislikes WHERE thread_id = $1 AND user_id = $2", threadID, userID)
+func (s *PostgresStore) AddLike(threadID, userID int) error {
+	return s.Vote(threadID, userID, 1)
+}
+
+// AddDislike adds a dislike for a thread and removes any existing like
+func (s *PostgresStore) AddDislike(threadID, userID int) error {
+	return s.Vote(threadID, userID, -1)
+}
+
+// RemoveLike removes a like for a thread
+func (s *PostgresStore) RemoveLike(threadID, userID int) error {
+	return s.changeVote(threadID, userID, 0, 1)
+}
+
+// RemoveDislike removes a dislike for a thread
+func (s *PostgresStore) RemoveDislike(threadID, userID int) error {
+	return s.changeVote(threadID, userID, 0, -1)
+}
+
+// Vote sets a user's vote on a thread to +1 or -1, or clears it with 0
+func (s *PostgresStore) Vote(threadID, userID, value int) error {
+	return s.changeVote(threadID, userID, value, 0)
+}
+
+// Replaces a user's vote and updates the thread's counters in one
+// transaction. A non-zero only restricts the change to votes of that value.
+func (s *PostgresStore) changeVote(threadID, userID, value, only int) error {
+	if value < -1 || value > 1 {
+		return ErrInvalidVote
+	}
+
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	// Serialize votes on this thread so concurrent clicks apply one at a time.
+	// Deleted and hidden posts take no votes.
+	var locked int
+	err = tx.QueryRow(
+		"SELECT id FROM threads WHERE id = $1 AND deleted_at IS NULL AND hidden_at IS NULL FOR UPDATE",
+		threadID,
+	).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return ErrThreadNotFound
+	} else if err != nil {
+		return err
+	}
 
-	// Add like
-	_, err = db.Exec("INSERT INTO likes (thread_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", threadID, userID)
-	return err
-}
+	var previous int
+	err = tx.QueryRow("SELECT value FROM votes WHERE thread_id = $1 AND user_id = $2", threadID, userID).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if previous == value || (only != 0 && previous != only) {
+		return tx.Commit()
+	}
 
-// AddDislike adds a dislike for a thread and removes any existing like
-func AddDislike(db *sql.DB, threadID, userID int) error {
-	// Remove like
-	_, err := db.Exec("DELETE FROM likes WHERE thread_id = $1 AND user_id = $2", threadID, userID)
+	if value == 0 {
+		_, err = tx.Exec("DELETE FROM votes WHERE thread_id = $1 AND user_id = $2", threadID, userID)
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO votes (thread_id, user_id, value) VALUES ($1, $2, $3)
+			ON CONFLICT (thread_id, user_id) DO UPDATE
+			SET value = EXCLUDED.value, updated_at = CURRENT_TIMESTAMP
+		`, threadID, userID, value)
+	}
 	if err != nil {
 		return err
 	}
 
-	// Add dislike
-	_, err = db.Exec("INSERT INTO dislikes (thread_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", threadID, userID)
-	return err
+	if previous != 0 {
+		if err := adjustCounter(tx, threadID, voteCounter(previous), -1); err != nil {
+			return err
+		}
+	}
+	if value != 0 {
+		if err := adjustCounter(tx, threadID, voteCounter(value), 1); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-// RemoveLike removes a like for a thread
-func RemoveLike(db *sql.DB, threadID, userID int) error {
-	_, err := db.Exec("DELETE FROM likes WHERE thread_id = $1 AND user_id = $2", threadID, userID)
-	return err
+// Names the thread counter that tracks votes of the given value
+func voteCounter(value int) string {
+	if value > 0 {
+		return "likes_count"
+	}
+	return "dislikes_count"
 }
 
-// RemoveDislike removes a dislike for a thread
-func RemoveDislike(db *sql.DB, threadID, userID int) error {
-	_, err := db.Exec("DELETE FROM dislikes WHERE thread_id = $1 AND user_id = $2", threadID, userID)
-	return err
+// FetchUserVotes lists a user's votes, most recently changed first
+func (s *PostgresStore) FetchUserVotes(userID int) ([]Vote, error) {
+	rows, err := s.db.Query(`
+		SELECT votes.thread_id, threads.title, threads.parent_id, votes.value, votes.created_at, votes.updated_at
+		FROM votes
+		INNER JOIN threads ON votes.thread_id = threads.id
+		WHERE votes.user_id = $1
+		ORDER BY votes.updated_at DESC, votes.thread_id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	votes := []Vote{}
+	for rows.Next() {
+		var vote Vote
+		if err := rows.Scan(&vote.ThreadID, &vote.Title, &vote.ParentID, &vote.Value, &vote.CreatedAt, &vote.UpdatedAt); err != nil {
+			return nil, err
+		}
+		votes = append(votes, vote)
+	}
+	return votes, rows.Err()
 }
 
-// CheckThreadExists verifies if a thread exists in the database
-func CheckThreadExists(db *sql.DB, threadID int) error {
+// CheckThreadExists verifies if a thread exists in the database and is
+// neither deleted nor hidden
+func (s *PostgresStore) CheckThreadExists(threadID int) error {
 	var exists int
-	err := db.QueryRow("SELECT COUNT(*) FROM threads WHERE id = $1", threadID).Scan(&exists)
+	err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM threads WHERE id = $1 AND deleted_at IS NULL AND hidden_at IS NULL",
+		threadID,
+	).Scan(&exists)
 	if err != nil || exists == 0 {
-		return errors.New("thread not found")
+		return ErrThreadNotFound
 	}
 	return nil
 }
 
 // FetchSaveState retrieves the save state of a thread for a user
-func FetchSaveState(db *sql.DB, threadID, userID int) (bool, error) {
+func (s *PostgresStore) FetchSaveState(threadID, userID int) (bool, error) {
 	var saveState bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM saved_threads WHERE user_id = $1 AND thread_id = $2)", userID, threadID).Scan(&saveState)
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM saved_threads WHERE user_id = $1 AND thread_id = $2)", userID, threadID).Scan(&saveState)
 	if err != nil {
 		return false, err
 	}
@@ -97,13 +197,13 @@ func FetchSaveState(db *sql.DB, threadID, userID int) (bool, error) {
 }
 
 // SaveThread saves a thread for a user
-func SaveThread(db *sql.DB, threadID, userID int) error {
-	_, err := db.Exec("INSERT INTO saved_threads (user_id, thread_id) VALUES ($1, $2)", userID, threadID)
+func (s *PostgresStore) SaveThread(threadID, userID int) error {
+	_, err := s.db.Exec("INSERT INTO saved_threads (user_id, thread_id) VALUES ($1, $2)", userID, threadID)
 	return err
 }
 
 // UnsaveThread removes a saved thread for a user
-func UnsaveThread(db *sql.DB, threadID, userID int) error {
-	_, err := db.Exec("DELETE FROM saved_threads WHERE user_id = $1 AND thread_id = $2", userID, threadID)
+func (s *PostgresStore) UnsaveThread(threadID, userID int) error {
+	_, err := s.db.Exec("DELETE FROM saved_threads WHERE user_id = $1 AND thread_id = $2", userID, threadID)
 	return err
 }
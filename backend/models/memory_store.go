@@ -0,0 +1,2589 @@
+package models
+
+import (
+	"backend/markdown"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements every store in process memory. It is meant for
+// tests and local experiments; full-text search is approximated by word matching.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	users      map[int]*memoryUser
+	threads    map[int]*memoryThread
+	categories []Category
+	tags       []Classifier
+	votes      map[memoryKey]*memoryVote
+	saved      map[memoryKey]time.Time
+
+	refreshTokens map[string]*memoryRefreshToken
+	revokedTokens map[string]time.Time
+
+	notifications []*memoryNotification
+	preferences   map[int]map[string]bool
+
+	mentions map[int]map[int]bool // Thread ID to mentioned user IDs
+
+	moderationRules []*memoryModerationRule
+	moderationFlags map[memoryFlagKey]time.Time
+
+	reports           []*memoryReport
+	moderationActions []*memoryModerationAction
+
+	revisions []*memoryRevision
+
+	threadSubscriptions   map[memoryKey]*memorySubscription
+	categorySubscriptions map[memoryCategoryKey]time.Time
+	tagSubscriptions      map[memoryTagKey]time.Time
+	follows               map[memoryFollowKey]time.Time
+
+	nextID int
+}
+
+type memoryUser struct {
+	ID                 int
+	Username           string
+	Password           string
+	Bio                string
+	IsAdmin            bool
+	CreatedAt          time.Time
+	SessionsValidAfter time.Time
+}
+
+type memoryThread struct {
+	ID            int
+	Title         *string
+	Content       string
+	CategoryID    int
+	TagIDs        []int // In the order the author gave them
+	UserID        int
+	ParentID      *int
+	CreatedAt     time.Time
+	Depth         int
+	LikesCount    int
+	DislikesCount int
+	CommentsCount int
+	HiddenAt      *time.Time
+	DeletedAt     *time.Time
+	DeletedBy     *int
+	UpdatedAt     *time.Time
+	PinnedAt      *time.Time
+	PinScope      string
+	LockedAt      *time.Time
+	Announcement  bool
+}
+
+type memoryRefreshToken struct {
+	ID        int
+	UserID    int
+	ExpiresAt time.Time
+	Revoked   bool
+}
+
+type memoryNotification struct {
+	NewNotification
+	ID        int
+	CreatedAt time.Time
+	Read      bool
+}
+
+type memoryModerationRule struct {
+	ModerationRule
+	CreatedByID int
+	Created     time.Time
+}
+
+// Identifies a post flagged under a moderation rule
+type memoryFlagKey struct {
+	ThreadID int
+	RuleID   int
+}
+
+type memoryReport struct {
+	ID         int
+	ThreadID   int
+	ReporterID int
+	Reason     string
+	Details    *string
+	CreatedAt  time.Time
+	ResolvedAt *time.Time
+}
+
+type memoryModerationAction struct {
+	ModerationAction
+	AuthorID    int
+	ModeratorID int
+	Created     time.Time
+}
+
+type memoryVote struct {
+	Value     int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Identifies a (thread, user) pair for votes, saves and subscriptions
+type memoryKey struct {
+	ThreadID int
+	UserID   int
+}
+
+// Identifies a (category, user) pair for category subscriptions
+type memoryCategoryKey struct {
+	CategoryID int
+	UserID     int
+}
+
+// Identifies a (tag, user) pair for tag subscriptions
+type memoryTagKey struct {
+	TagID  int
+	UserID int
+}
+
+// Identifies a user following another
+type memoryFollowKey struct {
+	FollowerID int
+	FolloweeID int
+}
+
+type memorySubscription struct {
+	LastReadAt time.Time
+	CreatedAt  time.Time
+}
+
+// NewMemoryStore returns an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:         map[int]*memoryUser{},
+		threads:       map[int]*memoryThread{},
+		votes:         map[memoryKey]*memoryVote{},
+		saved:         map[memoryKey]time.Time{},
+		refreshTokens: map[string]*memoryRefreshToken{},
+		revokedTokens: map[string]time.Time{},
+		preferences:   map[int]map[string]bool{},
+		mentions:      map[int]map[int]bool{},
+
+		moderationFlags: map[memoryFlagKey]time.Time{},
+
+		threadSubscriptions:   map[memoryKey]*memorySubscription{},
+		categorySubscriptions: map[memoryCategoryKey]time.Time{},
+		tagSubscriptions:      map[memoryTagKey]time.Time{},
+		follows:               map[memoryFollowKey]time.Time{},
+
+		// The categories the seed migration creates
+		categories: []Category{
+			{ID: 1, Name: "Featured", Slug: "featured", Position: 1, Posting: PostingAdmins},
+			{ID: 2, Name: "Coursework", Slug: "coursework", Position: 2, Posting: PostingEveryone},
+			{ID: 3, Name: "Events", Slug: "events", Position: 3, Posting: PostingEveryone},
+			{ID: 4, Name: "Community", Slug: "community", Position: 4, Posting: PostingEveryone},
+		},
+	}
+}
+
+// Returns a fresh ID; callers must hold the write lock
+func (m *MemoryStore) newID() int {
+	m.nextID++
+	return m.nextID
+}
+
+// Formats timestamps the way database/sql renders them into strings
+func memoryTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// --- Lookups (callers hold the lock) ---
+
+func (m *MemoryStore) userByName(username string) *memoryUser {
+	for _, user := range m.users {
+		if user.Username == username {
+			return user
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) categoryByID(id int) *Category {
+	for i := range m.categories {
+		if m.categories[i].ID == id {
+			return &m.categories[i]
+		}
+	}
+	return nil
+}
+
+// Finds a category by name, or by slug when no name matches
+func (m *MemoryStore) categoryByRef(ref string) *Category {
+	var bySlug *Category
+	for i := range m.categories {
+		if m.categories[i].Name == ref {
+			return &m.categories[i]
+		}
+		if m.categories[i].Slug == ref {
+			bySlug = &m.categories[i]
+		}
+	}
+	return bySlug
+}
+
+// Reports whether a category is the referenced one or one of its subcategories
+func (m *MemoryStore) inCategory(categoryID int, ref string) bool {
+	for category := m.categoryByID(categoryID); category != nil; {
+		if category.Name == ref || category.Slug == ref {
+			return true
+		}
+		if category.ParentID == nil {
+			break
+		}
+		category = m.categoryByID(*category.ParentID)
+	}
+	return false
+}
+
+func (m *MemoryStore) classifierName(list []Classifier, id int) *string {
+	for _, item := range list {
+		if item.ID == id {
+			name := item.Name
+			return &name
+		}
+	}
+	return nil
+}
+
+// Finds a tag by name; 0 when missing
+func (m *MemoryStore) classifierID(list []Classifier, name string) int {
+	for _, item := range list {
+		if item.Name == name {
+			return item.ID
+		}
+	}
+	return 0
+}
+
+// Resolves a tag by name, creating it when missing
+func (m *MemoryStore) resolveClassifier(list *[]Classifier, name string) int {
+	if id := m.classifierID(*list, name); id != 0 {
+		return id
+	}
+	id := 1
+	for _, item := range *list {
+		id = max(id, item.ID+1)
+	}
+	*list = append(*list, Classifier{ID: id, Name: name})
+	return id
+}
+
+// Builds the API view of a stored thread
+func (m *MemoryStore) toThread(t *memoryThread) Thread {
+	thread := Thread{
+		ID:            t.ID,
+		Title:         t.Title,
+		Content:       t.Content,
+		CreatedAt:     memoryTimestamp(t.CreatedAt),
+		UserID:        t.UserID,
+		ParentID:      t.ParentID,
+		LikesCount:    t.LikesCount,
+		DislikesCount: t.DislikesCount,
+		CommentsCount: t.CommentsCount,
+		Score:         t.LikesCount - t.DislikesCount,
+		Depth:         t.Depth,
+		Hidden:        t.HiddenAt != nil,
+		Deleted:       t.DeletedAt != nil,
+		Edited:        t.UpdatedAt != nil,
+		Pinned:        t.PinScope,
+		Locked:        t.LockedAt != nil,
+		Announcement:  t.Announcement,
+	}
+	if category := m.categoryByID(t.CategoryID); category != nil {
+		name := category.Name
+		thread.Category = &name
+	}
+	if t.Title != nil {
+		var tags []string
+		for _, tagID := range t.TagIDs {
+			if name := m.classifierName(m.tags, tagID); name != nil {
+				tags = append(tags, *name)
+			}
+		}
+		thread.setTags(tags)
+	}
+	if t.UpdatedAt != nil {
+		updatedAt := memoryTimestamp(*t.UpdatedAt)
+		thread.UpdatedAt = &updatedAt
+	}
+	if author, ok := m.users[t.UserID]; ok {
+		thread.Author = author.Username
+	}
+	if t.ParentID != nil {
+		if parent, ok := m.threads[*t.ParentID]; ok {
+			if parentAuthor, ok := m.users[parent.UserID]; ok {
+				name := parentAuthor.Username
+				thread.ParentAuthor = &name
+			}
+		}
+	}
+	return thread
+}
+
+// Reports whether a thread's tags include any or all of the wanted ones
+func matchesTags(tags, wanted []string, match string) bool {
+	found := 0
+	for _, tag := range wanted {
+		if slices.Contains(tags, tag) {
+			found++
+		}
+	}
+	if match == "all" {
+		return found == len(wanted)
+	}
+	return found > 0
+}
+
+// Lists top-level threads matching the same filters as threadFilters
+func (m *MemoryStore) filteredThreads(listing ThreadListing, now time.Time) []Thread {
+	tsQuery := BuildTSQuery(listing.Query)
+	window, windowed := rankingWindows[listing.Window]
+	var threads []Thread
+	for _, t := range m.threads {
+		if t.Title == nil || t.HiddenAt != nil || t.DeletedAt != nil {
+			continue
+		}
+		thread := m.toThread(t)
+		if tsQuery != "" && !matchesTSQuery(*t.Title+" "+t.Content, tsQuery) {
+			continue
+		}
+		if len(listing.Tags) > 0 && !matchesTags(thread.Tags, listing.Tags, listing.TagMatch) {
+			continue
+		}
+		if listing.Category != "" && !m.inCategory(t.CategoryID, listing.Category) {
+			continue
+		}
+		if windowed && t.CreatedAt.Before(now.Add(-window.duration)) {
+			continue
+		}
+		threads = append(threads, thread)
+	}
+	return threads
+}
+
+// Returns whether a sorts strictly before b in a listing, using IDs to break ties
+func rankedBefore(listing ThreadListing, now time.Time) func(a, b Thread) bool {
+	rank, ok := rankings[listing.SortBy]
+	if !ok {
+		rank = rankings["created_at"]
+	}
+	return func(a, b Thread) bool {
+		keyA, keyB := rank.score(a, now), rank.score(b, now)
+		if keyA == keyB {
+			keyA, keyB = float64(a.ID), float64(b.ID)
+		}
+		if listing.Order == "asc" {
+			return keyA < keyB
+		}
+		return keyA > keyB
+	}
+}
+
+// Orders threads the way a listing ranks them
+// Separates the pinned threads leading a listing, most recently pinned
+// first, from the rest; callers hold the lock
+func (m *MemoryStore) splitPinned(threads []Thread, listing ThreadListing) ([]Thread, []Thread) {
+	pinned, rest := []Thread{}, []Thread{}
+	for _, thread := range threads {
+		if pinLeads(thread.Pinned, listing) {
+			pinned = append(pinned, thread)
+		} else {
+			rest = append(rest, thread)
+		}
+	}
+	sort.SliceStable(pinned, func(i, j int) bool {
+		return m.threads[pinned[i].ID].PinnedAt.After(*m.threads[pinned[j].ID].PinnedAt)
+	})
+	return pinned, rest
+}
+
+func sortThreads(threads []Thread, listing ThreadListing, now time.Time) {
+	before := rankedBefore(listing, now)
+	sort.Slice(threads, func(i, j int) bool { return before(threads[i], threads[j]) })
+}
+
+// Approximates to_tsquery matching with case-insensitive word matching
+func matchesTSQuery(text, tsQuery string) bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !('a' <= r && r <= 'z') && !('0' <= r && r <= '9') && r < 128
+	})
+	joined := " " + strings.Join(words, " ") + " "
+
+	for _, term := range strings.Split(tsQuery, " & ") {
+		negate := strings.HasPrefix(term, "!")
+		term = strings.Trim(strings.TrimPrefix(term, "!"), "()")
+		phrase := strings.ReplaceAll(term, " <-> ", " ")
+
+		var found bool
+		if strings.HasSuffix(phrase, ":*") {
+			found = strings.Contains(joined, " "+strings.TrimSuffix(phrase, ":*"))
+		} else {
+			found = strings.Contains(joined, " "+phrase+" ")
+		}
+		if found == negate {
+			return false
+		}
+	}
+	return true
+}
+
+// --- ThreadStore ---
+
+func (m *MemoryStore) FetchThreads(listing ThreadListing, limit, offset int) ([]Thread, error) {
+	listing, err := listing.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	pinned, threads := m.splitPinned(m.filteredThreads(listing, now), listing)
+	sortThreads(threads, listing, now)
+	threads = append(pinned, threads...)
+	if offset >= len(threads) {
+		return nil, nil
+	}
+	return threads[offset:min(offset+limit, len(threads))], nil
+}
+
+func (m *MemoryStore) FetchThreadsByCursor(listing ThreadListing, limit int, cursor *ThreadCursor) ([]Thread, string, string, error) {
+	listing, err := listing.normalize()
+	if err != nil {
+		return nil, "", "", err
+	}
+	if _, ok := cursorSortColumns[listing.SortBy]; !ok {
+		return nil, "", "", ErrCursorUnsupportedSort
+	}
+	if cursor != nil && !cursor.matches(listing) {
+		return nil, "", "", ErrCursorMismatch
+	}
+
+	now := time.Now()
+	m.mu.RLock()
+	_, threads := m.splitPinned(m.filteredThreads(listing, now), listing)
+	m.mu.RUnlock()
+	sortThreads(threads, listing, now)
+	before := rankedBefore(listing, now)
+
+	// Find where the cursor's boundary row sits in the full ordering
+	backward := cursor != nil && cursor.Backward
+	start, end := 0, min(limit, len(threads))
+	if cursor != nil {
+		boundary := Thread{ID: cursor.ID, CreatedAt: cursor.Key}
+		switch listing.SortBy {
+		case "likes":
+			fmt.Sscan(cursor.Key, &boundary.LikesCount)
+		case "dislikes":
+			fmt.Sscan(cursor.Key, &boundary.DislikesCount)
+		case "comments":
+			fmt.Sscan(cursor.Key, &boundary.CommentsCount)
+		case "top":
+			fmt.Sscan(cursor.Key, &boundary.Score)
+		}
+		if backward {
+			end = sort.Search(len(threads), func(i int) bool { return !before(threads[i], boundary) })
+			start = max(end-limit, 0)
+		} else {
+			start = sort.Search(len(threads), func(i int) bool { return before(boundary, threads[i]) })
+			end = min(start+limit, len(threads))
+		}
+	}
+
+	page := append([]Thread{}, threads[start:end]...)
+	hasMore := end < len(threads)
+	if backward {
+		hasMore = start > 0
+	}
+	next, prev := pageCursors(listing, page, cursor, hasMore)
+	return page, next, prev, nil
+}
+
+func (m *MemoryStore) FetchPinnedThreads(listing ThreadListing) ([]Thread, error) {
+	listing, err := listing.normalize()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	pinned, _ := m.splitPinned(m.filteredThreads(listing, time.Now()), listing)
+	return pinned, nil
+}
+
+func (m *MemoryStore) GetThreadCount(listing ThreadListing) int {
+	listing, err := listing.normalize()
+	if err != nil {
+		return 0
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.filteredThreads(listing, time.Now()))
+}
+
+func (m *MemoryStore) FetchThreadByID(threadID int) (*Thread, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, ok := m.threads[threadID]
+	if !ok {
+		return nil, nil
+	}
+	thread := m.toThread(t)
+	thread.ParentAuthor = nil
+	thread.ContentHTML = markdown.Render(thread.Content)
+	return &thread, nil
+}
+
+func (m *MemoryStore) FetchCommentsByThreadID(threadID int, searchQuery, sortBy string) ([]Thread, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	query := strings.ToLower(searchQuery)
+	var comments []Thread
+	for _, t := range m.descendants(threadID) {
+		comment := m.toThread(t)
+		comment.ParentAuthor = nil
+		if !comment.Deleted &&
+			(comment.Hidden || !strings.Contains(strings.ToLower(comment.Content), query)) &&
+			!strings.Contains(strings.ToLower(comment.Author), query) {
+			continue
+		}
+		comment.Redact()
+		comment.ContentHTML = markdown.Render(comment.Content)
+		comments = append(comments, comment)
+	}
+	if sortBy != "likes" && sortBy != "dislikes" {
+		sortBy = "created_at"
+	}
+	sortThreads(comments, ThreadListing{SortBy: sortBy}, time.Now())
+	return pruneTombstones(comments), nil
+}
+
+func (m *MemoryStore) FetchCommentTree(parentID int, query CommentTreeQuery) ([]*CommentNode, int, error) {
+	query, err := query.normalize()
+	if err != nil {
+		return nil, 0, err
+	}
+	before := rankedBefore(ThreadListing{SortBy: query.SortBy, Order: query.Order}, time.Now())
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Replies shown in the tree, grouped by parent and ranked per group.
+	// Tombstones stay while any comment below them is live.
+	children := map[int][]Thread{}
+	for _, t := range m.threads {
+		if t.ParentID == nil {
+			continue
+		}
+		visible := t.DeletedAt == nil || slices.ContainsFunc(m.descendants(t.ID), func(reply *memoryThread) bool {
+			return reply.DeletedAt == nil
+		})
+		if visible {
+			children[*t.ParentID] = append(children[*t.ParentID], m.toThread(t))
+		}
+	}
+	for _, group := range children {
+		sort.Slice(group, func(i, j int) bool { return before(group[i], group[j]) })
+	}
+
+	// Walk level by level so rows arrive in the order buildCommentTree expects
+	var rows []commentTreeRow
+	level := []int{parentID}
+	for depth := 1; depth <= query.Depth && len(level) > 0; depth++ {
+		var next []int
+		for _, id := range level {
+			group := children[id]
+			start := 0
+			if depth == 1 {
+				start = min(query.Offset, len(group))
+			}
+			for _, reply := range group[start:min(start+query.Limit, len(group))] {
+				reply.ParentAuthor = nil
+				rows = append(rows, commentTreeRow{
+					Thread:     reply,
+					level:      depth,
+					siblings:   len(group),
+					hasReplies: len(children[reply.ID]) > 0,
+				})
+				next = append(next, reply.ID)
+			}
+		}
+		level = next
+	}
+
+	top, more := buildCommentTree(parentID, rows, query)
+	return top, more, nil
+}
+
+// Collects every reply below a thread, depth first; callers hold the lock
+func (m *MemoryStore) descendants(threadID int) []*memoryThread {
+	var result []*memoryThread
+	for _, t := range m.threads {
+		if t.ParentID != nil && *t.ParentID == threadID {
+			result = append(result, t)
+			result = append(result, m.descendants(t.ID)...)
+		}
+	}
+	return result
+}
+
+func (m *MemoryStore) FetchTags() ([]Classifier, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Classifier{}, m.tags...), nil
+}
+
+func (m *MemoryStore) CheckThreadExists(threadID int) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if t, ok := m.threads[threadID]; !ok || t.DeletedAt != nil || t.HiddenAt != nil {
+		return ErrThreadNotFound
+	}
+	return nil
+}
+
+func (m *MemoryStore) TitleExists(title string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, t := range m.threads {
+		if t.Title != nil && *t.Title == title {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) GetThreadDepth(threadID int) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.threads[threadID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return t.Depth, nil
+}
+
+func (m *MemoryStore) FetchRootThreadID(threadID int) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.threads[threadID]
+	for ok && t.ParentID != nil {
+		t, ok = m.threads[*t.ParentID]
+	}
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return t.ID, nil
+}
+
+func (m *MemoryStore) CreateThread(title *string, content *string, userID int, categoryID int, tags []string, createTags bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if content == nil {
+		return 0, fmt.Errorf("error inserting thread: content is required")
+	}
+	if title != nil {
+		for _, t := range m.threads {
+			if t.Title != nil && *t.Title == *title {
+				return 0, ErrDuplicateTitle
+			}
+		}
+	}
+	for _, tag := range tags {
+		if !createTags && m.classifierID(m.tags, tag) == 0 {
+			return 0, ErrUnknownTag
+		}
+	}
+	titleCopy := title
+	if title != nil {
+		value := *title
+		titleCopy = &value
+	}
+	var tagIDs []int
+	for _, tag := range tags {
+		tagIDs = append(tagIDs, m.resolveClassifier(&m.tags, tag))
+	}
+
+	id := m.newID()
+	m.threads[id] = &memoryThread{
+		ID:         id,
+		Title:      titleCopy,
+		Content:    *content,
+		CategoryID: categoryID,
+		TagIDs:     tagIDs,
+		UserID:     userID,
+		CreatedAt:  time.Now(),
+	}
+	return id, nil
+}
+
+func (m *MemoryStore) UpdateThread(threadID, editorID int, title, content *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t, ok := m.threads[threadID]; ok {
+		return m.reviseThread(t, editorID, title, content)
+	}
+	return nil
+}
+
+// Mirrors reviseThread in thread.go; callers hold the write lock
+func (m *MemoryStore) reviseThread(t *memoryThread, editorID int, title, content *string) error {
+	titleChanged := title != nil && (t.Title == nil || *t.Title != *title)
+	contentChanged := content != nil && t.Content != *content
+	if !titleChanged && !contentChanged {
+		return nil
+	}
+	if titleChanged {
+		for _, other := range m.threads {
+			if other.ID != t.ID && other.Title != nil && *other.Title == *title {
+				return ErrDuplicateTitle
+			}
+		}
+	}
+
+	now := time.Now()
+	m.revisions = append(m.revisions, &memoryRevision{
+		ID:        m.newID(),
+		ThreadID:  t.ID,
+		EditorID:  editorID,
+		Title:     t.Title,
+		Content:   t.Content,
+		CreatedAt: now,
+	})
+	if title != nil {
+		value := *title
+		t.Title = &value
+	}
+	if content != nil {
+		t.Content = *content
+	}
+	t.UpdatedAt = &now
+	return nil
+}
+
+func (m *MemoryStore) DeleteThread(threadID, deletedBy int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.threads[threadID]
+	if !ok || t.DeletedAt != nil {
+		return ErrThreadNotFound
+	}
+	now := time.Now()
+	t.DeletedAt, t.DeletedBy = &now, &deletedBy
+	m.adjustCommentsCount(t, -1)
+	return nil
+}
+
+func (m *MemoryStore) RestoreThread(threadID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.threads[threadID]
+	if !ok || t.DeletedAt == nil {
+		return false, nil
+	}
+	t.DeletedAt, t.DeletedBy = nil, nil
+	m.adjustCommentsCount(t, 1)
+	return true, nil
+}
+
+// Moves the reply count of a post's parent; callers hold the lock
+func (m *MemoryStore) adjustCommentsCount(t *memoryThread, delta int) {
+	if t.ParentID != nil {
+		if parent, ok := m.threads[*t.ParentID]; ok {
+			parent.CommentsCount += delta
+		}
+	}
+}
+
+func (m *MemoryStore) PurgeThread(threadID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.threads[threadID]
+	if !ok {
+		return false, nil
+	}
+	if t.DeletedAt == nil {
+		m.adjustCommentsCount(t, -1)
+	}
+
+	// Mirror ON DELETE CASCADE for replies and interactions
+	for _, removed := range append(m.descendants(threadID), t) {
+		delete(m.threads, removed.ID)
+		for key := range m.votes {
+			if key.ThreadID == removed.ID {
+				delete(m.votes, key)
+			}
+		}
+		for key := range m.saved {
+			if key.ThreadID == removed.ID {
+				delete(m.saved, key)
+			}
+		}
+		kept := m.notifications[:0]
+		for _, n := range m.notifications {
+			if n.ThreadID == nil || *n.ThreadID != removed.ID {
+				kept = append(kept, n)
+			}
+		}
+		m.notifications = kept
+		delete(m.mentions, removed.ID)
+		for key := range m.moderationFlags {
+			if key.ThreadID == removed.ID {
+				delete(m.moderationFlags, key)
+			}
+		}
+		keptReports := m.reports[:0]
+		for _, r := range m.reports {
+			if r.ThreadID != removed.ID {
+				keptReports = append(keptReports, r)
+			}
+		}
+		m.reports = keptReports
+		keptRevisions := m.revisions[:0]
+		for _, r := range m.revisions {
+			if r.ThreadID != removed.ID {
+				keptRevisions = append(keptRevisions, r)
+			}
+		}
+		m.revisions = keptRevisions
+		for key := range m.threadSubscriptions {
+			if key.ThreadID == removed.ID {
+				delete(m.threadSubscriptions, key)
+			}
+		}
+	}
+	return true, nil
+}
+
+func (m *MemoryStore) CreateComment(content string, userID int, parentID int, depth int) (int, error) {
+	if depth > MaxCommentDepth {
+		return 0, ErrMaxDepthReached
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	parent, ok := m.threads[parentID]
+	if !ok {
+		return 0, ErrThreadNotFound
+	}
+	parent.CommentsCount++
+
+	id := m.newID()
+	m.threads[id] = &memoryThread{
+		ID:        id,
+		Content:   content,
+		UserID:    userID,
+		ParentID:  &parentID,
+		CreatedAt: time.Now(),
+		Depth:     depth,
+	}
+	return id, nil
+}
+
+func (m *MemoryStore) SearchThreads(tsQuery string, comments bool, limit, offset int) ([]SearchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := []SearchResult{}
+	for _, t := range m.threads {
+		if (t.Title == nil) != comments || t.HiddenAt != nil || t.DeletedAt != nil {
+			continue
+		}
+		root := t
+		for root.ParentID != nil {
+			root = m.threads[*root.ParentID]
+		}
+		if root.HiddenAt != nil || root.DeletedAt != nil {
+			continue
+		}
+		text := t.Content
+		if t.Title != nil {
+			text = *t.Title + " " + t.Content
+		}
+		if !matchesTSQuery(text, tsQuery) {
+			continue
+		}
+		thread := m.toThread(t)
+		resultType := "thread"
+		if comments {
+			resultType = "comment"
+		}
+		results = append(results, SearchResult{
+			Type:      resultType,
+			ID:        thread.ID,
+			Title:     thread.Title,
+			Author:    thread.Author,
+			ParentID:  thread.ParentID,
+			Snippet:   markHeadline(t.Content),
+			Rank:      1,
+			CreatedAt: thread.CreatedAt,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].CreatedAt > results[j].CreatedAt })
+	if offset >= len(results) {
+		return []SearchResult{}, nil
+	}
+	return results[offset:min(offset+limit, len(results))], nil
+}
+
+// --- UserStore ---
+
+func (m *MemoryStore) FetchPrincipal(username string) (*Principal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user := m.userByName(username)
+	if user == nil {
+		return nil, sql.ErrNoRows
+	}
+	return &Principal{UserID: user.ID, Username: user.Username, IsAdmin: user.IsAdmin, CreatedAt: user.CreatedAt}, nil
+}
+
+func (m *MemoryStore) IsAdmin(username string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user := m.userByName(username)
+	return user != nil && user.IsAdmin, nil
+}
+
+func (m *MemoryStore) CheckThreadOwnershipOrAdmin(username string, userID int, threadID int) bool {
+	m.mu.RLock()
+	t, ok := m.threads[threadID]
+	m.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	if t.UserID == userID {
+		return true
+	}
+	isAdmin, _ := m.IsAdmin(username)
+	return isAdmin
+}
+
+func (m *MemoryStore) GetUserIDFromUsername(username string) (int, error) {
+	if username == "" {
+		return 0, fmt.Errorf("username is required")
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user := m.userByName(username)
+	if user == nil {
+		return 0, fmt.Errorf("username does not exist")
+	}
+	return user.ID, nil
+}
+
+func (m *MemoryStore) CheckUsernameExists(username string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.userByName(username) != nil, nil
+}
+
+func (m *MemoryStore) CreateUser(username string, password string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.userByName(username) != nil {
+		return fmt.Errorf("username already exists")
+	}
+	id := m.newID()
+	m.users[id] = &memoryUser{
+		ID:        id,
+		Username:  username,
+		Password:  password,
+		Bio:       "This user has not added a bio yet.",
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetPassword(username string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user := m.userByName(username)
+	if user == nil {
+		return "", sql.ErrNoRows
+	}
+	return user.Password, nil
+}
+
+func (m *MemoryStore) UpdatePassword(username string, newPassword string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user := m.userByName(username)
+	if user == nil {
+		return sql.ErrNoRows
+	}
+	user.Password = newPassword
+	m.revokeUserSessions(user)
+	return nil
+}
+
+// Mirrors revokeUserSessions; callers hold the write lock
+func (m *MemoryStore) revokeUserSessions(user *memoryUser) {
+	for _, token := range m.refreshTokens {
+		if token.UserID == user.ID {
+			token.Revoked = true
+		}
+	}
+	user.SessionsValidAfter = time.Now().Truncate(time.Microsecond)
+}
+
+func (m *MemoryStore) UpdateBio(bio string, username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.userByName(username); user != nil {
+		user.Bio = bio
+	}
+	return nil
+}
+
+func (m *MemoryStore) PromoteUser(username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.userByName(username); user != nil {
+		user.IsAdmin = true
+	}
+	return nil
+}
+
+func (m *MemoryStore) DemoteUser(username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.userByName(username); user != nil {
+		user.IsAdmin = false
+	}
+	return nil
+}
+
+// Computes the contribution score described in user.go; callers hold the lock
+func (m *MemoryStore) scoresFor(user *memoryUser) UserScores {
+	var threads, comments, threadLikes, commentLikes, dislikes int
+	for _, t := range m.threads {
+		if t.UserID != user.ID || t.DeletedAt != nil {
+			continue
+		}
+		if t.ParentID == nil {
+			threads++
+			threadLikes += t.LikesCount
+		} else {
+			comments++
+			commentLikes += t.LikesCount
+		}
+		dislikes += t.DislikesCount
+	}
+
+	average := func(total, count int) float64 {
+		if count == 0 {
+			return 0
+		}
+		return float64(total) / float64(count)
+	}
+	threadsScore := float64(threads*5) + average(threadLikes, threads)*10
+	commentsScore := float64(comments*2) + average(commentLikes, comments)*5
+	return UserScores{
+		UserID:            user.ID,
+		Username:          user.Username,
+		ThreadsScore:      threadsScore,
+		CommentsScore:     commentsScore,
+		ContributionScore: threadsScore + commentsScore - float64(dislikes*2),
+	}
+}
+
+func (m *MemoryStore) FetchUserScores(username string) (*UserScores, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user := m.userByName(username)
+	if user == nil {
+		return nil, sql.ErrNoRows
+	}
+	scores := m.scoresFor(user)
+	return &scores, nil
+}
+
+func (m *MemoryStore) FetchLeaderboard() ([]UserScores, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var leaderboard []UserScores
+	for _, user := range m.users {
+		leaderboard = append(leaderboard, m.scoresFor(user))
+	}
+	sort.Slice(leaderboard, func(i, j int) bool {
+		return leaderboard[i].ContributionScore > leaderboard[j].ContributionScore
+	})
+	return leaderboard, nil
+}
+
+func (m *MemoryStore) FetchUserInfo(username string) (*UserInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user := m.userByName(username)
+	if user == nil {
+		return nil, sql.ErrNoRows
+	}
+	role := "Regular User"
+	if user.IsAdmin {
+		role = "Admin"
+	}
+	return &UserInfo{
+		UserID:   user.ID,
+		Username: user.Username,
+		JoinDate: memoryTimestamp(user.CreatedAt),
+		Role:     role,
+		Bio:      user.Bio,
+	}, nil
+}
+
+func (m *MemoryStore) FetchUserMetrics(username string) (*UserMetrics, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user := m.userByName(username)
+	if user == nil {
+		return nil, sql.ErrNoRows
+	}
+	var metrics UserMetrics
+	for _, t := range m.threads {
+		if t.UserID != user.ID || t.DeletedAt != nil {
+			continue
+		}
+		if t.ParentID == nil {
+			metrics.ThreadsCreated++
+		} else {
+			metrics.CommentsMade++
+		}
+		metrics.LikesReceived += t.LikesCount
+		metrics.DislikesReceived += t.DislikesCount
+	}
+	return &metrics, nil
+}
+
+func (m *MemoryStore) FetchUserActivity(username string) (*UserActivity, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	activity := &UserActivity{}
+	user := m.userByName(username)
+	if user == nil {
+		return activity, nil
+	}
+	for _, t := range m.threads {
+		if t.UserID != user.ID || t.DeletedAt != nil {
+			continue
+		}
+		thread := m.toThread(t)
+		thread.Category, thread.Tag, thread.Tags = nil, nil, nil
+		if thread.Title == nil {
+			activity.Comments = append(activity.Comments, thread)
+		} else {
+			activity.Threads = append(activity.Threads, thread)
+		}
+	}
+	return activity, nil
+}
+
+func (m *MemoryStore) FetchUserSavedThreads(userID int) ([]SavedThread, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	savedThreads := []SavedThread{}
+	for key := range m.saved {
+		if key.UserID != userID {
+			continue
+		}
+		t := m.threads[key.ThreadID]
+		title := ""
+		if t.Title != nil {
+			title = *t.Title
+		}
+		savedThreads = append(savedThreads, SavedThread{ID: t.ID, Title: title, CreatedAt: memoryTimestamp(t.CreatedAt)})
+	}
+	return savedThreads, nil
+}
+
+func (m *MemoryStore) SearchUsers(tsQuery string, limit, offset int) ([]SearchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	results := []SearchResult{}
+	for _, user := range m.users {
+		if !matchesTSQuery(user.Username+" "+user.Bio, tsQuery) {
+			continue
+		}
+		results = append(results, SearchResult{
+			Type:      "user",
+			ID:        user.ID,
+			Author:    user.Username,
+			Snippet:   markHeadline(user.Bio),
+			Rank:      1,
+			CreatedAt: memoryTimestamp(user.CreatedAt),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Author < results[j].Author })
+	if offset >= len(results) {
+		return []SearchResult{}, nil
+	}
+	return results[offset:min(offset+limit, len(results))], nil
+}
+
+func (m *MemoryStore) CreateRefreshToken(userID int, tokenHash string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshTokens[tokenHash] = &memoryRefreshToken{ID: m.newID(), UserID: userID, ExpiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *MemoryStore) RotateRefreshToken(oldHash, newHash string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.refreshTokens[oldHash]
+	if !ok {
+		return "", ErrRefreshTokenInvalid
+	}
+	user := m.users[token.UserID]
+	if token.Revoked {
+		m.revokeUserSessions(user)
+		return "", ErrRefreshTokenReused
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return "", ErrRefreshTokenInvalid
+	}
+
+	token.Revoked = true
+	m.refreshTokens[newHash] = &memoryRefreshToken{ID: m.newID(), UserID: token.UserID, ExpiresAt: time.Now().Add(ttl)}
+	return user.Username, nil
+}
+
+func (m *MemoryStore) RevokeRefreshToken(userID int, tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if token, ok := m.refreshTokens[tokenHash]; ok && token.UserID == userID {
+		token.Revoked = true
+	}
+	return nil
+}
+
+func (m *MemoryStore) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revokedTokens[jti] = expiresAt
+	return nil
+}
+
+func (m *MemoryStore) IsAccessTokenRevoked(jti string, username string, issuedAt time.Time) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, revoked := m.revokedTokens[jti]; revoked {
+		return true, nil
+	}
+	user := m.userByName(username)
+	return user != nil && user.SessionsValidAfter.After(issuedAt), nil
+}
+
+func (m *MemoryStore) FetchUsernamesByPrefix(prefix string, limit int) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	usernames := []string{}
+	for _, user := range m.users {
+		if strings.HasPrefix(strings.ToLower(user.Username), strings.ToLower(prefix)) {
+			usernames = append(usernames, user.Username)
+		}
+	}
+	sort.Slice(usernames, func(i, j int) bool {
+		return strings.ToLower(usernames[i]) < strings.ToLower(usernames[j])
+	})
+	if len(usernames) > limit {
+		usernames = usernames[:limit]
+	}
+	return usernames, nil
+}
+
+// --- InteractionStore ---
+
+func (m *MemoryStore) GetInteractionState(threadID, userID int) (bool, bool, error) {
+	value, _ := m.GetVote(threadID, userID)
+	return value == 1, value == -1, nil
+}
+
+func (m *MemoryStore) GetVote(threadID, userID int) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if vote, ok := m.votes[memoryKey{threadID, userID}]; ok {
+		return vote.Value, nil
+	}
+	return 0, nil
+}
+
+func (m *MemoryStore) GetLikesCount(threadID int) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.threads[threadID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return t.LikesCount, nil
+}
+
+func (m *MemoryStore) GetDislikesCount(threadID int) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.threads[threadID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return t.DislikesCount, nil
+}
+
+func (m *MemoryStore) AddLike(threadID, userID int) error {
+	return m.Vote(threadID, userID, 1)
+}
+
+func (m *MemoryStore) AddDislike(threadID, userID int) error {
+	return m.Vote(threadID, userID, -1)
+}
+
+func (m *MemoryStore) RemoveLike(threadID, userID int) error {
+	return m.changeVote(threadID, userID, 0, 1)
+}
+
+func (m *MemoryStore) RemoveDislike(threadID, userID int) error {
+	return m.changeVote(threadID, userID, 0, -1)
+}
+
+func (m *MemoryStore) Vote(threadID, userID, value int) error {
+	return m.changeVote(threadID, userID, value, 0)
+}
+
+// Mirrors the Postgres changeVote: one vote per user, counters kept in step
+func (m *MemoryStore) changeVote(threadID, userID, value, only int) error {
+	if value < -1 || value > 1 {
+		return ErrInvalidVote
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.threads[threadID]
+	if !ok || t.DeletedAt != nil || t.HiddenAt != nil {
+		return ErrThreadNotFound
+	}
+	key := memoryKey{threadID, userID}
+	var previous int
+	if vote, ok := m.votes[key]; ok {
+		previous = vote.Value
+	}
+	if previous == value || (only != 0 && previous != only) {
+		return nil
+	}
+
+	counter := func(value int) *int {
+		if value > 0 {
+			return &t.LikesCount
+		}
+		return &t.DislikesCount
+	}
+	if previous != 0 {
+		*counter(previous)--
+	}
+
+	now := time.Now()
+	switch {
+	case value == 0:
+		delete(m.votes, key)
+	case previous == 0:
+		m.votes[key] = &memoryVote{Value: value, CreatedAt: now, UpdatedAt: now}
+	default:
+		m.votes[key].Value = value
+		m.votes[key].UpdatedAt = now
+	}
+	if value != 0 {
+		*counter(value)++
+	}
+	return nil
+}
+
+func (m *MemoryStore) FetchUserVotes(userID int) ([]Vote, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	votes := []Vote{}
+	for key, vote := range m.votes {
+		if key.UserID != userID {
+			continue
+		}
+		t := m.threads[key.ThreadID]
+		votes = append(votes, Vote{
+			ThreadID:  t.ID,
+			Title:     t.Title,
+			ParentID:  t.ParentID,
+			Value:     vote.Value,
+			CreatedAt: memoryTimestamp(vote.CreatedAt),
+			UpdatedAt: memoryTimestamp(vote.UpdatedAt),
+		})
+	}
+	sort.Slice(votes, func(i, j int) bool {
+		if votes[i].UpdatedAt != votes[j].UpdatedAt {
+			return votes[i].UpdatedAt > votes[j].UpdatedAt
+		}
+		return votes[i].ThreadID > votes[j].ThreadID
+	})
+	return votes, nil
+}
+
+func (m *MemoryStore) FetchSaveState(threadID, userID int) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, saved := m.saved[memoryKey{threadID, userID}]
+	return saved, nil
+}
+
+func (m *MemoryStore) SaveThread(threadID, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.threads[threadID]; !ok {
+		return ErrThreadNotFound
+	}
+	key := memoryKey{threadID, userID}
+	if _, exists := m.saved[key]; exists {
+		return fmt.Errorf("thread already saved")
+	}
+	m.saved[key] = time.Now()
+	return nil
+}
+
+func (m *MemoryStore) UnsaveThread(threadID, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.saved, memoryKey{threadID, userID})
+	return nil
+}
+
+// --- NotificationStore ---
+
+func (m *MemoryStore) CreateNotification(n NewNotification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if enabled, ok := m.preferences[n.UserID][n.Type]; ok && !enabled {
+		return nil
+	}
+	if n.Type == NotificationLikeMilestone {
+		for _, existing := range m.notifications {
+			if existing.UserID == n.UserID && existing.Type == n.Type &&
+				*existing.ThreadID == *n.ThreadID && *existing.Milestone == *n.Milestone {
+				return nil
+			}
+		}
+	}
+	m.notifications = append(m.notifications, &memoryNotification{NewNotification: n, ID: m.newID(), CreatedAt: time.Now()})
+	return nil
+}
+
+func (m *MemoryStore) FetchNotifications(userID int, unreadOnly bool, limit, offset int) ([]Notification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Stored oldest first, listed newest first
+	notifications := []Notification{}
+	for i := len(m.notifications) - 1; i >= 0; i-- {
+		n := m.notifications[i]
+		if n.UserID != userID || (unreadOnly && n.Read) {
+			continue
+		}
+		var actor *string
+		if n.ActorID != nil {
+			if user, ok := m.users[*n.ActorID]; ok {
+				name := user.Username
+				actor = &name
+			}
+		}
+		notifications = append(notifications, Notification{
+			ID:        n.ID,
+			Type:      n.Type,
+			Actor:     actor,
+			ThreadID:  n.ThreadID,
+			Milestone: n.Milestone,
+			CreatedAt: memoryTimestamp(n.CreatedAt),
+			Read:      n.Read,
+		})
+	}
+	if offset >= len(notifications) {
+		return []Notification{}, nil
+	}
+	return notifications[offset:min(offset+limit, len(notifications))], nil
+}
+
+func (m *MemoryStore) CountUnreadNotifications(userID int) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	count := 0
+	for _, n := range m.notifications {
+		if n.UserID == userID && !n.Read {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStore) MarkNotificationRead(userID, notificationID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, n := range m.notifications {
+		if n.ID == notificationID && n.UserID == userID {
+			n.Read = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) MarkAllNotificationsRead(userID int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var changed int64
+	for _, n := range m.notifications {
+		if n.UserID == userID && !n.Read {
+			n.Read = true
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+func (m *MemoryStore) FetchNotificationPreferences(userID int) (map[string]bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	preferences := map[string]bool{}
+	for _, kind := range NotificationTypes {
+		preferences[kind] = true
+	}
+	for kind, enabled := range m.preferences[userID] {
+		preferences[kind] = enabled
+	}
+	return preferences, nil
+}
+
+func (m *MemoryStore) UpdateNotificationPreferences(userID int, preferences map[string]bool) error {
+	if err := validateNotificationTypes(preferences); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.preferences[userID] == nil {
+		m.preferences[userID] = map[string]bool{}
+	}
+	for kind, enabled := range preferences {
+		m.preferences[userID][kind] = enabled
+	}
+	return nil
+}
+
+// --- MentionStore ---
+
+func (m *MemoryStore) SaveMentions(threadID int, usernames []string) ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := m.mentions[threadID]
+	current := map[int]bool{}
+	added := []int{}
+	for _, username := range usernames {
+		user := m.userByName(username)
+		if user == nil || current[user.ID] {
+			continue
+		}
+		current[user.ID] = true
+		if !previous[user.ID] {
+			added = append(added, user.ID)
+		}
+	}
+	m.mentions[threadID] = current
+	return added, nil
+}
+
+func (m *MemoryStore) FetchMentions(threadIDs []int) (map[int][]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mentions := map[int][]string{}
+	for _, threadID := range threadIDs {
+		for userID := range m.mentions[threadID] {
+			if user, ok := m.users[userID]; ok {
+				mentions[threadID] = append(mentions[threadID], user.Username)
+			}
+		}
+	}
+	return mentions, nil
+}
+
+// --- ModerationStore ---
+
+func (m *MemoryStore) FetchModerationRules() ([]ModerationRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rules := []ModerationRule{}
+	for _, r := range m.moderationRules {
+		rule := r.ModerationRule
+		if user, ok := m.users[r.CreatedByID]; ok {
+			rule.CreatedBy = &user.Username
+		}
+		rule.CreatedAt = memoryTimestamp(r.Created)
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (m *MemoryStore) CreateModerationRule(rule ModerationRule, createdBy int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule.ID = m.newID()
+	m.moderationRules = append(m.moderationRules, &memoryModerationRule{
+		ModerationRule: rule,
+		CreatedByID:    createdBy,
+		Created:        time.Now(),
+	})
+	return rule.ID, nil
+}
+
+func (m *MemoryStore) UpdateModerationRule(rule ModerationRule) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.moderationRules {
+		if r.ID == rule.ID {
+			r.Pattern, r.Kind, r.Action = rule.Pattern, rule.Kind, rule.Action
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) DeleteModerationRule(ruleID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, r := range m.moderationRules {
+		if r.ID == ruleID {
+			m.moderationRules = append(m.moderationRules[:i], m.moderationRules[i+1:]...)
+			for key := range m.moderationFlags {
+				if key.RuleID == ruleID {
+					delete(m.moderationFlags, key)
+				}
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) FlagThread(threadID int, ruleIDs []int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.threads[threadID]; !ok {
+		return fmt.Errorf("error flagging thread: thread %d does not exist", threadID)
+	}
+	for _, ruleID := range ruleIDs {
+		key := memoryFlagKey{ThreadID: threadID, RuleID: ruleID}
+		if _, ok := m.moderationFlags[key]; !ok {
+			m.moderationFlags[key] = time.Now()
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) FetchModerationFlags(limit, offset int) ([]ModerationFlag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type entry struct {
+		flag ModerationFlag
+		at   time.Time
+	}
+	var entries []entry
+	for key, at := range m.moderationFlags {
+		t, ok := m.threads[key.ThreadID]
+		if !ok {
+			continue
+		}
+		flag := ModerationFlag{
+			ThreadID:  t.ID,
+			Title:     t.Title,
+			Content:   t.Content,
+			RuleID:    key.RuleID,
+			CreatedAt: memoryTimestamp(at),
+		}
+		if user, ok := m.users[t.UserID]; ok {
+			flag.Author = user.Username
+		}
+		for _, r := range m.moderationRules {
+			if r.ID == key.RuleID {
+				flag.Pattern = r.Pattern
+			}
+		}
+		entries = append(entries, entry{flag, at})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].at.Equal(entries[j].at) {
+			return entries[i].at.After(entries[j].at)
+		}
+		return entries[i].flag.ThreadID > entries[j].flag.ThreadID
+	})
+
+	flags := []ModerationFlag{}
+	for i := offset; i < len(entries) && len(flags) < limit; i++ {
+		flags = append(flags, entries[i].flag)
+	}
+	return flags, nil
+}
+
+func (m *MemoryStore) ClearModerationFlags(threadID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cleared := false
+	for key := range m.moderationFlags {
+		if key.ThreadID == threadID {
+			delete(m.moderationFlags, key)
+			cleared = true
+		}
+	}
+	return cleared, nil
+}
+
+// --- ReportStore ---
+
+func (m *MemoryStore) CreateReport(threadID, reporterID int, reason string, details *string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.threads[threadID]; !ok {
+		return 0, fmt.Errorf("error inserting report: thread %d does not exist", threadID)
+	}
+	for _, r := range m.reports {
+		if r.ThreadID == threadID && r.ReporterID == reporterID && r.ResolvedAt == nil {
+			return 0, ErrDuplicateReport
+		}
+	}
+	report := &memoryReport{
+		ID:         m.newID(),
+		ThreadID:   threadID,
+		ReporterID: reporterID,
+		Reason:     reason,
+		Details:    details,
+		CreatedAt:  time.Now(),
+	}
+	m.reports = append(m.reports, report)
+	return report.ID, nil
+}
+
+func (m *MemoryStore) FetchReportQueue(limit, offset int) ([]ReportedItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type entry struct {
+		item        *ReportedItem
+		first, last time.Time
+	}
+	entries := map[int]*entry{}
+	for _, r := range m.reports {
+		if r.ResolvedAt != nil {
+			continue
+		}
+		e, ok := entries[r.ThreadID]
+		if !ok {
+			t, ok := m.threads[r.ThreadID]
+			if !ok {
+				continue
+			}
+			thread := m.toThread(t)
+			e = &entry{
+				item: &ReportedItem{
+					ThreadID: t.ID,
+					Title:    t.Title,
+					ParentID: t.ParentID,
+					Content:  t.Content,
+					Author:   thread.Author,
+					Hidden:   thread.Hidden,
+					Reasons:  map[string]int{},
+				},
+				first: r.CreatedAt,
+				last:  r.CreatedAt,
+			}
+			entries[r.ThreadID] = e
+		}
+		e.item.ReportCount++
+		e.item.Reasons[r.Reason]++
+		if r.CreatedAt.Before(e.first) {
+			e.first = r.CreatedAt
+		}
+		if r.CreatedAt.After(e.last) {
+			e.last = r.CreatedAt
+		}
+	}
+
+	queue := make([]*entry, 0, len(entries))
+	for _, e := range entries {
+		e.item.FirstReportedAt = memoryTimestamp(e.first)
+		e.item.LastReportedAt = memoryTimestamp(e.last)
+		queue = append(queue, e)
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		if queue[i].item.ReportCount != queue[j].item.ReportCount {
+			return queue[i].item.ReportCount > queue[j].item.ReportCount
+		}
+		return queue[i].last.After(queue[j].last)
+	})
+
+	items := []ReportedItem{}
+	for i := offset; i < len(queue) && len(items) < limit; i++ {
+		items = append(items, *queue[i].item)
+	}
+	return items, nil
+}
+
+func (m *MemoryStore) ResolveReports(threadID, moderatorID int, action string, note *string) (*ModerationAction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.threads[threadID]
+	if !ok {
+		return nil, ErrThreadNotFound
+	}
+
+	now := time.Now()
+	resolved := 0
+	for _, r := range m.reports {
+		if r.ThreadID == threadID && r.ResolvedAt == nil {
+			r.ResolvedAt = &now
+			resolved++
+		}
+	}
+	if resolved == 0 {
+		return nil, ErrNoOpenReports
+	}
+	if action == ReportHide && t.HiddenAt == nil {
+		t.HiddenAt = &now
+	}
+
+	record := &memoryModerationAction{
+		ModerationAction: ModerationAction{
+			ID:              m.newID(),
+			ThreadID:        threadID,
+			Action:          action,
+			Note:            note,
+			ReportsResolved: resolved,
+		},
+		AuthorID:    t.UserID,
+		ModeratorID: moderatorID,
+		Created:     now,
+	}
+	m.moderationActions = append(m.moderationActions, record)
+	result := m.toModerationAction(record)
+	return &result, nil
+}
+
+func (m *MemoryStore) FetchModerationActions(limit, offset int) ([]ModerationAction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	actions := []ModerationAction{}
+	for i := len(m.moderationActions) - 1 - offset; i >= 0 && len(actions) < limit; i-- {
+		actions = append(actions, m.toModerationAction(m.moderationActions[i]))
+	}
+	return actions, nil
+}
+
+func (m *MemoryStore) toModerationAction(a *memoryModerationAction) ModerationAction {
+	action := a.ModerationAction
+	if moderator, ok := m.users[a.ModeratorID]; ok {
+		action.Moderator = &moderator.Username
+	}
+	if author, ok := m.users[a.AuthorID]; ok {
+		action.Author = &author.Username
+	}
+	action.CreatedAt = memoryTimestamp(a.Created)
+	return action
+}
+
+// --- ThreadStatusStore ---
+
+// Finds a visible top-level thread for an admin control; callers hold the lock
+func (m *MemoryStore) statusTarget(threadID int) *memoryThread {
+	t, ok := m.threads[threadID]
+	if !ok || t.ParentID != nil || t.DeletedAt != nil {
+		return nil
+	}
+	return t
+}
+
+func (m *MemoryStore) PinThread(threadID int, scope string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.statusTarget(threadID)
+	if t == nil {
+		return false, nil
+	}
+	t.PinnedAt, t.PinScope = nil, scope
+	if scope != "" {
+		now := time.Now()
+		t.PinnedAt = &now
+	}
+	return true, nil
+}
+
+func (m *MemoryStore) LockThread(threadID int, locked bool) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.statusTarget(threadID)
+	if t == nil {
+		return false, nil
+	}
+	if !locked {
+		t.LockedAt = nil
+	} else if t.LockedAt == nil {
+		now := time.Now()
+		t.LockedAt = &now
+	}
+	return true, nil
+}
+
+func (m *MemoryStore) SetAnnouncement(threadID int, announcement bool) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.statusTarget(threadID)
+	if t == nil {
+		return false, nil
+	}
+	t.Announcement = announcement
+	return true, nil
+}
+
+func (m *MemoryStore) IsThreadLocked(threadID int) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, ok := m.threads[threadID]
+	for ok && t.ParentID != nil {
+		t, ok = m.threads[*t.ParentID]
+	}
+	return ok && t.LockedAt != nil, nil
+}
+
+// --- CategoryStore ---
+
+func (m *MemoryStore) FetchCategoryTree() ([]*CategoryNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	nodes := map[int]*CategoryNode{}
+	var list []*CategoryNode
+	for _, category := range m.categories {
+		node := &CategoryNode{Category: category}
+		nodes[category.ID] = node
+		list = append(list, node)
+	}
+	for _, t := range m.threads {
+		if t.HiddenAt != nil || t.DeletedAt != nil {
+			continue
+		}
+		// Posts count toward their thread's category, if the thread is visible
+		root, visible := t, true
+		for root.ParentID != nil && visible {
+			root = m.threads[*root.ParentID]
+			visible = root != nil && root.HiddenAt == nil && root.DeletedAt == nil
+		}
+		if !visible {
+			continue
+		}
+		node, ok := nodes[root.CategoryID]
+		if !ok {
+			continue
+		}
+		if t == root {
+			node.ThreadCount++
+		}
+		if node.LatestActivity == nil || t.CreatedAt.After(*node.LatestActivity) {
+			created := t.CreatedAt
+			node.LatestActivity = &created
+		}
+	}
+	return buildCategoryTree(list), nil
+}
+
+func (m *MemoryStore) FetchCategory(ref string) (*Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if category := m.categoryByRef(ref); category != nil {
+		found := *category
+		return &found, nil
+	}
+	return nil, nil
+}
+
+// Mirrors the unique names and slugs and the parent checks of the
+// categories table; callers hold the lock
+func (m *MemoryStore) checkCategory(category Category) error {
+	for _, existing := range m.categories {
+		if existing.ID != category.ID && (existing.Name == category.Name || existing.Slug == category.Slug) {
+			return ErrDuplicateCategory
+		}
+	}
+	if category.ParentID == nil {
+		return nil
+	}
+	parent := m.categoryByID(*category.ParentID)
+	if parent == nil {
+		return ErrUnknownParent
+	}
+	for parent != nil {
+		if parent.ID == category.ID {
+			return ErrCategoryCycle
+		}
+		if parent.ParentID == nil {
+			break
+		}
+		parent = m.categoryByID(*parent.ParentID)
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateCategory(category Category) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	category.ID = 0
+	if err := m.checkCategory(category); err != nil {
+		return 0, err
+	}
+	for _, existing := range m.categories {
+		category.ID = max(category.ID, existing.ID)
+	}
+	category.ID++
+	m.categories = append(m.categories, category)
+	return category.ID, nil
+}
+
+func (m *MemoryStore) UpdateCategory(category Category) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing := m.categoryByID(category.ID)
+	if existing == nil {
+		return false, nil
+	}
+	if err := m.checkCategory(category); err != nil {
+		return false, err
+	}
+	*existing = category
+	return true, nil
+}
+
+func (m *MemoryStore) DeleteCategory(categoryID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.threads {
+		if t.CategoryID == categoryID {
+			return false, ErrCategoryInUse
+		}
+	}
+	for _, category := range m.categories {
+		if category.ParentID != nil && *category.ParentID == categoryID {
+			return false, ErrCategoryInUse
+		}
+	}
+	if m.categoryByID(categoryID) == nil {
+		return false, nil
+	}
+	m.categories = slices.DeleteFunc(m.categories, func(category Category) bool { return category.ID == categoryID })
+	for key := range m.categorySubscriptions {
+		if key.CategoryID == categoryID {
+			delete(m.categorySubscriptions, key)
+		}
+	}
+	return true, nil
+}
+
+// --- TagStore ---
+
+func (m *MemoryStore) CreateTag(name string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.classifierID(m.tags, name) != 0 {
+		return 0, ErrDuplicateTag
+	}
+	return m.resolveClassifier(&m.tags, name), nil
+}
+
+func (m *MemoryStore) RenameTag(tagID int, name string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing := m.classifierID(m.tags, name); existing != 0 && existing != tagID {
+		return false, ErrDuplicateTag
+	}
+	for i := range m.tags {
+		if m.tags[i].ID == tagID {
+			m.tags[i].Name = name
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) MergeTags(fromID, intoID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.classifierName(m.tags, fromID) == nil || m.classifierName(m.tags, intoID) == nil {
+		return false, nil
+	}
+	for _, t := range m.threads {
+		for i, tagID := range t.TagIDs {
+			if tagID != fromID {
+				continue
+			}
+			if slices.Contains(t.TagIDs, intoID) {
+				t.TagIDs = slices.Delete(t.TagIDs, i, i+1)
+			} else {
+				t.TagIDs[i] = intoID
+			}
+			break
+		}
+	}
+	for key, created := range m.tagSubscriptions {
+		if key.TagID == fromID {
+			moved := memoryTagKey{TagID: intoID, UserID: key.UserID}
+			if _, ok := m.tagSubscriptions[moved]; !ok {
+				m.tagSubscriptions[moved] = created
+			}
+		}
+	}
+	m.removeTag(fromID)
+	return true, nil
+}
+
+func (m *MemoryStore) DeleteTag(tagID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.classifierName(m.tags, tagID) == nil {
+		return false, nil
+	}
+	for _, t := range m.threads {
+		t.TagIDs = slices.DeleteFunc(t.TagIDs, func(id int) bool { return id == tagID })
+	}
+	m.removeTag(tagID)
+	return true, nil
+}
+
+// Drops a tag from the tag list with its subscriptions; callers hold the lock
+func (m *MemoryStore) removeTag(tagID int) {
+	m.tags = slices.DeleteFunc(m.tags, func(tag Classifier) bool { return tag.ID == tagID })
+	for key := range m.tagSubscriptions {
+		if key.TagID == tagID {
+			delete(m.tagSubscriptions, key)
+		}
+	}
+}
+
+// --- SubscriptionStore ---
+
+func (m *MemoryStore) SubscribeThread(userID, threadID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memoryKey{ThreadID: threadID, UserID: userID}
+	now := time.Now()
+	if subscription, ok := m.threadSubscriptions[key]; ok {
+		subscription.LastReadAt = now
+		return nil
+	}
+	m.threadSubscriptions[key] = &memorySubscription{LastReadAt: now, CreatedAt: now}
+	return nil
+}
+
+func (m *MemoryStore) UnsubscribeThread(userID, threadID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memoryKey{ThreadID: threadID, UserID: userID}
+	if _, ok := m.threadSubscriptions[key]; !ok {
+		return false, nil
+	}
+	delete(m.threadSubscriptions, key)
+	return true, nil
+}
+
+func (m *MemoryStore) MarkThreadRead(userID, threadID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subscription, ok := m.threadSubscriptions[memoryKey{ThreadID: threadID, UserID: userID}]
+	if !ok {
+		return false, nil
+	}
+	subscription.LastReadAt = time.Now()
+	return true, nil
+}
+
+func (m *MemoryStore) FetchThreadSubscriptions(userID, limit, offset int) ([]SubscribedThread, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	threads := []SubscribedThread{}
+	latest := map[int]time.Time{}
+	for key, subscription := range m.threadSubscriptions {
+		t, ok := m.threads[key.ThreadID]
+		if key.UserID != userID || !ok || t.HiddenAt != nil || t.DeletedAt != nil {
+			continue
+		}
+		thread := SubscribedThread{Thread: m.toThread(t), LastReadAt: memoryTimestamp(subscription.LastReadAt)}
+		latest[t.ID] = t.CreatedAt
+		for _, reply := range m.descendants(t.ID) {
+			if reply.HiddenAt != nil || reply.DeletedAt != nil {
+				continue
+			}
+			if reply.CreatedAt.After(subscription.LastReadAt) && reply.UserID != userID {
+				thread.UnreadCount++
+			}
+			if reply.CreatedAt.After(latest[t.ID]) {
+				latest[t.ID] = reply.CreatedAt
+			}
+		}
+		thread.LatestActivity = memoryTimestamp(latest[t.ID])
+		threads = append(threads, thread)
+	}
+	sort.Slice(threads, func(i, j int) bool {
+		a, b := latest[threads[i].ID], latest[threads[j].ID]
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return threads[i].ID > threads[j].ID
+	})
+	if offset >= len(threads) {
+		return []SubscribedThread{}, nil
+	}
+	return threads[offset:min(offset+limit, len(threads))], nil
+}
+
+func (m *MemoryStore) FetchThreadSubscribers(threadID int) ([]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var userIDs []int
+	for key := range m.threadSubscriptions {
+		if key.ThreadID == threadID {
+			userIDs = append(userIDs, key.UserID)
+		}
+	}
+	return userIDs, nil
+}
+
+func (m *MemoryStore) SubscribeCategory(userID, categoryID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.categoryByID(categoryID) == nil {
+		return false, nil
+	}
+	key := memoryCategoryKey{CategoryID: categoryID, UserID: userID}
+	if _, ok := m.categorySubscriptions[key]; !ok {
+		m.categorySubscriptions[key] = time.Now()
+	}
+	return true, nil
+}
+
+func (m *MemoryStore) UnsubscribeCategory(userID, categoryID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memoryCategoryKey{CategoryID: categoryID, UserID: userID}
+	if _, ok := m.categorySubscriptions[key]; !ok {
+		return false, nil
+	}
+	delete(m.categorySubscriptions, key)
+	return true, nil
+}
+
+func (m *MemoryStore) FetchCategorySubscriptions(userID int) ([]Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	categories := []Category{}
+	for key := range m.categorySubscriptions {
+		if category := m.categoryByID(key.CategoryID); key.UserID == userID && category != nil {
+			categories = append(categories, *category)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Position != categories[j].Position {
+			return categories[i].Position < categories[j].Position
+		}
+		return categories[i].Name < categories[j].Name
+	})
+	return categories, nil
+}
+
+func (m *MemoryStore) FetchCategorySubscribers(categoryID int) ([]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	followed := map[int]bool{}
+	for category := m.categoryByID(categoryID); category != nil; {
+		followed[category.ID] = true
+		if category.ParentID == nil {
+			break
+		}
+		category = m.categoryByID(*category.ParentID)
+	}
+	seen := map[int]bool{}
+	var userIDs []int
+	for key := range m.categorySubscriptions {
+		if followed[key.CategoryID] && !seen[key.UserID] {
+			seen[key.UserID] = true
+			userIDs = append(userIDs, key.UserID)
+		}
+	}
+	return userIDs, nil
+}
+
+func (m *MemoryStore) SubscribeTag(userID, tagID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.classifierName(m.tags, tagID) == nil {
+		return false, nil
+	}
+	key := memoryTagKey{TagID: tagID, UserID: userID}
+	if _, ok := m.tagSubscriptions[key]; !ok {
+		m.tagSubscriptions[key] = time.Now()
+	}
+	return true, nil
+}
+
+func (m *MemoryStore) UnsubscribeTag(userID, tagID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memoryTagKey{TagID: tagID, UserID: userID}
+	if _, ok := m.tagSubscriptions[key]; !ok {
+		return false, nil
+	}
+	delete(m.tagSubscriptions, key)
+	return true, nil
+}
+
+func (m *MemoryStore) FetchTagSubscriptions(userID int) ([]Classifier, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tags := []Classifier{}
+	for _, tag := range m.tags {
+		if _, ok := m.tagSubscriptions[memoryTagKey{TagID: tag.ID, UserID: userID}]; ok {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (m *MemoryStore) FollowUser(followerID, followeeID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if followerID == followeeID {
+		return fmt.Errorf("users cannot follow themselves")
+	}
+	key := memoryFollowKey{FollowerID: followerID, FolloweeID: followeeID}
+	if _, ok := m.follows[key]; !ok {
+		m.follows[key] = time.Now()
+	}
+	return nil
+}
+
+func (m *MemoryStore) UnfollowUser(followerID, followeeID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memoryFollowKey{FollowerID: followerID, FolloweeID: followeeID}
+	if _, ok := m.follows[key]; !ok {
+		return false, nil
+	}
+	delete(m.follows, key)
+	return true, nil
+}
+
+func (m *MemoryStore) FetchFollowedUsers(userID int) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	usernames := []string{}
+	for key := range m.follows {
+		if user, ok := m.users[key.FolloweeID]; ok && key.FollowerID == userID {
+			usernames = append(usernames, user.Username)
+		}
+	}
+	sort.Strings(usernames)
+	return usernames, nil
+}
+
+// --- FeedStore ---
+
+func (m *MemoryStore) FetchFeed(userID, limit int, cursor *ThreadCursor) ([]FeedThread, string, error) {
+	if err := checkFeedCursor(cursor); err != nil {
+		return nil, "", err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Tags of the threads the user liked or saved
+	likedTags := map[int]bool{}
+	for _, t := range m.threads {
+		key := memoryKey{ThreadID: t.ID, UserID: userID}
+		vote, voted := m.votes[key]
+		_, saved := m.saved[key]
+		if (voted && vote.Value == 1) || saved {
+			for _, tagID := range t.TagIDs {
+				likedTags[tagID] = true
+			}
+		}
+	}
+
+	threads := []FeedThread{}
+	for _, t := range m.threads {
+		key := memoryKey{ThreadID: t.ID, UserID: userID}
+		_, voted := m.votes[key]
+		_, saved := m.saved[key]
+		_, subscribed := m.threadSubscriptions[key]
+		if t.Title == nil || t.HiddenAt != nil || t.DeletedAt != nil || t.UserID == userID || voted || saved || subscribed {
+			continue
+		}
+
+		var reasons []string
+		if _, ok := m.follows[memoryFollowKey{FollowerID: userID, FolloweeID: t.UserID}]; ok {
+			reasons = append(reasons, FeedFollowedUser)
+		}
+		for category := m.categoryByID(t.CategoryID); category != nil; {
+			if _, ok := m.categorySubscriptions[memoryCategoryKey{CategoryID: category.ID, UserID: userID}]; ok {
+				reasons = append(reasons, FeedCategory)
+				break
+			}
+			if category.ParentID == nil {
+				break
+			}
+			category = m.categoryByID(*category.ParentID)
+		}
+		if slices.ContainsFunc(t.TagIDs, func(tagID int) bool {
+			_, ok := m.tagSubscriptions[memoryTagKey{TagID: tagID, UserID: userID}]
+			return ok
+		}) {
+			reasons = append(reasons, FeedTag)
+		}
+		if slices.ContainsFunc(t.TagIDs, func(tagID int) bool { return likedTags[tagID] }) {
+			reasons = append(reasons, FeedSimilar)
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+
+		if cursor != nil {
+			key, _ := time.Parse(time.RFC3339Nano, cursor.Key)
+			if t.CreatedAt.After(key) || (t.CreatedAt.Equal(key) && t.ID >= cursor.ID) {
+				continue
+			}
+		}
+		threads = append(threads, FeedThread{Thread: m.toThread(t), Reasons: reasons})
+	}
+	sort.Slice(threads, func(i, j int) bool {
+		a, b := m.threads[threads[i].ID].CreatedAt, m.threads[threads[j].ID].CreatedAt
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return threads[i].ID > threads[j].ID
+	})
+
+	if len(threads) <= limit {
+		return threads, "", nil
+	}
+	threads = threads[:limit]
+	return threads, feedCursor(threads[limit-1]), nil
+}
+
+// --- RevisionStore ---
+
+type memoryRevision struct {
+	ID        int
+	ThreadID  int
+	EditorID  int
+	Title     *string
+	Content   string
+	CreatedAt time.Time
+}
+
+func (m *MemoryStore) FetchRevisions(threadID int) ([]Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := []Revision{}
+	for i := len(m.revisions) - 1; i >= 0; i-- {
+		r := m.revisions[i]
+		if r.ThreadID != threadID {
+			continue
+		}
+		revision := Revision{
+			ID:        r.ID,
+			ThreadID:  r.ThreadID,
+			Title:     r.Title,
+			Content:   r.Content,
+			CreatedAt: memoryTimestamp(r.CreatedAt),
+		}
+		if editor, ok := m.users[r.EditorID]; ok {
+			name := editor.Username
+			revision.Editor = &name
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
+
+func (m *MemoryStore) RollbackThread(threadID, revisionID, editorID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.threads[threadID]
+	if !ok {
+		return false, nil
+	}
+	for _, r := range m.revisions {
+		if r.ID == revisionID && r.ThreadID == threadID {
+			content := r.Content
+			return true, m.reviseThread(t, editorID, r.Title, &content)
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Matches @username mentions that do not follow a word character or another @
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w+)`)
+
+// At most this many users are linked from a single post
+const MaxMentionsPerPost = 20
+
+// Content token types
+const (
+	TokenText    = "text"
+	TokenMention = "mention"
+)
+
+// ContentToken is a run of plain text or a mention of an existing user.
+// Concatenating the Text of every token reproduces the content.
+type ContentToken struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Username string `json:"username,omitempty"` // Set for mentions
+}
+
+// ParseMentions returns the distinct usernames mentioned in content, in order
+// of first appearance
+func ParseMentions(content string) []string {
+	usernames := []string{}
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		if seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		usernames = append(usernames, match[1])
+		if len(usernames) == MaxMentionsPerPost {
+			break
+		}
+	}
+	return usernames
+}
+
+// TokenizeContent splits content into text and mention tokens. Only the given
+// usernames, as stored for the post, become mentions.
+func TokenizeContent(content string, mentioned []string) []ContentToken {
+	known := map[string]bool{}
+	for _, username := range mentioned {
+		known[username] = true
+	}
+
+	tokens := []ContentToken{}
+	text := func(s string) {
+		if s != "" {
+			tokens = append(tokens, ContentToken{Type: TokenText, Text: s})
+		}
+	}
+
+	last := 0
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		start, end := match[2]-1, match[3] // Include the @
+		username := content[match[2]:match[3]]
+		if !known[username] {
+			continue
+		}
+		text(content[last:start])
+		tokens = append(tokens, ContentToken{Type: TokenMention, Text: content[start:end], Username: username})
+		last = end
+	}
+	text(content[last:])
+	return tokens
+}
+
+// SaveMentions links a post to the existing users among usernames, replacing
+// its previous mentions. Returns the IDs of users who were not linked before.
+func (s *PostgresStore) SaveMentions(threadID int, usernames []string) ([]int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		DELETE FROM mentions
+		WHERE thread_id = $1
+			AND user_id NOT IN (SELECT id FROM users WHERE username = ANY($2))
+	`, threadID, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`
+		INSERT INTO mentions (thread_id, user_id)
+		SELECT $1, id FROM users WHERE username = ANY($2)
+		ON CONFLICT DO NOTHING
+		RETURNING user_id
+	`, threadID, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	added := []int{}
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		added = append(added, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return added, tx.Commit()
+}
+
+// FetchMentions returns the usernames mentioned by each of the given posts
+func (s *PostgresStore) FetchMentions(threadIDs []int) (map[int][]string, error) {
+	rows, err := s.db.Query(`
+		SELECT mentions.thread_id, users.username
+		FROM mentions
+		INNER JOIN users ON mentions.user_id = users.id
+		WHERE mentions.thread_id = ANY($1)
+	`, pq.Array(threadIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := map[int][]string{}
+	for rows.Next() {
+		var threadID int
+		var username string
+		if err := rows.Scan(&threadID, &username); err != nil {
+			return nil, err
+		}
+		mentions[threadID] = append(mentions[threadID], username)
+	}
+	return mentions, rows.Err()
+}
+
+// FetchUsernamesByPrefix lists usernames starting with prefix, ignoring case,
+// in alphabetical order
+func (s *PostgresStore) FetchUsernamesByPrefix(prefix string, limit int) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT username FROM users
+		WHERE lower(username) LIKE $1
+		ORDER BY lower(username)
+		LIMIT $2
+	`, likePrefix(strings.ToLower(prefix)), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usernames := []string{}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		usernames = append(usernames, username)
+	}
+	return usernames, rows.Err()
+}
+
+// Builds a LIKE pattern matching values that start with prefix literally
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// How a moderation rule's pattern is matched
+const (
+	RuleWord      = "word"      // Whole words only
+	RuleSubstring = "substring" // Anywhere, including inside words
+	RuleRegex     = "regex"     // Regular expression over the normalized text
+)
+
+// What happens to content matching a moderation rule
+const (
+	ActionReject = "reject" // The write fails with a validation error
+	ActionMask   = "mask"   // Matches are replaced with asterisks
+	ActionFlag   = "flag"   // The post is saved and queued for review
+)
+
+type ModerationRule struct {
+	ID        int     `json:"id"`
+	Pattern   string  `json:"pattern"`
+	Kind      string  `json:"kind"`
+	Action    string  `json:"action"`
+	CreatedBy *string `json:"createdBy,omitempty"` // Nullable for seeded rules
+	CreatedAt string  `json:"createdAt"`
+}
+
+// ModerationFlag is a post that matched a flag rule
+type ModerationFlag struct {
+	ThreadID  int     `json:"threadId"`
+	Title     *string `json:"title,omitempty"` // Nullable for comments
+	Content   string  `json:"content"`
+	Author    string  `json:"author"`
+	RuleID    int     `json:"ruleId"`
+	Pattern   string  `json:"pattern"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+// FetchModerationRules lists every rule, oldest first
+func (s *PostgresStore) FetchModerationRules() ([]ModerationRule, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			moderation_rules.id,
+			moderation_rules.pattern,
+			moderation_rules.kind,
+			moderation_rules.action,
+			users.username,
+			moderation_rules.created_at
+		FROM moderation_rules
+		LEFT JOIN users ON moderation_rules.created_by = users.id
+		ORDER BY moderation_rules.id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []ModerationRule{}
+	for rows.Next() {
+		var rule ModerationRule
+		if err := rows.Scan(&rule.ID, &rule.Pattern, &rule.Kind, &rule.Action, &rule.CreatedBy, &rule.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// CreateModerationRule stores a rule and returns its ID
+func (s *PostgresStore) CreateModerationRule(rule ModerationRule, createdBy int) (int, error) {
+	var id int
+	err := s.db.QueryRow(`
+		INSERT INTO moderation_rules (pattern, kind, action, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, rule.Pattern, rule.Kind, rule.Action, createdBy).Scan(&id)
+	return id, err
+}
+
+// UpdateModerationRule replaces a rule's pattern, kind and action.
+// Returns false if there is no such rule.
+func (s *PostgresStore) UpdateModerationRule(rule ModerationRule) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE moderation_rules SET pattern = $1, kind = $2, action = $3 WHERE id = $4
+	`, rule.Pattern, rule.Kind, rule.Action, rule.ID)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+// DeleteModerationRule removes a rule along with the flags it raised.
+// Returns false if there is no such rule.
+func (s *PostgresStore) DeleteModerationRule(ruleID int) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM moderation_rules WHERE id = $1", ruleID)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+// FlagThread queues a post for review under each of the given rules
+func (s *PostgresStore) FlagThread(threadID int, ruleIDs []int) error {
+	_, err := s.db.Exec(`
+		INSERT INTO moderation_flags (thread_id, rule_id)
+		SELECT $1, rule_id FROM unnest($2::integer[]) AS rule_id
+		ON CONFLICT DO NOTHING
+	`, threadID, pq.Array(ruleIDs))
+	return err
+}
+
+// FetchModerationFlags lists flagged posts, newest first
+func (s *PostgresStore) FetchModerationFlags(limit, offset int) ([]ModerationFlag, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			threads.id,
+			threads.title,
+			threads.content,
+			users.username,
+			moderation_rules.id,
+			moderation_rules.pattern,
+			moderation_flags.created_at
+		FROM moderation_flags
+		INNER JOIN threads ON moderation_flags.thread_id = threads.id
+		INNER JOIN users ON threads.user_id = users.id
+		INNER JOIN moderation_rules ON moderation_flags.rule_id = moderation_rules.id
+		ORDER BY moderation_flags.created_at DESC, threads.id DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := []ModerationFlag{}
+	for rows.Next() {
+		var flag ModerationFlag
+		var title sql.NullString
+		if err := rows.Scan(&flag.ThreadID, &title, &flag.Content, &flag.Author, &flag.RuleID, &flag.Pattern, &flag.CreatedAt); err != nil {
+			return nil, err
+		}
+		if title.Valid {
+			flag.Title = &title.String
+		}
+		flags = append(flags, flag)
+	}
+	return flags, rows.Err()
+}
+
+// ClearModerationFlags resolves every flag on a post.
+// Returns false if the post was not flagged.
+func (s *PostgresStore) ClearModerationFlags(threadID int) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM moderation_flags WHERE thread_id = $1", threadID)
+	if err != nil {
+		return false, err
+	}
+	cleared, err := result.RowsAffected()
+	return cleared > 0, err
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Event types a user can be notified about
+const (
+	NotificationReply         = "reply"
+	NotificationMention       = "mention"
+	NotificationLikeMilestone = "like_milestone"
+	NotificationPromotion     = "promotion"
+	NotificationWarning       = "warning"
+	NotificationThreadReply   = "thread_reply"  // New comment in a subscribed thread
+	NotificationCategoryPost  = "category_post" // New thread in a subscribed category
+)
+
+// NotificationTypes lists every event type, in display order
+var NotificationTypes = []string{
+	NotificationReply,
+	NotificationMention,
+	NotificationLikeMilestone,
+	NotificationPromotion,
+	NotificationWarning,
+	NotificationThreadReply,
+	NotificationCategoryPost,
+}
+
+var ErrUnknownNotificationType = errors.New("unknown notification type")
+
+type Notification struct {
+	ID        int     `json:"id"`
+	Type      string  `json:"type"`
+	Actor     *string `json:"actor,omitempty"`     // Nullable for system events
+	ThreadID  *int    `json:"threadId,omitempty"`  // The post the event is about
+	Milestone *int    `json:"milestone,omitempty"` // Likes reached, for like milestones
+	CreatedAt string  `json:"createdAt"`
+	Read      bool    `json:"read"`
+}
+
+// NewNotification describes an event to deliver to one recipient
+type NewNotification struct {
+	UserID    int
+	Type      string
+	ActorID   *int
+	ThreadID  *int
+	Milestone *int
+}
+
+// CreateNotification records an event unless the recipient has turned its
+// type off. Repeated like milestones are ignored.
+func (s *PostgresStore) CreateNotification(n NewNotification) error {
+	_, err := s.db.Exec(`
+		INSERT INTO notifications (user_id, type, actor_id, thread_id, milestone)
+		SELECT $1::integer, $2::varchar, $3::integer, $4::integer, $5::integer
+		WHERE NOT EXISTS (
+			SELECT 1 FROM notification_preferences
+			WHERE user_id = $1 AND type = $2 AND NOT enabled
+		)
+		ON CONFLICT DO NOTHING
+	`, n.UserID, n.Type, n.ActorID, n.ThreadID, n.Milestone)
+	return err
+}
+
+// FetchNotifications lists a user's notifications, newest first
+func (s *PostgresStore) FetchNotifications(userID int, unreadOnly bool, limit, offset int) ([]Notification, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			notifications.id,
+			notifications.type,
+			actors.username,
+			notifications.thread_id,
+			notifications.milestone,
+			notifications.created_at,
+			notifications.read_at IS NOT NULL
+		FROM notifications
+		LEFT JOIN users AS actors ON notifications.actor_id = actors.id
+		WHERE notifications.user_id = $1 AND (NOT $2 OR notifications.read_at IS NULL)
+		ORDER BY notifications.created_at DESC, notifications.id DESC
+		LIMIT $3 OFFSET $4
+	`, userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.Type, &n.Actor, &n.ThreadID, &n.Milestone, &n.CreatedAt, &n.Read); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// CountUnreadNotifications counts a user's unread notifications
+func (s *PostgresStore) CountUnreadNotifications(userID int) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL", userID).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationRead marks one of a user's notifications as read.
+// Returns false if the user has no such notification.
+func (s *PostgresStore) MarkNotificationRead(userID, notificationID int) (bool, error) {
+	var id int
+	err := s.db.QueryRow(`
+		UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+		WHERE id = $1 AND user_id = $2
+		RETURNING id
+	`, notificationID, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// MarkAllNotificationsRead marks every unread notification of a user as read
+// and returns how many changed
+func (s *PostgresStore) MarkAllNotificationsRead(userID int) (int64, error) {
+	result, err := s.db.Exec("UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND read_at IS NULL", userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// FetchNotificationPreferences reports which event types a user receives
+func (s *PostgresStore) FetchNotificationPreferences(userID int) (map[string]bool, error) {
+	preferences := map[string]bool{}
+	for _, kind := range NotificationTypes {
+		preferences[kind] = true
+	}
+
+	rows, err := s.db.Query("SELECT type, enabled FROM notification_preferences WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind string
+		var enabled bool
+		if err := rows.Scan(&kind, &enabled); err != nil {
+			return nil, err
+		}
+		preferences[kind] = enabled
+	}
+	return preferences, rows.Err()
+}
+
+// UpdateNotificationPreferences turns event types on or off for a user.
+// Types not mentioned keep their current setting.
+func (s *PostgresStore) UpdateNotificationPreferences(userID int, preferences map[string]bool) error {
+	if err := validateNotificationTypes(preferences); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for kind, enabled := range preferences {
+		_, err := tx.Exec(`
+			INSERT INTO notification_preferences (user_id, type, enabled) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled
+		`, userID, kind, enabled)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Rejects preference keys that are not known event types
+func validateNotificationTypes(preferences map[string]bool) error {
+	for kind := range preferences {
+		known := false
+		for _, t := range NotificationTypes {
+			known = known || t == kind
+		}
+		if !known {
+			return ErrUnknownNotificationType
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	ErrInvalidWindow = errors.New("window must be day, week, month or all")
+	ErrInvalidOrder  = errors.New("order must be asc or desc")
+	ErrInvalidMatch  = errors.New("tagMatch must be any or all")
+)
+
+// ThreadListing selects which top-level threads a listing returns and how
+// they are ranked
+type ThreadListing struct {
+	Query    string
+	SortBy   string   // created_at, likes, dislikes, comments, relevance, hot, top, controversial or rising
+	Window   string   // day, week, month or all; limits listings to recent threads
+	Order    string   // desc (default) or asc
+	Tags     []string // Threads carrying any or all of these tags
+	TagMatch string   // any (default) or all
+	Category string
+}
+
+// A ranking orders threads by a SQL expression over the threads table.
+// The Go version scores the same formula for the in-memory store.
+type ranking struct {
+	expr          string
+	score         func(thread Thread, now time.Time) float64
+	defaultWindow string
+}
+
+// Seconds of age that outweigh a tenfold increase in score for "hot";
+// keep in step with the SQL expression
+const hotDecaySeconds = 45000
+
+var rankings = map[string]ranking{
+	"created_at": {
+		expr: "threads.created_at",
+		score: func(thread Thread, _ time.Time) float64 {
+			return float64(threadTime(thread).UnixNano())
+		},
+	},
+	"likes": {
+		expr:  "threads.likes_count",
+		score: func(thread Thread, _ time.Time) float64 { return float64(thread.LikesCount) },
+	},
+	"dislikes": {
+		expr:  "threads.dislikes_count",
+		score: func(thread Thread, _ time.Time) float64 { return float64(thread.DislikesCount) },
+	},
+	"comments": {
+		expr:  "threads.comments_count",
+		score: func(thread Thread, _ time.Time) float64 { return float64(thread.CommentsCount) },
+	},
+	// Net score with a time decay, so new threads can outrank older popular ones
+	"hot": {
+		expr: "SIGN(threads.score) * LOG(GREATEST(ABS(threads.score), 1)) + EXTRACT(EPOCH FROM threads.created_at) / 45000",
+		score: func(thread Thread, _ time.Time) float64 {
+			score := float64(thread.Score)
+			magnitude := math.Copysign(math.Log10(math.Max(math.Abs(score), 1)), score)
+			return magnitude + float64(threadTime(thread).Unix())/hotDecaySeconds
+		},
+	},
+	// Net score, usually combined with a window
+	"top": {
+		expr:  "threads.score",
+		score: func(thread Thread, _ time.Time) float64 { return float64(thread.Score) },
+	},
+	// Many votes split evenly between likes and dislikes
+	"controversial": {
+		expr: `CASE WHEN threads.likes_count = 0 OR threads.dislikes_count = 0 THEN 0
+			ELSE POWER(threads.likes_count + threads.dislikes_count,
+				LEAST(threads.likes_count, threads.dislikes_count)::float / GREATEST(threads.likes_count, threads.dislikes_count))
+			END`,
+		score: func(thread Thread, _ time.Time) float64 {
+			up, down := float64(thread.LikesCount), float64(thread.DislikesCount)
+			if up == 0 || down == 0 {
+				return 0
+			}
+			return math.Pow(up+down, math.Min(up, down)/math.Max(up, down))
+		},
+	},
+	// Votes and replies per hour of age among recent threads
+	"rising": {
+		expr: `(threads.likes_count + threads.dislikes_count + threads.comments_count)
+			/ POWER(EXTRACT(EPOCH FROM LOCALTIMESTAMP - threads.created_at) / 3600 + 2, 1.5)`,
+		score: func(thread Thread, now time.Time) float64 {
+			activity := float64(thread.LikesCount + thread.DislikesCount + thread.CommentsCount)
+			return activity / math.Pow(now.Sub(threadTime(thread)).Hours()+2, 1.5)
+		},
+		defaultWindow: "day",
+	},
+}
+
+// Lookback for each window, as a SQL interval and a Go duration
+var rankingWindows = map[string]struct {
+	interval string
+	duration time.Duration
+}{
+	"day":   {"1 day", 24 * time.Hour},
+	"week":  {"7 days", 7 * 24 * time.Hour},
+	"month": {"1 month", 30 * 24 * time.Hour},
+}
+
+// Checks the listing and fills in defaults for sort, window and order.
+// Unknown sorts fall back to created_at as they always have.
+func (l ThreadListing) normalize() (ThreadListing, error) {
+	rank, ok := rankings[l.SortBy]
+	if !ok && l.SortBy != "relevance" {
+		l.SortBy = "created_at"
+	}
+	if l.Window == "" {
+		l.Window = rank.defaultWindow
+	}
+	if l.Window == "all" {
+		l.Window = ""
+	}
+	if _, ok := rankingWindows[l.Window]; !ok && l.Window != "" {
+		return l, ErrInvalidWindow
+	}
+	switch l.Order {
+	case "":
+		l.Order = "desc"
+	case "asc", "desc":
+	default:
+		return l, ErrInvalidOrder
+	}
+	l.Tags = CleanTags(l.Tags)
+	switch l.TagMatch {
+	case "":
+		l.TagMatch = "any"
+	case "any", "all":
+	default:
+		return l, ErrInvalidMatch
+	}
+	return l, nil
+}
+
+// SQL direction keyword for the listing's order
+func (l ThreadListing) direction() string {
+	if l.Order == "asc" {
+		return "ASC"
+	}
+	return "DESC"
+}
+
+// Parses a thread's creation time as rendered by database/sql
+func threadTime(thread Thread) time.Time {
+	created, _ := time.Parse(time.RFC3339Nano, thread.CreatedAt)
+	return created
+}
@@ -0,0 +1,267 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Reasons a post can be reported for
+const (
+	ReportSpam           = "spam"
+	ReportHarassment     = "harassment"
+	ReportHate           = "hate"
+	ReportViolence       = "violence"
+	ReportSexual         = "sexual"
+	ReportMisinformation = "misinformation"
+	ReportOffTopic       = "off_topic"
+	ReportOther          = "other"
+)
+
+// ReportReasons lists every reason code, in display order
+var ReportReasons = []string{
+	ReportSpam,
+	ReportHarassment,
+	ReportHate,
+	ReportViolence,
+	ReportSexual,
+	ReportMisinformation,
+	ReportOffTopic,
+	ReportOther,
+}
+
+// Moderator actions that resolve a post's open reports
+const (
+	ReportDismiss = "dismiss" // No violation; reports are closed
+	ReportHide    = "hide"    // The post is hidden from public view
+	ReportDelete  = "delete"  // The post is deleted, leaving a tombstone over its replies
+	ReportWarn    = "warn"    // The author is sent a warning
+)
+
+// ReportActions lists every action a moderator can take
+var ReportActions = []string{ReportDismiss, ReportHide, ReportDelete, ReportWarn}
+
+var (
+	ErrDuplicateReport = errors.New("you have already reported this post")
+	ErrNoOpenReports   = errors.New("post has no open reports")
+)
+
+// ReportedItem is a post in the moderation queue with its open reports
+type ReportedItem struct {
+	ThreadID        int            `json:"threadId"`
+	Title           *string        `json:"title,omitempty"`    // Nullable for comments
+	ParentID        *int           `json:"parentId,omitempty"` // Set for comments
+	Content         string         `json:"content"`
+	Author          string         `json:"author"`
+	Hidden          bool           `json:"hidden"`
+	ReportCount     int            `json:"reportCount"`
+	Reasons         map[string]int `json:"reasons"` // Open reports per reason code
+	FirstReportedAt string         `json:"firstReportedAt"`
+	LastReportedAt  string         `json:"lastReportedAt"`
+}
+
+// ModerationAction records a moderator's decision on a reported post
+type ModerationAction struct {
+	ID              int     `json:"id"`
+	ThreadID        int     `json:"threadId"`
+	Action          string  `json:"action"`
+	Moderator       *string `json:"moderator,omitempty"` // Nullable once the account is gone
+	Author          *string `json:"author,omitempty"`
+	Note            *string `json:"note,omitempty"`
+	ReportsResolved int     `json:"reportsResolved"`
+	CreatedAt       string  `json:"createdAt"`
+}
+
+// CreateReport records a user's report of a post
+func (s *PostgresStore) CreateReport(threadID, reporterID int, reason string, details *string) (int, error) {
+	var id int
+	err := s.db.QueryRow(`
+		INSERT INTO reports (thread_id, reporter_id, reason, details)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, threadID, reporterID, reason, details).Scan(&id)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return 0, ErrDuplicateReport
+	}
+	return id, err
+}
+
+// FetchReportQueue lists posts with open reports, most reported first
+func (s *PostgresStore) FetchReportQueue(limit, offset int) ([]ReportedItem, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			threads.id,
+			threads.title,
+			threads.parent_id,
+			threads.content,
+			users.username,
+			threads.hidden_at IS NOT NULL,
+			COUNT(*),
+			array_agg(reports.reason),
+			MIN(reports.created_at),
+			MAX(reports.created_at)
+		FROM reports
+		INNER JOIN threads ON reports.thread_id = threads.id
+		INNER JOIN users ON threads.user_id = users.id
+		WHERE reports.resolved_at IS NULL
+		GROUP BY threads.id, users.username
+		ORDER BY COUNT(*) DESC, MAX(reports.created_at) DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []ReportedItem{}
+	for rows.Next() {
+		var item ReportedItem
+		var title sql.NullString
+		var parentID sql.NullInt64
+		var reasons []string
+		if err := rows.Scan(
+			&item.ThreadID,
+			&title,
+			&parentID,
+			&item.Content,
+			&item.Author,
+			&item.Hidden,
+			&item.ReportCount,
+			pq.Array(&reasons),
+			&item.FirstReportedAt,
+			&item.LastReportedAt,
+		); err != nil {
+			return nil, err
+		}
+		if title.Valid {
+			item.Title = &title.String
+		}
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			item.ParentID = &id
+		}
+		item.Reasons = countReasons(reasons)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// ResolveReports records a moderator's action on a post and closes its open
+// reports. Hiding takes effect here; deleting and warning are left to the caller.
+func (s *PostgresStore) ResolveReports(threadID, moderatorID int, action string, note *string) (*ModerationAction, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var actionID int
+	err = tx.QueryRow(`
+		INSERT INTO moderation_actions (thread_id, author_id, moderator_id, action, note)
+		SELECT id, user_id, $2, $3, $4 FROM threads WHERE id = $1
+		RETURNING id
+	`, threadID, moderatorID, action, note).Scan(&actionID)
+	if err == sql.ErrNoRows {
+		return nil, ErrThreadNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE reports SET resolved_at = CURRENT_TIMESTAMP, action_id = $2
+		WHERE thread_id = $1 AND resolved_at IS NULL
+	`, threadID, actionID)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if resolved == 0 {
+		return nil, ErrNoOpenReports
+	}
+
+	if action == ReportHide {
+		if _, err := tx.Exec("UPDATE threads SET hidden_at = COALESCE(hidden_at, CURRENT_TIMESTAMP) WHERE id = $1", threadID); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE moderation_actions SET reports_resolved = $2 WHERE id = $1", actionID, resolved); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.fetchModerationAction(actionID)
+}
+
+// FetchModerationActions lists moderator decisions, newest first
+func (s *PostgresStore) FetchModerationActions(limit, offset int) ([]ModerationAction, error) {
+	rows, err := s.db.Query(moderationActionQuery+`
+		ORDER BY moderation_actions.created_at DESC, moderation_actions.id DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []ModerationAction{}
+	for rows.Next() {
+		action, err := scanModerationAction(rows)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, *action)
+	}
+	return actions, rows.Err()
+}
+
+const moderationActionQuery = `
+	SELECT
+		moderation_actions.id,
+		moderation_actions.thread_id,
+		moderation_actions.action,
+		moderators.username,
+		authors.username,
+		moderation_actions.note,
+		moderation_actions.reports_resolved,
+		moderation_actions.created_at
+	FROM moderation_actions
+	LEFT JOIN users AS moderators ON moderation_actions.moderator_id = moderators.id
+	LEFT JOIN users AS authors ON moderation_actions.author_id = authors.id
+`
+
+func (s *PostgresStore) fetchModerationAction(actionID int) (*ModerationAction, error) {
+	return scanModerationAction(s.db.QueryRow(moderationActionQuery+"WHERE moderation_actions.id = $1", actionID))
+}
+
+func scanModerationAction(row interface{ Scan(...interface{}) error }) (*ModerationAction, error) {
+	var action ModerationAction
+	err := row.Scan(
+		&action.ID,
+		&action.ThreadID,
+		&action.Action,
+		&action.Moderator,
+		&action.Author,
+		&action.Note,
+		&action.ReportsResolved,
+		&action.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &action, nil
+}
+
+// Tallies reason codes
+func countReasons(reasons []string) map[string]int {
+	counts := map[string]int{}
+	for _, reason := range reasons {
+		counts[reason]++
+	}
+	return counts
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// Revision is what a thread or comment said before one of its edits
+type Revision struct {
+	ID        int     `json:"id"`
+	ThreadID  int     `json:"threadId"`
+	Editor    *string `json:"editor"`          // Who made the edit; nil if their account is gone
+	Title     *string `json:"title,omitempty"` // Nullable for comments
+	Content   string  `json:"content"`
+	CreatedAt string  `json:"createdAt"` // When the edit replaced this version
+}
+
+// FetchRevisions lists a post's revisions, newest first
+func (s *PostgresStore) FetchRevisions(threadID int) ([]Revision, error) {
+	rows, err := s.db.Query(`
+		SELECT r.id, r.thread_id, users.username, r.title, r.content, r.created_at
+		FROM thread_revisions r
+		LEFT JOIN users ON r.editor_id = users.id
+		WHERE r.thread_id = $1
+		ORDER BY r.id DESC
+	`, threadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		var revision Revision
+		if err := rows.Scan(
+			&revision.ID,
+			&revision.ThreadID,
+			&revision.Editor,
+			&revision.Title,
+			&revision.Content,
+			&revision.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// RollbackThread restores the title and content a revision recorded. The
+// rollback is an edit itself, so the version it replaces becomes a revision
+// too. Returns false if the revision does not belong to the post, and
+// ErrDuplicateTitle if another thread has taken its title since.
+func (s *PostgresStore) RollbackThread(threadID, revisionID, editorID int) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var title sql.NullString
+	var content string
+	err = tx.QueryRow(
+		"SELECT title, content FROM thread_revisions WHERE id = $1 AND thread_id = $2",
+		revisionID, threadID,
+	).Scan(&title, &content)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	var titlePtr *string
+	if title.Valid {
+		titlePtr = &title.String
+	}
+	if err := reviseThread(tx, threadID, editorID, titlePtr, &content); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
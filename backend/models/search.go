@@ -0,0 +1,201 @@
+package models
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+type SearchResult struct {
+	Type      string  `json:"type"` // "thread", "comment" or "user"
+	ID        int     `json:"id"`
+	Title     *string `json:"title,omitempty"`
+	Author    string  `json:"author,omitempty"`
+	ParentID  *int    `json:"parentId,omitempty"` // Set for comments
+	Snippet   string  `json:"snippet"`            // HTML-escaped, matches wrapped in <mark>
+	Rank      float64 `json:"rank"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+// ts_headline markers; control characters pass through HTML escaping
+// untouched, so they are swapped for <mark> afterwards
+const headlineOptions = "StartSel=\x02, StopSel=\x03, MaxWords=35, MinWords=15, MaxFragments=2"
+
+// BuildTSQuery converts search box syntax into a to_tsquery expression.
+// Terms are ANDed; "quoted text" matches a phrase, a trailing * matches a
+// prefix and a leading - excludes the term. Returns "" if nothing is searchable.
+func BuildTSQuery(input string) string {
+	var terms []string
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		negate := false
+		if runes[i] == '-' {
+			negate = true
+			i++
+		}
+
+		// Read either a quoted phrase or a bare word
+		var raw string
+		quoted := i < len(runes) && runes[i] == '"'
+		if quoted {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			raw = string(runes[i+1 : min(end, len(runes))])
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			raw = string(runes[i:end])
+			i = end
+		}
+
+		prefix := !quoted && strings.HasSuffix(raw, "*")
+		words := strings.FieldsFunc(raw, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			continue
+		}
+		for j, word := range words {
+			words[j] = strings.ToLower(word)
+		}
+		if prefix {
+			words[len(words)-1] += ":*"
+		}
+
+		term := strings.Join(words, " <-> ")
+		if len(words) > 1 {
+			term = "(" + term + ")"
+		}
+		if negate {
+			term = "!" + term
+		}
+		terms = append(terms, term)
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+// Comments are only found while the thread they were posted in is itself
+// listed: neither hidden nor deleted
+const visibleRoot = `EXISTS (
+	WITH RECURSIVE ancestors AS (
+		SELECT a.id, a.parent_id, a.hidden_at, a.deleted_at FROM threads a WHERE a.id = threads.parent_id
+		UNION ALL
+		SELECT p.id, p.parent_id, p.hidden_at, p.deleted_at
+		FROM threads p
+		INNER JOIN ancestors ON p.id = ancestors.parent_id
+	)
+	SELECT 1 FROM ancestors
+	WHERE parent_id IS NULL AND hidden_at IS NULL AND deleted_at IS NULL
+)`
+
+// SearchThreads ranks threads (or comments) matching a tsquery built by BuildTSQuery
+func (s *PostgresStore) SearchThreads(tsQuery string, comments bool, limit, offset int) ([]SearchResult, error) {
+	kind := "threads.title IS NOT NULL"
+	resultType := "thread"
+	if comments {
+		kind = "threads.title IS NULL AND " + visibleRoot
+		resultType = "comment"
+	}
+
+	// Rank and page first so ts_headline only runs on the returned rows
+	rows, err := s.db.Query(`
+		SELECT
+			matches.id,
+			matches.title,
+			users.username,
+			matches.parent_id,
+			ts_headline('english', matches.content, query, $4),
+			matches.rank,
+			matches.created_at
+		FROM (
+			SELECT threads.*, ts_rank(search_vector, query) AS rank, query
+			FROM threads, to_tsquery('english', $1) AS query
+			WHERE search_vector @@ query AND hidden_at IS NULL AND deleted_at IS NULL AND `+kind+`
+			ORDER BY rank DESC, threads.created_at DESC
+			LIMIT $2 OFFSET $3
+		) AS matches
+		INNER JOIN users ON matches.user_id = users.id
+		ORDER BY matches.rank DESC, matches.created_at DESC
+	`, tsQuery, limit, offset, headlineOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		result := SearchResult{Type: resultType}
+		if err := rows.Scan(
+			&result.ID,
+			&result.Title,
+			&result.Author,
+			&result.ParentID,
+			&result.Snippet,
+			&result.Rank,
+			&result.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		result.Snippet = markHeadline(result.Snippet)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// SearchUsers ranks users whose name or bio match a tsquery built by BuildTSQuery
+func (s *PostgresStore) SearchUsers(tsQuery string, limit, offset int) ([]SearchResult, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			matches.id,
+			matches.username,
+			ts_headline('simple', coalesce(matches.bio, ''), query, $4),
+			matches.rank,
+			matches.created_at
+		FROM (
+			SELECT users.*, ts_rank(search_vector, query) AS rank, query
+			FROM users, to_tsquery('simple', $1) AS query
+			WHERE search_vector @@ query
+			ORDER BY rank DESC, users.username ASC
+			LIMIT $2 OFFSET $3
+		) AS matches
+		ORDER BY matches.rank DESC, matches.username ASC
+	`, tsQuery, limit, offset, headlineOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		result := SearchResult{Type: "user"}
+		if err := rows.Scan(
+			&result.ID,
+			&result.Author,
+			&result.Snippet,
+			&result.Rank,
+			&result.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		result.Snippet = markHeadline(result.Snippet)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// Escapes a ts_headline fragment and turns its markers into <mark> tags
+func markHeadline(headline string) string {
+	escaped := html.EscapeString(headline)
+	return strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>").Replace(escaped)
+}
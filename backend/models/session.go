@@ -0,0 +1,144 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+// Expiry is computed by the database so it shares a clock and time zone with
+// the CURRENT_TIMESTAMP comparisons below
+const refreshExpiry = "CURRENT_TIMESTAMP + make_interval(secs => $3)"
+
+// CreateRefreshToken stores the hash of a newly issued refresh token
+func (s *PostgresStore) CreateRefreshToken(userID int, tokenHash string, ttl time.Duration) error {
+	_, err := s.db.Exec(
+		"INSERT INTO refresh_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, "+refreshExpiry+")",
+		userID, tokenHash, ttl.Seconds(),
+	)
+	return err
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one and returns its owner.
+// Presenting an already rotated token revokes every session of that user.
+func (s *PostgresStore) RotateRefreshToken(oldHash, newHash string, ttl time.Duration) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var tokenID, userID int
+	var expired, revoked bool
+	err = tx.QueryRow(`
+		SELECT id, user_id, expires_at < CURRENT_TIMESTAMP, revoked_at IS NOT NULL
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, oldHash).Scan(&tokenID, &userID, &expired, &revoked)
+	if err == sql.ErrNoRows {
+		return "", ErrRefreshTokenInvalid
+	} else if err != nil {
+		return "", err
+	}
+
+	// A revoked token being replayed suggests theft, so end every session
+	if revoked {
+		if err := revokeUserSessions(tx, userID); err != nil {
+			return "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return "", err
+		}
+		return "", ErrRefreshTokenReused
+	}
+	if expired {
+		return "", ErrRefreshTokenInvalid
+	}
+
+	var newID int
+	err = tx.QueryRow(
+		"INSERT INTO refresh_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, "+refreshExpiry+") RETURNING id",
+		userID, newHash, ttl.Seconds(),
+	).Scan(&newID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP, replaced_by = $1 WHERE id = $2",
+		newID, tokenID,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	var username string
+	if err := tx.QueryRow("SELECT username FROM users WHERE id = $1", userID).Scan(&username); err != nil {
+		return "", err
+	}
+
+	return username, tx.Commit()
+}
+
+// RevokeRefreshToken revokes one of the user's refresh tokens
+func (s *PostgresStore) RevokeRefreshToken(userID int, tokenHash string) error {
+	_, err := s.db.Exec(`
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND token_hash = $2 AND revoked_at IS NULL
+	`, userID, tokenHash)
+	return err
+}
+
+// RevokeAccessToken adds an access token to the revocation list until it expires
+func (s *PostgresStore) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	// Expired entries can never match a valid token, so prune them here
+	if _, err := s.db.Exec("DELETE FROM revoked_tokens WHERE expires_at < CURRENT_TIMESTAMP"); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec(
+		"INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, to_timestamp($2)) ON CONFLICT (jti) DO NOTHING",
+		jti, expiresAt.Unix(),
+	)
+	return err
+}
+
+// IsAccessTokenRevoked reports whether an access token was logged out or
+// issued before the user's sessions were invalidated
+func (s *PostgresStore) IsAccessTokenRevoked(jti string, username string, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := s.db.QueryRow(`
+		SELECT
+			EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1)
+			OR EXISTS(
+				SELECT 1 FROM users
+				WHERE username = $2 AND sessions_valid_after > to_timestamp(0) + $3::bigint * INTERVAL '1 microsecond'
+			)
+	`, jti, username, issuedAt.UnixMicro()).Scan(&revoked)
+	return revoked, err
+}
+
+// Revokes all refresh tokens and invalidates access tokens issued so far
+func revokeUserSessions(tx *sql.Tx, userID int) error {
+	_, err := tx.Exec(`
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	// Taken from the clock that stamps iat_us, at its microsecond precision,
+	// so tokens issued right after this are not caught by it
+	_, err = tx.Exec(
+		"UPDATE users SET sessions_valid_after = to_timestamp(0) + $2::bigint * INTERVAL '1 microsecond' WHERE id = $1",
+		userID, time.Now().UnixMicro(),
+	)
+	return err
+}
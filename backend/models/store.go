@@ -0,0 +1,201 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ThreadStore persists threads and comments, and lists tags
+type ThreadStore interface {
+	FetchThreads(listing ThreadListing, limit, offset int) ([]Thread, error)
+	FetchThreadsByCursor(listing ThreadListing, limit int, cursor *ThreadCursor) ([]Thread, string, string, error)
+	FetchPinnedThreads(listing ThreadListing) ([]Thread, error)
+	GetThreadCount(listing ThreadListing) int
+	FetchThreadByID(threadID int) (*Thread, error)
+	FetchCommentsByThreadID(threadID int, searchQuery, sortBy string) ([]Thread, error)
+	FetchCommentTree(parentID int, query CommentTreeQuery) ([]*CommentNode, int, error)
+	FetchTags() ([]Classifier, error)
+	CheckThreadExists(threadID int) error
+	TitleExists(title string) (bool, error)
+	GetThreadDepth(threadID int) (int, error)
+	FetchRootThreadID(threadID int) (int, error)
+	CreateThread(title *string, content *string, userID int, categoryID int, tags []string, createTags bool) (int, error)
+	UpdateThread(threadID, editorID int, title, content *string) error
+	DeleteThread(threadID, deletedBy int) error
+	RestoreThread(threadID int) (bool, error)
+	PurgeThread(threadID int) (bool, error)
+	CreateComment(content string, userID int, parentID int, depth int) (int, error)
+	SearchThreads(tsQuery string, comments bool, limit, offset int) ([]SearchResult, error)
+}
+
+// UserStore persists accounts, profiles and login sessions
+type UserStore interface {
+	FetchPrincipal(username string) (*Principal, error)
+	IsAdmin(username string) (bool, error)
+	CheckThreadOwnershipOrAdmin(username string, userID int, threadID int) bool
+	GetUserIDFromUsername(username string) (int, error)
+	CheckUsernameExists(username string) (bool, error)
+	CreateUser(username string, password string) error
+	GetPassword(username string) (string, error)
+	UpdatePassword(username string, newPassword string) error
+	UpdateBio(bio string, username string) error
+	PromoteUser(username string) error
+	DemoteUser(username string) error
+	FetchUserScores(username string) (*UserScores, error)
+	FetchLeaderboard() ([]UserScores, error)
+	FetchUserInfo(username string) (*UserInfo, error)
+	FetchUserMetrics(username string) (*UserMetrics, error)
+	FetchUserActivity(username string) (*UserActivity, error)
+	FetchUserSavedThreads(userID int) ([]SavedThread, error)
+	SearchUsers(tsQuery string, limit, offset int) ([]SearchResult, error)
+	FetchUsernamesByPrefix(prefix string, limit int) ([]string, error)
+
+	CreateRefreshToken(userID int, tokenHash string, ttl time.Duration) error
+	RotateRefreshToken(oldHash, newHash string, ttl time.Duration) (string, error)
+	RevokeRefreshToken(userID int, tokenHash string) error
+	RevokeAccessToken(jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(jti string, username string, issuedAt time.Time) (bool, error)
+}
+
+// InteractionStore persists votes and saved threads
+type InteractionStore interface {
+	GetInteractionState(threadID, userID int) (bool, bool, error)
+	GetVote(threadID, userID int) (int, error)
+	Vote(threadID, userID, value int) error
+	FetchUserVotes(userID int) ([]Vote, error)
+	GetLikesCount(threadID int) (int, error)
+	GetDislikesCount(threadID int) (int, error)
+	AddLike(threadID, userID int) error
+	AddDislike(threadID, userID int) error
+	RemoveLike(threadID, userID int) error
+	RemoveDislike(threadID, userID int) error
+	FetchSaveState(threadID, userID int) (bool, error)
+	SaveThread(threadID, userID int) error
+	UnsaveThread(threadID, userID int) error
+}
+
+// NotificationStore persists notifications and per-user delivery preferences
+type NotificationStore interface {
+	CreateNotification(n NewNotification) error
+	FetchNotifications(userID int, unreadOnly bool, limit, offset int) ([]Notification, error)
+	CountUnreadNotifications(userID int) (int, error)
+	MarkNotificationRead(userID, notificationID int) (bool, error)
+	MarkAllNotificationsRead(userID int) (int64, error)
+	FetchNotificationPreferences(userID int) (map[string]bool, error)
+	UpdateNotificationPreferences(userID int, preferences map[string]bool) error
+}
+
+// MentionStore links posts to the users they mention
+type MentionStore interface {
+	SaveMentions(threadID int, usernames []string) ([]int, error)
+	FetchMentions(threadIDs []int) (map[int][]string, error)
+}
+
+// ModerationStore persists moderation rules and the posts they flag
+type ModerationStore interface {
+	FetchModerationRules() ([]ModerationRule, error)
+	CreateModerationRule(rule ModerationRule, createdBy int) (int, error)
+	UpdateModerationRule(rule ModerationRule) (bool, error)
+	DeleteModerationRule(ruleID int) (bool, error)
+	FlagThread(threadID int, ruleIDs []int) error
+	FetchModerationFlags(limit, offset int) ([]ModerationFlag, error)
+	ClearModerationFlags(threadID int) (bool, error)
+}
+
+// ReportStore persists user reports and the moderator actions resolving them
+type ReportStore interface {
+	CreateReport(threadID, reporterID int, reason string, details *string) (int, error)
+	FetchReportQueue(limit, offset int) ([]ReportedItem, error)
+	ResolveReports(threadID, moderatorID int, action string, note *string) (*ModerationAction, error)
+	FetchModerationActions(limit, offset int) ([]ModerationAction, error)
+}
+
+// RevisionStore keeps the versions a post had before each edit
+type RevisionStore interface {
+	FetchRevisions(threadID int) ([]Revision, error)
+	RollbackThread(threadID, revisionID, editorID int) (bool, error)
+}
+
+// ThreadStatusStore holds the admin controls on top-level threads: pins,
+// locks and announcements
+type ThreadStatusStore interface {
+	PinThread(threadID int, scope string) (bool, error)
+	LockThread(threadID int, locked bool) (bool, error)
+	SetAnnouncement(threadID int, announcement bool) (bool, error)
+	IsThreadLocked(threadID int) (bool, error)
+}
+
+// CategoryStore lets admins arrange categories into sub-forums
+type CategoryStore interface {
+	FetchCategoryTree() ([]*CategoryNode, error)
+	FetchCategory(ref string) (*Category, error)
+	CreateCategory(category Category) (int, error)
+	UpdateCategory(category Category) (bool, error)
+	DeleteCategory(categoryID int) (bool, error)
+}
+
+// TagStore lets admins curate the tags threads are filed under
+type TagStore interface {
+	CreateTag(name string) (int, error)
+	RenameTag(tagID int, name string) (bool, error)
+	MergeTags(fromID, intoID int) (bool, error)
+	DeleteTag(tagID int) (bool, error)
+}
+
+// SubscriptionStore tracks the threads, categories, tags and users people
+// follow for new activity
+type SubscriptionStore interface {
+	SubscribeThread(userID, threadID int) error
+	UnsubscribeThread(userID, threadID int) (bool, error)
+	MarkThreadRead(userID, threadID int) (bool, error)
+	FetchThreadSubscriptions(userID, limit, offset int) ([]SubscribedThread, error)
+	FetchThreadSubscribers(threadID int) ([]int, error)
+	SubscribeCategory(userID, categoryID int) (bool, error)
+	UnsubscribeCategory(userID, categoryID int) (bool, error)
+	FetchCategorySubscriptions(userID int) ([]Category, error)
+	FetchCategorySubscribers(categoryID int) ([]int, error)
+	SubscribeTag(userID, tagID int) (bool, error)
+	UnsubscribeTag(userID, tagID int) (bool, error)
+	FetchTagSubscriptions(userID int) ([]Classifier, error)
+	FollowUser(followerID, followeeID int) error
+	UnfollowUser(followerID, followeeID int) (bool, error)
+	FetchFollowedUsers(userID int) ([]string, error)
+}
+
+// FeedStore picks threads for each user's personalized feed
+type FeedStore interface {
+	FetchFeed(userID, limit int, cursor *ThreadCursor) ([]FeedThread, string, error)
+}
+
+// Store combines every store; both backends implement it in full
+type Store interface {
+	ThreadStore
+	UserStore
+	InteractionStore
+	NotificationStore
+	MentionStore
+	ModerationStore
+	ReportStore
+	RevisionStore
+	ThreadStatusStore
+	CategoryStore
+	TagStore
+	SubscriptionStore
+	FeedStore
+}
+
+// PostgresStore implements every store on top of a PostgreSQL database
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore wraps an open database connection
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Compile-time checks that both implementations satisfy every store
+var (
+	_ Store = (*PostgresStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
@@ -0,0 +1,285 @@
+package models
+
+import "fmt"
+
+// SubscribedThread is a followed thread with the activity its subscriber
+// has not seen yet
+type SubscribedThread struct {
+	Thread
+	UnreadCount    int    `json:"unreadCount"` // Visible comments by others since LastReadAt
+	LastReadAt     string `json:"lastReadAt"`
+	LatestActivity string `json:"latestActivity"` // Newest visible post in the thread
+}
+
+// SubscribeThread follows a top-level thread and marks it read
+func (s *PostgresStore) SubscribeThread(userID, threadID int) error {
+	_, err := s.db.Exec(`
+		INSERT INTO thread_subscriptions (user_id, thread_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, thread_id) DO UPDATE SET last_read_at = CURRENT_TIMESTAMP
+	`, userID, threadID)
+	return err
+}
+
+// UnsubscribeThread stops following a thread. Returns false if the user
+// did not follow it.
+func (s *PostgresStore) UnsubscribeThread(userID, threadID int) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM thread_subscriptions WHERE user_id = $1 AND thread_id = $2", userID, threadID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// MarkThreadRead moves a subscription's last-read marker to now. Returns
+// false if the user does not follow the thread.
+func (s *PostgresStore) MarkThreadRead(userID, threadID int) (bool, error) {
+	result, err := s.db.Exec(
+		"UPDATE thread_subscriptions SET last_read_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND thread_id = $2",
+		userID, threadID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// FetchThreadSubscriptions lists the visible threads a user follows with
+// their unread counts, most recently active first
+func (s *PostgresStore) FetchThreadSubscriptions(userID, limit, offset int) ([]SubscribedThread, error) {
+	rows, err := s.db.Query(`
+		SELECT listing.*, subscription.last_read_at, activity.unread, activity.latest
+		FROM (`+fmt.Sprintf(threadListQuery, "AND threads.hidden_at IS NULL AND threads.deleted_at IS NULL")+`) AS listing
+		INNER JOIN thread_subscriptions subscription
+			ON subscription.thread_id = listing.id AND subscription.user_id = $1
+		CROSS JOIN LATERAL (
+			WITH RECURSIVE replies AS (
+				SELECT id, user_id, created_at, hidden_at, deleted_at FROM threads WHERE parent_id = listing.id
+				UNION ALL
+				SELECT threads.id, threads.user_id, threads.created_at, threads.hidden_at, threads.deleted_at
+				FROM threads
+				INNER JOIN replies ON threads.parent_id = replies.id
+			)
+			SELECT
+				COUNT(*) FILTER (WHERE created_at > subscription.last_read_at AND user_id <> $1) AS unread,
+				GREATEST(listing.created_at, MAX(created_at)) AS latest
+			FROM replies
+			WHERE hidden_at IS NULL AND deleted_at IS NULL
+		) AS activity
+		ORDER BY activity.latest DESC, listing.id DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	threads := []SubscribedThread{}
+	for rows.Next() {
+		var thread SubscribedThread
+		thread.Thread, err = scanThreadListRow(rows, &thread.LastReadAt, &thread.UnreadCount, &thread.LatestActivity)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, thread)
+	}
+	return threads, rows.Err()
+}
+
+// FetchThreadSubscribers lists the users following a top-level thread
+func (s *PostgresStore) FetchThreadSubscribers(threadID int) ([]int, error) {
+	return s.fetchUserIDs("SELECT user_id FROM thread_subscriptions WHERE thread_id = $1", threadID)
+}
+
+// SubscribeCategory follows the new threads of a category and its
+// subcategories. Returns false if the category does not exist.
+func (s *PostgresStore) SubscribeCategory(userID, categoryID int) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`
+		WITH subscribed AS (
+			INSERT INTO category_subscriptions (user_id, category_id)
+			SELECT $1, id FROM categories WHERE id = $2
+			ON CONFLICT (user_id, category_id) DO NOTHING
+		)
+		SELECT EXISTS (SELECT 1 FROM categories WHERE id = $2)
+	`, userID, categoryID).Scan(&exists)
+	return exists, err
+}
+
+// UnsubscribeCategory stops following a category. Returns false if the user
+// did not follow it.
+func (s *PostgresStore) UnsubscribeCategory(userID, categoryID int) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM category_subscriptions WHERE user_id = $1 AND category_id = $2", userID, categoryID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// FetchCategorySubscriptions lists the categories a user follows, in
+// display order
+func (s *PostgresStore) FetchCategorySubscriptions(userID int) ([]Category, error) {
+	rows, err := s.db.Query(`
+		SELECT c.id, c.name, c.slug, c.description, c.position, c.parent_id, c.posting
+		FROM categories c
+		INNER JOIN category_subscriptions ON category_subscriptions.category_id = c.id
+		WHERE category_subscriptions.user_id = $1
+		ORDER BY c.position, c.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		var category Category
+		if err := rows.Scan(
+			&category.ID,
+			&category.Name,
+			&category.Slug,
+			&category.Description,
+			&category.Position,
+			&category.ParentID,
+			&category.Posting,
+		); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+// FetchCategorySubscribers lists the users following a category or any
+// category above it
+func (s *PostgresStore) FetchCategorySubscribers(categoryID int) ([]int, error) {
+	return s.fetchUserIDs(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM categories WHERE id = $1
+			UNION ALL
+			SELECT categories.id, categories.parent_id
+			FROM categories
+			INNER JOIN ancestors ON categories.id = ancestors.parent_id
+		)
+		SELECT DISTINCT category_subscriptions.user_id
+		FROM category_subscriptions
+		INNER JOIN ancestors ON category_subscriptions.category_id = ancestors.id
+	`, categoryID)
+}
+
+// Runs a query returning a single column of user IDs
+func (s *PostgresStore) fetchUserIDs(query string, args ...interface{}) ([]int, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// FollowUser adds a user's new threads to the follower's feed
+func (s *PostgresStore) FollowUser(followerID, followeeID int) error {
+	_, err := s.db.Exec(`
+		INSERT INTO user_follows (follower_id, followee_id) VALUES ($1, $2)
+		ON CONFLICT (follower_id, followee_id) DO NOTHING
+	`, followerID, followeeID)
+	return err
+}
+
+// UnfollowUser stops following a user. Returns false if the follower did not
+// follow them.
+func (s *PostgresStore) UnfollowUser(followerID, followeeID int) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM user_follows WHERE follower_id = $1 AND followee_id = $2", followerID, followeeID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// FetchFollowedUsers lists the usernames a user follows, alphabetically
+func (s *PostgresStore) FetchFollowedUsers(userID int) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT users.username
+		FROM user_follows
+		INNER JOIN users ON users.id = user_follows.followee_id
+		WHERE user_follows.follower_id = $1
+		ORDER BY users.username
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usernames := []string{}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		usernames = append(usernames, username)
+	}
+	return usernames, rows.Err()
+}
+
+// SubscribeTag adds the threads under a tag to a user's feed. Returns false
+// if the tag does not exist.
+func (s *PostgresStore) SubscribeTag(userID, tagID int) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`
+		WITH subscribed AS (
+			INSERT INTO tag_subscriptions (user_id, tag_id)
+			SELECT $1, id FROM tags WHERE id = $2
+			ON CONFLICT (user_id, tag_id) DO NOTHING
+		)
+		SELECT EXISTS (SELECT 1 FROM tags WHERE id = $2)
+	`, userID, tagID).Scan(&exists)
+	return exists, err
+}
+
+// UnsubscribeTag stops following a tag. Returns false if the user did not
+// follow it.
+func (s *PostgresStore) UnsubscribeTag(userID, tagID int) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM tag_subscriptions WHERE user_id = $1 AND tag_id = $2", userID, tagID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// FetchTagSubscriptions lists the tags a user follows, alphabetically
+func (s *PostgresStore) FetchTagSubscriptions(userID int) ([]Classifier, error) {
+	rows, err := s.db.Query(`
+		SELECT tags.id, tags.name
+		FROM tags
+		INNER JOIN tag_subscriptions ON tag_subscriptions.tag_id = tags.id
+		WHERE tag_subscriptions.user_id = $1
+		ORDER BY tags.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Classifier{}
+	for rows.Next() {
+		var tag Classifier
+		if err := rows.Scan(&tag.ID, &tag.Name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Limits on the tags a thread carries
+const (
+	MaxTagsPerThread = 5
+	MaxTagLength     = 30
+)
+
+var (
+	ErrUnknownTag   = errors.New("only admins can create new tags")
+	ErrDuplicateTag = errors.New("a tag with this name already exists")
+)
+
+// CleanTags trims tag names and drops empty and repeated ones, keeping the
+// order they were given in
+func CleanTags(tags []string) []string {
+	cleaned := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		cleaned = append(cleaned, tag)
+	}
+	return cleaned
+}
+
+// Aggregates a thread's tag names in the order they were given; the
+// enclosing query must expose the thread as threads
+const threadTagsColumn = `COALESCE((
+			SELECT array_agg(tags.name ORDER BY thread_tags.position)
+			FROM thread_tags
+			INNER JOIN tags ON thread_tags.tag_id = tags.id
+			WHERE thread_tags.thread_id = threads.id
+		), '{}')`
+
+// Sets the tags scanned from threadTagsColumn, keeping the first one as the
+// single tag older clients read
+func (t *Thread) setTags(tags []string) {
+	t.Tags = tags
+	t.Tag = nil
+	if len(tags) > 0 {
+		t.Tag = &tags[0]
+	}
+}
+
+// Attaches tags to a new thread in order. Unknown tags are created when
+// createTags is set and rejected with ErrUnknownTag otherwise.
+func tagThread(tx *sql.Tx, threadID int, tags []string, createTags bool) error {
+	for position, name := range tags {
+		if createTags {
+			if _, err := tx.Exec("INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO NOTHING", name); err != nil {
+				return fmt.Errorf("error inserting new tag '%s': %v", name, err)
+			}
+		}
+
+		var tagID int
+		err := tx.QueryRow("SELECT id FROM tags WHERE name = $1", name).Scan(&tagID)
+		if err == sql.ErrNoRows {
+			return ErrUnknownTag
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(
+			"INSERT INTO thread_tags (thread_id, tag_id, position) VALUES ($1, $2, $3)",
+			threadID, tagID, position,
+		); err != nil {
+			return fmt.Errorf("error tagging thread: %v", err)
+		}
+	}
+	return nil
+}
+
+// Reports unique violations on tag names as ErrDuplicateTag
+func tagNameError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrDuplicateTag
+	}
+	return err
+}
+
+// CreateTag adds a tag that threads can then be given
+func (s *PostgresStore) CreateTag(name string) (int, error) {
+	var tagID int
+	err := s.db.QueryRow("INSERT INTO tags (name) VALUES ($1) RETURNING id", name).Scan(&tagID)
+	return tagID, tagNameError(err)
+}
+
+// RenameTag changes a tag's name on every thread carrying it. Returns false
+// if the tag does not exist.
+func (s *PostgresStore) RenameTag(tagID int, name string) (bool, error) {
+	result, err := s.db.Exec("UPDATE tags SET name = $2 WHERE id = $1", tagID, name)
+	if err != nil {
+		return false, tagNameError(err)
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// MergeTags moves every thread and subscriber from one tag to another, then
+// deletes the first. Threads that had both keep their place for the
+// remaining tag. Returns false if either tag does not exist.
+func (s *PostgresStore) MergeTags(fromID, intoID int) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var found int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM tags WHERE id IN ($1, $2)", fromID, intoID).Scan(&found); err != nil {
+		return false, err
+	}
+	if found < 2 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO thread_tags (thread_id, tag_id, position)
+		SELECT thread_id, $2, position FROM thread_tags WHERE tag_id = $1
+		ON CONFLICT (thread_id, tag_id) DO NOTHING
+	`, fromID, intoID); err != nil {
+		return false, fmt.Errorf("error moving threads between tags: %v", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO tag_subscriptions (user_id, tag_id, created_at)
+		SELECT user_id, $2, created_at FROM tag_subscriptions WHERE tag_id = $1
+		ON CONFLICT (user_id, tag_id) DO NOTHING
+	`, fromID, intoID); err != nil {
+		return false, fmt.Errorf("error moving subscribers between tags: %v", err)
+	}
+	// Removing the tag drops its thread_tags and subscription rows with it
+	if _, err := tx.Exec("DELETE FROM tags WHERE id = $1", fromID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// DeleteTag removes a tag from every thread and from the tag list. Returns
+// false if the tag does not exist.
+func (s *PostgresStore) DeleteTag(tagID int) (bool, error) {
+	result, err := s.db.Exec("DELETE FROM tags WHERE id = $1", tagID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
@@ -1,71 +1,119 @@
 package models
 
 import (
+	"backend/markdown"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+
+	"github.com/lib/pq"
 )
 
 type Thread struct {
-	ID            int     `json:"id"`
-	Title         *string `json:"title,omitempty"` // Nullable for comments
-	Content       string  `json:"content"`
-	Category      *string `json:"category,omitempty"` // Nullable category
-	Author        string  `json:"author"`
-	ParentAuthor  *string `json:"parentAuthor,omitempty"` // Nullable parent author
-	CreatedAt     string  `json:"createdAt"`
-	UserID        int     `json:"userId"`
-	ParentID      *int    `json:"parentId,omitempty"` // Nullable parent thread
-	LikesCount    int     `json:"likesCount"`
-	DislikesCount int     `json:"dislikesCount"`
-	CommentsCount int     `json:"commentsCount"`
-	Depth         int     `json:"depth"`
-	Tag           *string `json:"tag,omitempty"` // Nullable for comments
+	ID            int            `json:"id"`
+	Title         *string        `json:"title,omitempty"`       // Nullable for comments
+	Content       string         `json:"content"`               // Markdown source
+	ContentHTML   string         `json:"contentHtml,omitempty"` // Sanitized rendering, on detail views
+	Category      *string        `json:"category,omitempty"`    // Nullable category
+	Author        string         `json:"author"`
+	ParentAuthor  *string        `json:"parentAuthor,omitempty"` // Nullable parent author
+	CreatedAt     string         `json:"createdAt"`
+	UpdatedAt     *string        `json:"updatedAt,omitempty"` // Time of the last edit
+	Edited        bool           `json:"edited"`
+	UserID        int            `json:"userId"`
+	ParentID      *int           `json:"parentId,omitempty"` // Nullable parent thread
+	LikesCount    int            `json:"likesCount"`
+	DislikesCount int            `json:"dislikesCount"`
+	CommentsCount int            `json:"commentsCount"`
+	Score         int            `json:"score"` // Likes minus dislikes
+	Depth         int            `json:"depth"`
+	Tag           *string        `json:"tag,omitempty"`     // First of Tags, for older clients
+	Tags          []string       `json:"tags,omitempty"`    // In the order the author gave them; none on comments
+	Tokens        []ContentToken `json:"tokens,omitempty"`  // Content split into text and mentions
+	Hidden        bool           `json:"hidden,omitempty"`  // Hidden by a moderator
+	Deleted       bool           `json:"deleted,omitempty"` // Soft-deleted, shown as a tombstone
+	Pinned        string         `json:"pinned,omitempty"`  // global or category while pinned
+	Locked        bool           `json:"locked,omitempty"`  // Closed to replies and votes
+	Announcement  bool           `json:"announcement,omitempty"`
 }
 
-// Category struct for mapping database categories and tags
-type Classifier struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+// Shown in place of what hidden and deleted posts contain
+const (
+	HiddenPlaceholder  = "[hidden by a moderator]"
+	DeletedPlaceholder = "[deleted]"
+)
+
+// Redact replaces what a hidden or deleted post shows publicly. The stored
+// row keeps the original for moderators and restores.
+func (t *Thread) Redact() {
+	switch {
+	case t.Deleted:
+		placeholder := DeletedPlaceholder
+		if t.Title != nil {
+			t.Title = &placeholder
+		}
+		t.Content = DeletedPlaceholder
+		t.Author = DeletedPlaceholder
+		t.UserID = 0
+	case t.Hidden:
+		t.Content = HiddenPlaceholder
+	default:
+		return
+	}
+	t.ContentHTML = markdown.Render(t.Content)
 }
 
-// FetchThreads retrieves threads with filtering, sorting, and pagination
-func FetchThreads(db *sql.DB, searchQuery, sortBy string, limit, offset int, tag, category string) ([]Thread, error) {
-	validSortColumns := map[string]string{
-		"created_at": "threads.created_at",
-		"likes":      "likes_count",
-		"dislikes":   "dislikes_count",
-		"comments":   "comments_count",
+// Drops tombstones of deleted comments that have no visible replies left
+func pruneTombstones(comments []Thread) []Thread {
+	parents := map[int]*int{}
+	for _, comment := range comments {
+		parents[comment.ID] = comment.ParentID
+	}
+	keep := map[int]bool{}
+	for _, comment := range comments {
+		if comment.Deleted {
+			continue
+		}
+		for id := &comment.ID; id != nil && !keep[*id]; id = parents[*id] {
+			keep[*id] = true
+		}
 	}
 
-	// Validate `sortBy`
-	sortColumn, ok := validSortColumns[sortBy]
-	if !ok {
-		sortColumn = "threads.created_at"
+	kept := comments[:0]
+	for _, comment := range comments {
+		if keep[comment.ID] {
+			kept = append(kept, comment)
+		}
 	}
+	return kept
+}
 
-	// Add conditions for tag and category dynamically
-	var conditions []string
-	var params []interface{}
-	params = append(params, "%"+searchQuery+"%", "%"+searchQuery+"%")
-	paramIndex := 3 // Placeholder index starts from 3 as two params are already used
-	if tag != "" {
-		conditions = append(conditions, fmt.Sprintf("tags.name = $%d", paramIndex))
-		params = append(params, tag)
-		paramIndex++
-	}
-	if category != "" {
-		conditions = append(conditions, fmt.Sprintf("categories.name = $%d", paramIndex))
-		params = append(params, category)
-		paramIndex++
-	}
-	conditionsString := ""
-	if len(conditions) > 0 {
-		conditionsString = "AND " + strings.Join(conditions, " AND ")
+// Comments may be nested at most this many levels below a thread
+const MaxCommentDepth = 3
+
+var ErrMaxDepthReached = errors.New("maximum nesting depth reached")
+
+var ErrDuplicateTitle = errors.New("title must be unique")
+
+// Reports unique violations on thread titles as ErrDuplicateTitle
+func titleError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrDuplicateTitle
 	}
+	return err
+}
 
-	query := fmt.Sprintf(`
+// Category struct for mapping database categories and tags
+type Classifier struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Selects one row per top-level thread with its stored counters.
+// The single %s verb takes extra "AND ..." filter conditions.
+const threadListQuery = `
 		SELECT 
 			threads.id, 
 			COALESCE(threads.title, '') AS title, 
@@ -73,31 +121,55 @@ func FetchThreads(db *sql.DB, searchQuery, sortBy string, limit, offset int, tag
 			users.username AS author, 
 			threads.created_at,
 			threads.user_id,
-			COUNT(DISTINCT likes.id) AS likes_count,
-			COUNT(DISTINCT dislikes.id) AS dislikes_count,
-			COUNT(DISTINCT comments.id) AS comments_count,
+			threads.likes_count,
+			threads.dislikes_count,
+			threads.comments_count,
+			threads.score,
 			threads.depth,
 			categories.name AS category,
-			COALESCE(tags.name, '') AS tag
+			` + threadTagsColumn + ` AS tags,
+			threads.updated_at,
+			COALESCE(threads.pin_scope, '') AS pinned,
+			threads.locked_at IS NOT NULL AS locked,
+			threads.announcement
 		FROM threads
 		INNER JOIN users ON threads.user_id = users.id
-		LEFT JOIN likes ON threads.id = likes.thread_id
-		LEFT JOIN dislikes ON threads.id = dislikes.thread_id
-		LEFT JOIN threads AS comments ON threads.id = comments.parent_id
 		INNER JOIN categories ON threads.category_id = categories.id
-		INNER JOIN tags ON threads.tag_id = tags.id
 		WHERE threads.title IS NOT NULL
-		AND (threads.title LIKE $1 OR threads.content LIKE $2)
 		%s
-		GROUP BY threads.id, threads.title, threads.content, threads.created_at, threads.user_id, users.username, categories.name, tags.name
-		ORDER BY %s DESC
+`
+
+// FetchThreads retrieves threads with filtering, ranking, and pagination
+func (s *PostgresStore) FetchThreads(listing ThreadListing, limit, offset int) ([]Thread, error) {
+	listing, err := listing.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	// Shared with GetThreadCount so results and page counts always agree
+	conditions, params := threadFilters(listing)
+	paramIndex := len(params) + 1
+
+	sortColumn := rankings["created_at"].expr
+	if rank, ok := rankings[listing.SortBy]; ok {
+		sortColumn = rank.expr
+	}
+	// Relevance only makes sense when there is something to rank against
+	if listing.SortBy == "relevance" && BuildTSQuery(listing.Query) != "" {
+		sortColumn = "ts_rank(threads.search_vector, to_tsquery('english', $1))"
+	}
+
+	// Pinned threads come first whatever the sort, most recently pinned on top
+	direction := listing.direction()
+	query := fmt.Sprintf(threadListQuery, joinConditions(conditions)) + fmt.Sprintf(`
+		ORDER BY %s DESC, threads.pinned_at DESC NULLS LAST, %s %s, threads.id %s
 		LIMIT $%d OFFSET $%d
-	`, conditionsString, sortColumn, paramIndex, paramIndex+1)
+	`, pinnedFirst(listing), sortColumn, direction, direction, paramIndex, paramIndex+1)
 
 	// Add pagination params
 	params = append(params, limit, offset)
 
-	rows, err := db.Query(query, params...)
+	rows, err := s.db.Query(query, params...)
 	if err != nil {
 		return nil, err
 	}
@@ -105,31 +177,163 @@ func FetchThreads(db *sql.DB, searchQuery, sortBy string, limit, offset int, tag
 
 	var threads []Thread
 	for rows.Next() {
-		var thread Thread
-		if err := rows.Scan(
-			&thread.ID,
-			&thread.Title,
-			&thread.Content,
-			&thread.Author,
-			&thread.CreatedAt,
-			&thread.UserID,
-			&thread.LikesCount,
-			&thread.DislikesCount,
-			&thread.CommentsCount,
-			&thread.Depth,
-			&thread.Category,
-			&thread.Tag,
-		); err != nil {
+		thread, err := scanThreadListRow(rows)
+		if err != nil {
 			return nil, err
 		}
 		threads = append(threads, thread)
 	}
-	return threads, nil
+	return threads, rows.Err()
+}
+
+// FetchThreadsByCursor retrieves one page of threads using keyset pagination.
+// A nil cursor starts from the first page. The returned cursors point at the
+// adjacent pages and are empty when there is no such page. Pinned threads are
+// left out; FetchPinnedThreads lists them for the first page.
+func (s *PostgresStore) FetchThreadsByCursor(listing ThreadListing, limit int, cursor *ThreadCursor) ([]Thread, string, string, error) {
+	listing, err := listing.normalize()
+	if err != nil {
+		return nil, "", "", err
+	}
+	keyColumn, ok := cursorSortColumns[listing.SortBy]
+	if !ok {
+		return nil, "", "", ErrCursorUnsupportedSort
+	}
+	if cursor != nil && !cursor.matches(listing) {
+		return nil, "", "", ErrCursorMismatch
+	}
+
+	// Pinned threads stay out of the keyset ordering
+	conditions, params := threadFilters(listing)
+	conditions = append(conditions, "NOT "+pinnedFirst(listing))
+
+	// Walk forwards in the listing's order by default, or backwards from a prev cursor
+	backward := cursor != nil && cursor.Backward
+	comparison, direction := "<", "DESC"
+	if backward != (listing.Order == "asc") {
+		comparison, direction = ">", "ASC"
+	}
+
+	keyset := ""
+	if cursor != nil {
+		keyset = fmt.Sprintf("WHERE (listing.%s, listing.id) %s ($%d, $%d)", keyColumn, comparison, len(params)+1, len(params)+2)
+		params = append(params, cursor.Key, cursor.ID)
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	query := fmt.Sprintf(`
+		SELECT * FROM (%s) AS listing
+		%s
+		ORDER BY listing.%s %s, listing.id %s
+		LIMIT $%d
+	`, fmt.Sprintf(threadListQuery, joinConditions(conditions)), keyset, keyColumn, direction, direction, len(params)+1)
+	params = append(params, limit+1)
+
+	rows, err := s.db.Query(query, params...)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer rows.Close()
+
+	threads := []Thread{}
+	for rows.Next() {
+		thread, err := scanThreadListRow(rows)
+		if err != nil {
+			return nil, "", "", err
+		}
+		threads = append(threads, thread)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", "", err
+	}
+
+	hasMore := len(threads) > limit
+	if hasMore {
+		threads = threads[:limit]
+	}
+	if backward {
+		for i, j := 0, len(threads)-1; i < j; i, j = i+1, j-1 {
+			threads[i], threads[j] = threads[j], threads[i]
+		}
+	}
+	next, prev := pageCursors(listing, threads, cursor, hasMore)
+	return threads, next, prev, nil
+}
+
+// FetchPinnedThreads lists the pinned threads leading a listing, most
+// recently pinned first
+func (s *PostgresStore) FetchPinnedThreads(listing ThreadListing) ([]Thread, error) {
+	listing, err := listing.normalize()
+	if err != nil {
+		return nil, err
+	}
+	conditions, params := threadFilters(listing)
+	conditions = append(conditions, pinnedFirst(listing))
+	rows, err := s.db.Query(
+		fmt.Sprintf(threadListQuery, joinConditions(conditions))+"ORDER BY threads.pinned_at DESC, threads.id DESC",
+		params...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pinned := []Thread{}
+	for rows.Next() {
+		thread, err := scanThreadListRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		pinned = append(pinned, thread)
+	}
+	return pinned, rows.Err()
+}
+
+// Destinations for the columns of threadListQuery, in select order
+func threadListColumns(thread *Thread, tags *[]string) []interface{} {
+	return []interface{}{
+		&thread.ID,
+		&thread.Title,
+		&thread.Content,
+		&thread.Author,
+		&thread.CreatedAt,
+		&thread.UserID,
+		&thread.LikesCount,
+		&thread.DislikesCount,
+		&thread.CommentsCount,
+		&thread.Score,
+		&thread.Depth,
+		&thread.Category,
+		pq.Array(tags),
+		&thread.UpdatedAt,
+		&thread.Pinned,
+		&thread.Locked,
+		&thread.Announcement,
+	}
+}
+
+// Scans a row produced by threadListQuery, followed by any extra columns
+func scanThreadListRow(rows *sql.Rows, extra ...interface{}) (Thread, error) {
+	var thread Thread
+	var tags []string
+	err := rows.Scan(append(threadListColumns(&thread, &tags), extra...)...)
+	thread.setTags(tags)
+	thread.Edited = thread.UpdatedAt != nil
+	return thread, err
+}
+
+// Prefixes filter conditions for use after an existing WHERE clause
+func joinConditions(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "AND " + strings.Join(conditions, " AND ")
 }
 
 // FetchThreadByID retrieves a thread by its ID
-func FetchThreadByID(db *sql.DB, threadID int) (*Thread, error) {
+func (s *PostgresStore) FetchThreadByID(threadID int) (*Thread, error) {
 	var thread Thread
+	var tags []string
 
 	query := `
 		SELECT 
@@ -140,24 +344,26 @@ func FetchThreadByID(db *sql.DB, threadID int) (*Thread, error) {
 			threads.created_at, 
 			threads.user_id, 
 			threads.parent_id, 
-			COUNT(DISTINCT likes.id) AS likes_count,
-			COUNT(DISTINCT dislikes.id) AS dislikes_count,
-			COUNT(DISTINCT comments.id) AS comments_count,
+			threads.likes_count,
+			threads.dislikes_count,
+			threads.comments_count,
+			threads.score,
 			threads.depth,
 			categories.name AS category,
-			tags.name AS tag
+			` + threadTagsColumn + ` AS tags,
+			threads.hidden_at IS NOT NULL,
+			threads.deleted_at IS NOT NULL,
+			threads.updated_at,
+			COALESCE(threads.pin_scope, ''),
+			threads.locked_at IS NOT NULL,
+			threads.announcement
 		FROM threads
 		INNER JOIN users ON threads.user_id = users.id
-		LEFT JOIN likes ON threads.id = likes.thread_id
-		LEFT JOIN dislikes ON threads.id = dislikes.thread_id
-		LEFT JOIN threads AS comments ON threads.id = comments.parent_id
 		LEFT JOIN categories ON threads.category_id = categories.id
-		LEFT JOIN tags ON threads.tag_id = tags.id
 		WHERE threads.id = $1
-		GROUP BY threads.id, users.username, categories.name, tags.name
 	`
 
-	err := db.QueryRow(query, threadID).Scan(
+	err := s.db.QueryRow(query, threadID).Scan(
 		&thread.ID,
 		&thread.Title,
 		&thread.Content,
@@ -168,9 +374,16 @@ func FetchThreadByID(db *sql.DB, threadID int) (*Thread, error) {
 		&thread.LikesCount,
 		&thread.DislikesCount,
 		&thread.CommentsCount,
+		&thread.Score,
 		&thread.Depth,
 		&thread.Category,
-		&thread.Tag,
+		pq.Array(&tags),
+		&thread.Hidden,
+		&thread.Deleted,
+		&thread.UpdatedAt,
+		&thread.Pinned,
+		&thread.Locked,
+		&thread.Announcement,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -178,16 +391,19 @@ func FetchThreadByID(db *sql.DB, threadID int) (*Thread, error) {
 		}
 		return nil, err
 	}
+	thread.setTags(tags)
+	thread.Edited = thread.UpdatedAt != nil
+	thread.ContentHTML = markdown.Render(thread.Content)
 
 	return &thread, nil
 }
 
 // FetchCommentsByThreadID retrieves all comments for a specific thread, with sorting
-func FetchCommentsByThreadID(db *sql.DB, threadID int, searchQuery, sortBy string) ([]Thread, error) {
+func (s *PostgresStore) FetchCommentsByThreadID(threadID int, searchQuery, sortBy string) ([]Thread, error) {
 	validSortColumns := map[string]string{
 		"created_at": "ct.created_at",
-		"likes":      "likes_count",
-		"dislikes":   "dislikes_count",
+		"likes":      "ct.likes_count",
+		"dislikes":   "ct.dislikes_count",
 	}
 
 	// Validate and sanitize `sortBy`
@@ -201,17 +417,14 @@ func FetchCommentsByThreadID(db *sql.DB, threadID int, searchQuery, sortBy strin
 		WITH RECURSIVE CommentTree AS (
 			SELECT 
 				t.*, 
-				categories.name AS category,
-				tags.name AS tag
+				categories.name AS category
 			FROM threads t
 			LEFT JOIN categories ON t.category_id = categories.id
-			LEFT JOIN tags ON t.tag_id = tags.id
 			WHERE t.parent_id = $1
 			UNION ALL
 			SELECT 
 				t.*, 
-				COALESCE(ct.category, NULL) AS category,
-				COALESCE(ct.tag, NULL) AS tag
+				COALESCE(ct.category, NULL) AS category
 			FROM threads t
 			INNER JOIN CommentTree ct ON t.parent_id = ct.id
 		)
@@ -223,20 +436,27 @@ func FetchCommentsByThreadID(db *sql.DB, threadID int, searchQuery, sortBy strin
 			ct.created_at, 
 			ct.user_id, 
 			ct.parent_id, 
-			(SELECT COUNT(*) FROM likes WHERE likes.thread_id = ct.id) AS likes_count,
-			(SELECT COUNT(*) FROM dislikes WHERE dislikes.thread_id = ct.id) AS dislikes_count,
-			(SELECT COUNT(*) FROM threads WHERE threads.parent_id = ct.id) AS comments_count,
+			ct.likes_count,
+			ct.dislikes_count,
+			ct.comments_count,
+			ct.score,
 			ct.depth,
 			ct.category,
-			ct.tag
+			ct.hidden_at IS NOT NULL,
+			ct.deleted_at IS NOT NULL,
+			ct.updated_at
 		FROM CommentTree ct
 		LEFT JOIN users ON ct.user_id = users.id
-		WHERE ct.id != $1 AND (ct.content ILIKE $2 OR users.username ILIKE $3)
+		WHERE ct.id != $1 AND (
+			ct.deleted_at IS NOT NULL -- Tombstones, pruned below unless replies match
+			OR (ct.hidden_at IS NULL AND ct.content ILIKE $2)
+			OR users.username ILIKE $3
+		)
 		ORDER BY %s DESC
 	`, sortColumn)
 
 	// Execute the query
-	rows, err := db.Query(query, threadID, "%"+searchQuery+"%", "%"+searchQuery+"%")
+	rows, err := s.db.Query(query, threadID, "%"+searchQuery+"%", "%"+searchQuery+"%")
 	if err != nil {
 		return nil, err
 	}
@@ -259,9 +479,12 @@ func FetchCommentsByThreadID(db *sql.DB, threadID int, searchQuery, sortBy strin
 			&comment.LikesCount,
 			&comment.DislikesCount,
 			&comment.CommentsCount,
+			&comment.Score,
 			&comment.Depth,
 			&comment.Category,
-			&comment.Tag,
+			&comment.Hidden,
+			&comment.Deleted,
+			&comment.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -278,38 +501,20 @@ func FetchCommentsByThreadID(db *sql.DB, threadID int, searchQuery, sortBy strin
 		} else {
 			comment.ParentID = nil
 		}
+		comment.Edited = comment.UpdatedAt != nil
+		comment.Redact()
+		comment.ContentHTML = markdown.Render(comment.Content)
 
 		comments = append(comments, comment)
 	}
 
-	return comments, nil
-}
-
-// FetchCategories retrieves all categories from the database
-func FetchCategories(db *sql.DB) ([]Classifier, error) {
-	query := "SELECT id, name FROM categories ORDER BY id ASC"
-	rows, err := db.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var categories []Classifier
-	for rows.Next() {
-		var category Classifier
-		if err := rows.Scan(&category.ID, &category.Name); err != nil {
-			return nil, err
-		}
-		categories = append(categories, category)
-	}
-
-	return categories, nil
+	return pruneTombstones(comments), rows.Err()
 }
 
 // FetchTags retrieves all tags from the database
-func FetchTags(db *sql.DB) ([]Classifier, error) {
+func (s *PostgresStore) FetchTags() ([]Classifier, error) {
 	// Query to fetch all tags
-	rows, err := db.Query("SELECT id, name FROM tags")
+	rows, err := s.db.Query("SELECT id, name FROM tags")
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch tags: %v", err)
 	}
@@ -333,131 +538,282 @@ func FetchTags(db *sql.DB) ([]Classifier, error) {
 	return tags, nil
 }
 
-// CreateThread creates a new thread in the database
-func CreateThread(db *sql.DB, title *string, content *string, userID int, category, tag string) error {
-	// Resolve or insert the category
-	var categoryID int
-	err := db.QueryRow(`
-        SELECT id FROM categories WHERE name = $1
-    `, category).Scan(&categoryID)
+// TitleExists reports whether a thread already uses the given title
+func (s *PostgresStore) TitleExists(title string) (bool, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM threads WHERE title = $1", title).Scan(&count)
+	return count > 0, err
+}
+
+// GetThreadDepth retrieves the nesting depth of a thread or comment
+func (s *PostgresStore) GetThreadDepth(threadID int) (int, error) {
+	var depth int
+	err := s.db.QueryRow("SELECT depth FROM threads WHERE id = $1", threadID).Scan(&depth)
+	return depth, err
+}
+
+// FetchRootThreadID finds the top-level thread a comment belongs to. A thread
+// is its own root.
+func (s *PostgresStore) FetchRootThreadID(threadID int) (int, error) {
+	var rootID int
+	err := s.db.QueryRow(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM threads WHERE id = $1
+			UNION ALL
+			SELECT threads.id, threads.parent_id
+			FROM threads
+			INNER JOIN ancestors ON threads.id = ancestors.parent_id
+		)
+		SELECT id FROM ancestors WHERE parent_id IS NULL
+	`, threadID).Scan(&rootID)
+	return rootID, err
+}
+
+// CreateThread creates a new thread in the database and returns its ID.
+// Tags missing from the tag list are created only when createTags is set.
+func (s *PostgresStore) CreateThread(title *string, content *string, userID int, categoryID int, tags []string, createTags bool) (int, error) {
+	// Insert the thread together with its tags
+	tx, err := s.db.Begin()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			err = db.QueryRow(`
-                INSERT INTO categories (name) VALUES ($1) RETURNING id
-            `, category).Scan(&categoryID)
-			if err != nil {
-				return fmt.Errorf("error inserting new category '%s': %v", category, err)
-			}
-		} else {
-			return err
-		}
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var threadID int
+	err = tx.QueryRow(`
+        INSERT INTO threads (title, content, user_id, category_id, created_at)
+        VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+        RETURNING id
+    `, title, content, userID, categoryID).Scan(&threadID)
+	if err := titleError(err); err == ErrDuplicateTitle {
+		return 0, err
+	} else if err != nil {
+		return 0, fmt.Errorf("error inserting thread: %v", err)
+	}
+	if err := tagThread(tx, threadID, tags, createTags); err != nil {
+		return 0, err
 	}
 
-	// Resolve or insert the tag
-	var tagID int
-	err = db.QueryRow(`
-        SELECT id FROM tags WHERE name = $1
-    `, tag).Scan(&tagID)
+	return threadID, tx.Commit()
+}
+
+// UpdateThread changes a thread's title and/or content, keeping what it said
+// before as a revision. Nil fields are left as they are, and an edit that
+// changes nothing records no revision. Returns ErrDuplicateTitle if another
+// thread has the new title.
+func (s *PostgresStore) UpdateThread(threadID, editorID int, title, content *string) error {
+	tx, err := s.db.Begin()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			err = db.QueryRow(`
-                INSERT INTO tags (name) VALUES ($1) RETURNING id
-            `, tag).Scan(&tagID)
-			if err != nil {
-				return fmt.Errorf("error inserting new tag '%s': %v", tag, err)
-			}
-		} else {
-			return err
-		}
+		return err
 	}
+	defer tx.Rollback()
 
-	// Insert the thread
-	_, err = db.Exec(`
-        INSERT INTO threads (title, content, user_id, category_id, tag_id, created_at)
-        VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
-    `, title, content, userID, categoryID, tagID)
-	if err != nil {
-		return fmt.Errorf("error inserting thread: %v", err)
+	if err := reviseThread(tx, threadID, editorID, title, content); err != nil {
+		return err
 	}
+	return tx.Commit()
+}
 
-	return nil
+// Records the current title and content as a revision, then applies the edit
+func reviseThread(tx *sql.Tx, threadID, editorID int, title, content *string) error {
+	result, err := tx.Exec(`
+		INSERT INTO thread_revisions (thread_id, editor_id, title, content)
+		SELECT id, $2, title, content FROM threads
+		WHERE id = $1 AND (
+			title IS DISTINCT FROM COALESCE($3, title)
+			OR content IS DISTINCT FROM COALESCE($4, content)
+		)
+	`, threadID, editorID, title, content)
+	if err != nil {
+		return fmt.Errorf("error saving revision: %v", err)
+	}
+	if revised, err := result.RowsAffected(); err != nil || revised == 0 {
+		return err
+	}
 
+	_, err = tx.Exec(`
+		UPDATE threads
+		SET title = COALESCE($2, title), content = COALESCE($3, content), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, threadID, title, content)
+	return titleError(err)
 }
 
-// UpdateThread updates a thread's title or content
-func UpdateThread(db *sql.DB, threadID int, title, content *string) error {
-	query := "UPDATE threads SET"
-	params := []interface{}{}
+// DeleteThread deletes a thread by ID and keeps its parent's reply count in step
+func (s *PostgresStore) DeleteThread(threadID, deletedBy int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var parentID sql.NullInt64
+	err = tx.QueryRow(`
+		UPDATE threads SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $2
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING parent_id
+	`, threadID, deletedBy).Scan(&parentID)
+	if err == sql.ErrNoRows {
+		return ErrThreadNotFound
+	} else if err != nil {
+		return err
+	}
 
-	if title != nil {
-		query += " title = $1,"
-		params = append(params, *title)
+	if parentID.Valid {
+		if err := adjustCounter(tx, int(parentID.Int64), "comments_count", -1); err != nil {
+			return err
+		}
 	}
 
-	if content != nil {
-		query += " content = $2,"
-		params = append(params, *content)
+	return tx.Commit()
+}
+
+// RestoreThread undoes a soft delete. Returns false if the post is not deleted.
+func (s *PostgresStore) RestoreThread(threadID int) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var parentID sql.NullInt64
+	err = tx.QueryRow(`
+		UPDATE threads SET deleted_at = NULL, deleted_by = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING parent_id
+	`, threadID).Scan(&parentID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
 	}
 
-	query = strings.TrimSuffix(query, ",") + " WHERE id = $3"
-	params = append(params, threadID)
+	if parentID.Valid {
+		if err := adjustCounter(tx, int(parentID.Int64), "comments_count", 1); err != nil {
+			return false, err
+		}
+	}
 
-	_, err := db.Exec(query, params...)
-	return err
+	return true, tx.Commit()
 }
 
-// DeleteThread deletes a thread by ID
-func DeleteThread(db *sql.DB, threadID int) error {
-	_, err := db.Exec("DELETE FROM threads WHERE id = $1", threadID)
-	return err
+// PurgeThread permanently removes a post. Replies go with it through
+// ON DELETE CASCADE. Returns false if there is no such post.
+func (s *PostgresStore) PurgeThread(threadID int) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var parentID sql.NullInt64
+	var counted bool
+	err = tx.QueryRow("DELETE FROM threads WHERE id = $1 RETURNING parent_id, deleted_at IS NULL", threadID).Scan(&parentID, &counted)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	// Soft-deleted replies were already taken off the parent's count
+	if parentID.Valid && counted {
+		if err := adjustCounter(tx, int(parentID.Int64), "comments_count", -1); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
 }
 
-// CreateComment adds a new comment to a thread
-func CreateComment(db *sql.DB, content string, userID int, parentID int, depth int) error {
+// CreateComment adds a new comment to a thread and returns its ID
+func (s *PostgresStore) CreateComment(content string, userID int, parentID int, depth int) (int, error) {
 	// Ensure the depth does not exceed the limit
-	if depth > 3 {
-		return fmt.Errorf("maximum nesting depth reached")
+	if depth > MaxCommentDepth {
+		return 0, ErrMaxDepthReached
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
 	}
+	defer tx.Rollback()
 
 	// Insert the comment
-	_, err := db.Exec(`
+	var commentID int
+	err = tx.QueryRow(`
 		INSERT INTO threads (content, user_id, parent_id, depth, created_at)
 		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
-	`, content, userID, parentID, depth)
+		RETURNING id
+	`, content, userID, parentID, depth).Scan(&commentID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := adjustCounter(tx, parentID, "comments_count", 1); err != nil {
+		return 0, err
+	}
+
+	return commentID, tx.Commit()
+}
+
+// RecountThreadCounters repairs stored counters that drifted from the
+// underlying rows and returns how many threads were corrected
+func (s *PostgresStore) RecountThreadCounters() (int64, error) {
+	result, err := s.db.Exec(`
+		UPDATE threads SET
+			likes_count = actual.likes_count,
+			dislikes_count = actual.dislikes_count,
+			comments_count = actual.comments_count
+		FROM (
+			SELECT
+				threads.id,
+				(SELECT COUNT(*) FROM votes WHERE votes.thread_id = threads.id AND value = 1) AS likes_count,
+				(SELECT COUNT(*) FROM votes WHERE votes.thread_id = threads.id AND value = -1) AS dislikes_count,
+				(SELECT COUNT(*) FROM threads AS comments WHERE comments.parent_id = threads.id AND comments.deleted_at IS NULL) AS comments_count
+			FROM threads
+		) AS actual
+		WHERE threads.id = actual.id
+		AND (
+			threads.likes_count <> actual.likes_count
+			OR threads.dislikes_count <> actual.dislikes_count
+			OR threads.comments_count <> actual.comments_count
+		)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Shifts one of a thread's stored counters; column must be a trusted name
+func adjustCounter(tx *sql.Tx, threadID int, column string, delta int) error {
+	_, err := tx.Exec(
+		fmt.Sprintf("UPDATE threads SET %s = %s + $1 WHERE id = $2", column, column),
+		delta, threadID,
+	)
 	return err
 }
 
 // GetThreadCount retrieves the count of threads based on search criteria
-func GetThreadCount(db *sql.DB, searchQuery, tag, category string) int {
+func (s *PostgresStore) GetThreadCount(listing ThreadListing) int {
 	query := `
 		SELECT COUNT(*) 
 		FROM threads 
 		INNER JOIN categories ON threads.category_id = categories.id
-		INNER JOIN tags ON threads.tag_id = tags.id
-		WHERE threads.title IS NOT NULL 
-		AND (threads.title ILIKE $1 OR threads.content ILIKE $2)
+		WHERE threads.title IS NOT NULL
 	`
 
-	var conditions []string
-	var params []interface{}
-	params = append(params, "%"+searchQuery+"%", "%"+searchQuery+"%")
-	paramIndex := 3 // Start after the first two params
-	if tag != "" {
-		conditions = append(conditions, fmt.Sprintf("tags.name = $%d", paramIndex))
-		params = append(params, tag)
-		paramIndex++
-	}
-	if category != "" {
-		conditions = append(conditions, fmt.Sprintf("categories.name = $%d", paramIndex))
-		params = append(params, category)
+	listing, err := listing.normalize()
+	if err != nil {
+		return 0
 	}
-
+	conditions, params := threadFilters(listing)
 	if len(conditions) > 0 {
 		query += " AND " + strings.Join(conditions, " AND ")
 	}
 
 	var count int
-	err := db.QueryRow(query, params...).Scan(&count)
+	err = s.db.QueryRow(query, params...).Scan(&count)
 	if err != nil {
 		log.Printf("Error counting threads: %v", err)
 		return 0
@@ -465,3 +821,44 @@ func GetThreadCount(db *sql.DB, searchQuery, tag, category string) int {
 
 	return count
 }
+
+// Builds the WHERE conditions shared by thread listings and counts.
+// A full-text query, when present, is always parameter $1.
+func threadFilters(listing ThreadListing) ([]string, []interface{}) {
+	conditions := []string{"threads.hidden_at IS NULL", "threads.deleted_at IS NULL"}
+	var params []interface{}
+
+	if tsQuery := BuildTSQuery(listing.Query); tsQuery != "" {
+		params = append(params, tsQuery)
+		conditions = append(conditions, fmt.Sprintf("threads.search_vector @@ to_tsquery('english', $%d)", len(params)))
+	}
+	if len(listing.Tags) > 0 {
+		params = append(params, pq.Array(listing.Tags))
+		tagged := fmt.Sprintf(`
+			FROM thread_tags
+			INNER JOIN tags ON thread_tags.tag_id = tags.id
+			WHERE thread_tags.thread_id = threads.id AND tags.name = ANY($%d)`, len(params))
+		if listing.TagMatch == "all" {
+			conditions = append(conditions, fmt.Sprintf("(SELECT COUNT(*) %s) = %d", tagged, len(listing.Tags)))
+		} else {
+			conditions = append(conditions, "EXISTS (SELECT 1 "+tagged+")")
+		}
+	}
+	if listing.Category != "" {
+		// A category lists the threads of its subcategories too
+		params = append(params, listing.Category)
+		conditions = append(conditions, fmt.Sprintf(`threads.category_id IN (
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM categories WHERE name = $%d OR slug = $%[1]d
+				UNION ALL
+				SELECT categories.id FROM categories INNER JOIN subtree ON categories.parent_id = subtree.id
+			)
+			SELECT id FROM subtree
+		)`, len(params)))
+	}
+	if window, ok := rankingWindows[listing.Window]; ok {
+		conditions = append(conditions, fmt.Sprintf("threads.created_at >= LOCALTIMESTAMP - INTERVAL '%s'", window.interval))
+	}
+
+	return conditions, params
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Where a pinned thread stays on top
+const (
+	PinGlobal   = "global"   // Every listing
+	PinCategory = "category" // Listings of its category
+)
+
+var ErrThreadLocked = errors.New("this thread is locked and no longer accepts replies or votes")
+
+// Reports whether a pin of the given scope puts a thread ahead of the rest
+// of a listing; keep in step with pinnedFirst
+func pinLeads(scope string, listing ThreadListing) bool {
+	return scope == PinGlobal || (scope == PinCategory && listing.Category != "")
+}
+
+// SQL condition for threads that lead a listing because they are pinned
+func pinnedFirst(listing ThreadListing) string {
+	if listing.Category != "" {
+		return "threads.pinned_at IS NOT NULL"
+	}
+	return "COALESCE(threads.pin_scope = 'global', FALSE)"
+}
+
+// Applies an admin control to a visible top-level thread. Returns false if
+// there is no such thread.
+func (s *PostgresStore) setThreadStatus(threadID int, assignments string, args ...interface{}) (bool, error) {
+	result, err := s.db.Exec(
+		fmt.Sprintf("UPDATE threads SET %s WHERE id = $1 AND parent_id IS NULL AND deleted_at IS NULL", assignments),
+		append([]interface{}{threadID}, args...)...,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// PinThread keeps a thread at the top of listings, everywhere or within its
+// category. An empty scope unpins it.
+func (s *PostgresStore) PinThread(threadID int, scope string) (bool, error) {
+	if scope == "" {
+		return s.setThreadStatus(threadID, "pinned_at = NULL, pin_scope = NULL")
+	}
+	return s.setThreadStatus(threadID, "pinned_at = CURRENT_TIMESTAMP, pin_scope = $2", scope)
+}
+
+// LockThread closes a thread to new replies and votes, or reopens it
+func (s *PostgresStore) LockThread(threadID int, locked bool) (bool, error) {
+	if !locked {
+		return s.setThreadStatus(threadID, "locked_at = NULL")
+	}
+	return s.setThreadStatus(threadID, "locked_at = COALESCE(locked_at, CURRENT_TIMESTAMP)")
+}
+
+// SetAnnouncement marks a thread as an announcement, or clears the mark
+func (s *PostgresStore) SetAnnouncement(threadID int, announcement bool) (bool, error) {
+	return s.setThreadStatus(threadID, "announcement = $2", announcement)
+}
+
+// IsThreadLocked reports whether the thread a post belongs to is locked.
+// Missing posts are not locked.
+func (s *PostgresStore) IsThreadLocked(threadID int) (bool, error) {
+	var locked bool
+	err := s.db.QueryRow(`
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, locked_at FROM threads WHERE id = $1
+			UNION ALL
+			SELECT threads.id, threads.parent_id, threads.locked_at
+			FROM threads
+			INNER JOIN ancestors ON threads.id = ancestors.parent_id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE parent_id IS NULL AND locked_at IS NOT NULL)
+	`, threadID).Scan(&locked)
+	return locked, err
+}
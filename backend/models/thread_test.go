@@ -0,0 +1,37 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+// Counts the columns a query selects, skipping commas nested in parentheses
+func selectedColumns(query string) int {
+	upper := strings.ToUpper(query)
+	list := query[strings.Index(upper, "SELECT")+len("SELECT") : strings.Index(upper, "\n\t\tFROM THREADS")]
+	count, depth := 1, 0
+	for _, r := range list {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// The listing is only exercised against the memory store elsewhere, so guard
+// the Postgres scan against drifting from the query it reads
+func TestThreadListScanMatchesQuery(t *testing.T) {
+	var thread Thread
+	var tags []string
+	columns, destinations := selectedColumns(threadListQuery), len(threadListColumns(&thread, &tags))
+	if columns != destinations {
+		t.Fatalf("threadListQuery selects %d columns but scanThreadListRow scans %d", columns, destinations)
+	}
+}
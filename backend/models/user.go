@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"time"
 )
 
 type UserInfo struct {
@@ -47,10 +48,36 @@ type SavedThread struct {
 	CreatedAt string `json:"createdAt"`
 }
 
+// Principal is the verified identity behind an authenticated request
+type Principal struct {
+	UserID    int       `json:"userId"`
+	Username  string    `json:"username"`
+	IsAdmin   bool      `json:"isAdmin"`
+	CreatedAt time.Time `json:"createdAt"` // When the account was registered
+}
+
+// Fetch the principal for a verified username in a single lookup
+func (s *PostgresStore) FetchPrincipal(username string) (*Principal, error) {
+	var principal Principal
+	err := s.db.QueryRow(
+		"SELECT id, username, is_admin, COALESCE(created_at, 'epoch') FROM users WHERE username = $1",
+		username,
+	).Scan(
+		&principal.UserID,
+		&principal.Username,
+		&principal.IsAdmin,
+		&principal.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &principal, nil
+}
+
 // Check if a user is an admin
-func IsAdmin(db *sql.DB, username string) (bool, error) {
+func (s *PostgresStore) IsAdmin(username string) (bool, error) {
 	var isAdmin bool
-	err := db.QueryRow("SELECT is_admin FROM users WHERE username = $1", username).Scan(&isAdmin)
+	err := s.db.QueryRow("SELECT is_admin FROM users WHERE username = $1", username).Scan(&isAdmin)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil // User does not exist
@@ -61,10 +88,10 @@ func IsAdmin(db *sql.DB, username string) (bool, error) {
 }
 
 // Check if the user is the owner of the thread or an admin
-func CheckThreadOwnershipOrAdmin(db *sql.DB, username string, userID int, threadID int) bool {
+func (s *PostgresStore) CheckThreadOwnershipOrAdmin(username string, userID int, threadID int) bool {
 	// Check if the thread exists and get the user_id (thread creator)
 	var threadCreatorID int
-	err := db.QueryRow("SELECT user_id FROM threads WHERE id = $1", threadID).Scan(&threadCreatorID)
+	err := s.db.QueryRow("SELECT user_id FROM threads WHERE id = $1", threadID).Scan(&threadCreatorID)
 	if err != nil {
 		return false // If thread does not exist or there is an error, deny access
 	}
@@ -75,7 +102,7 @@ func CheckThreadOwnershipOrAdmin(db *sql.DB, username string, userID int, thread
 	}
 
 	// Check if the user is an admin
-	isAdmin, err := IsAdmin(db, username)
+	isAdmin, err := s.IsAdmin(username)
 	if err != nil {
 		return false // If there's an error in checking admin status, deny access
 	}
@@ -85,13 +112,13 @@ func CheckThreadOwnershipOrAdmin(db *sql.DB, username string, userID int, thread
 }
 
 // Get user ID from username and validate username existence
-func GetUserIDFromUsername(db *sql.DB, username string) (int, error) {
+func (s *PostgresStore) GetUserIDFromUsername(username string) (int, error) {
 	if username == "" {
 		return 0, fmt.Errorf("username is required")
 	}
 
 	var userID int
-	err := db.QueryRow("SELECT id FROM users WHERE username = $1", username).Scan(&userID)
+	err := s.db.QueryRow("SELECT id FROM users WHERE username = $1", username).Scan(&userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return 0, fmt.Errorf("username does not exist")
@@ -103,9 +130,9 @@ func GetUserIDFromUsername(db *sql.DB, username string) (int, error) {
 }
 
 // Check if a username exists in the database
-func CheckUsernameExists(db *sql.DB, username string) (bool, error) {
+func (s *PostgresStore) CheckUsernameExists(username string) (bool, error) {
 	var existingUser string
-	err := db.QueryRow("SELECT username FROM users WHERE username = $1", username).Scan(&existingUser)
+	err := s.db.QueryRow("SELECT username FROM users WHERE username = $1", username).Scan(&existingUser)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil // Username does not exist
@@ -116,17 +143,17 @@ func CheckUsernameExists(db *sql.DB, username string) (bool, error) {
 }
 
 // Insert a new user into the database
-func CreateUser(db *sql.DB, username string, password string) error {
-	_, err := db.Exec("INSERT INTO users (username, password) VALUES ($1, $2)", username, password)
+func (s *PostgresStore) CreateUser(username string, password string) error {
+	_, err := s.db.Exec("INSERT INTO users (username, password) VALUES ($1, $2)", username, password)
 	return err
 }
 
 // Get the hashed password for a user
-func GetPassword(db *sql.DB, username string) (string, error) {
+func (s *PostgresStore) GetPassword(username string) (string, error) {
 	var hashedPassword string
 
 	// Query the database for the hashed password
-	err := db.QueryRow("SELECT password FROM users WHERE username = $1", username).Scan(&hashedPassword)
+	err := s.db.QueryRow("SELECT password FROM users WHERE username = $1", username).Scan(&hashedPassword)
 	if err != nil {
 		return "", err
 	}
@@ -134,17 +161,29 @@ func GetPassword(db *sql.DB, username string) (string, error) {
 	return hashedPassword, nil
 }
 
-// Update the password for a user
-func UpdatePassword(db *sql.DB, username string, newPassword string) error {
-	_, err := db.Exec("UPDATE users SET password = $1 WHERE username = $2", newPassword, username)
+// Update the password for a user and sign out all of their sessions
+func (s *PostgresStore) UpdatePassword(username string, newPassword string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow("UPDATE users SET password = $1 WHERE username = $2 RETURNING id", newPassword, username).Scan(&userID)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	if err := revokeUserSessions(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // Fetch a user's activity scores
-func FetchUserScores(db *sql.DB, username string) (*UserScores, error) {
+func (s *PostgresStore) FetchUserScores(username string) (*UserScores, error) {
 	query := `
 		SELECT 
 			u.id AS user_id,
@@ -152,41 +191,29 @@ func FetchUserScores(db *sql.DB, username string) (*UserScores, error) {
 
 			-- Threads Score
 			COUNT(CASE WHEN t.parent_id IS NULL THEN 1 END) * 5 +
-			COALESCE(AVG(CASE WHEN t.parent_id IS NULL THEN l.likes END), 0) * 10 AS threads_score,
+			COALESCE(AVG(CASE WHEN t.parent_id IS NULL THEN t.likes_count END), 0) * 10 AS threads_score,
 
 			-- Comments Score
 			COUNT(CASE WHEN t.parent_id IS NOT NULL THEN 1 END) * 2 +
-			COALESCE(AVG(CASE WHEN t.parent_id IS NOT NULL THEN lc.likes END), 0) * 5 AS comments_score,
+			COALESCE(AVG(CASE WHEN t.parent_id IS NOT NULL THEN t.likes_count END), 0) * 5 AS comments_score,
 
 			-- Contribution Score
 			(
 				COUNT(CASE WHEN t.parent_id IS NULL THEN 1 END) * 5 +
-				COALESCE(AVG(CASE WHEN t.parent_id IS NULL THEN l.likes END), 0) * 10 +
+				COALESCE(AVG(CASE WHEN t.parent_id IS NULL THEN t.likes_count END), 0) * 10 +
 				COUNT(CASE WHEN t.parent_id IS NOT NULL THEN 1 END) * 2 +
-				COALESCE(AVG(CASE WHEN t.parent_id IS NOT NULL THEN lc.likes END), 0) * 5 - 
-				COUNT(d.id) * 2
+				COALESCE(AVG(CASE WHEN t.parent_id IS NOT NULL THEN t.likes_count END), 0) * 5 - 
+				COALESCE(SUM(t.dislikes_count), 0) * 2
 			) AS contribution_score
 
 		FROM 
 			users u
-		LEFT JOIN threads t ON u.id = t.user_id
-		LEFT JOIN (
-			SELECT thread_id, COUNT(*) AS likes 
-			FROM likes 
-			GROUP BY thread_id
-		) l ON t.id = l.thread_id
-		LEFT JOIN (
-			SELECT thread_id, COUNT(*) AS likes 
-			FROM likes 
-			GROUP BY thread_id
-		) lc ON t.id = lc.thread_id
-		LEFT JOIN dislikes d ON t.id = d.thread_id
-
+		LEFT JOIN threads t ON u.id = t.user_id AND t.deleted_at IS NULL
 		WHERE u.username = $1
 		GROUP BY u.id, u.username
     `
 
-	row := db.QueryRow(query, username)
+	row := s.db.QueryRow(query, username)
 
 	var entry UserScores
 	err := row.Scan(
@@ -204,7 +231,7 @@ func FetchUserScores(db *sql.DB, username string) (*UserScores, error) {
 }
 
 // Fetch ALL users' activity scores
-func FetchLeaderboard(db *sql.DB) ([]UserScores, error) {
+func (s *PostgresStore) FetchLeaderboard() ([]UserScores, error) {
 	query := `
 		SELECT 
 			u.id AS user_id,
@@ -212,30 +239,29 @@ func FetchLeaderboard(db *sql.DB) ([]UserScores, error) {
 
 			-- Threads Score
 			COUNT(CASE WHEN t.parent_id IS NULL THEN 1 END) * 5 +
-			COALESCE(AVG(CASE WHEN t.parent_id IS NULL THEN l.likes END), 0) * 10 AS threads_score,
+			COALESCE(AVG(CASE WHEN t.parent_id IS NULL THEN t.likes_count END), 0) * 10 AS threads_score,
 
 			-- Comments Score
 			COUNT(CASE WHEN t.parent_id IS NOT NULL THEN 1 END) * 2 +
-			COALESCE(AVG(CASE WHEN t.parent_id IS NOT NULL THEN lc.likes END), 0) * 5 AS comments_score,
+			COALESCE(AVG(CASE WHEN t.parent_id IS NOT NULL THEN t.likes_count END), 0) * 5 AS comments_score,
 
 			-- Contribution Score
 			(
 				COUNT(CASE WHEN t.parent_id IS NULL THEN 1 END) * 5 +
-				COALESCE(AVG(CASE WHEN t.parent_id IS NULL THEN l.likes END), 0) * 10 +
+				COALESCE(AVG(CASE WHEN t.parent_id IS NULL THEN t.likes_count END), 0) * 10 +
 				COUNT(CASE WHEN t.parent_id IS NOT NULL THEN 1 END) * 2 +
-				COALESCE(AVG(CASE WHEN t.parent_id IS NOT NULL THEN lc.likes END), 0) * 5 - 
-				COUNT(d.id) * 2
+				COALESCE(AVG(CASE WHEN t.parent_id IS NOT NULL THEN t.likes_count END), 0) * 5 - 
+				COALESCE(SUM(t.dislikes_count), 0) * 2
 			) AS contribution_score
 
 		FROM 
 			users u
-		LEFT JOIN threads t ON u.id = t.user_id
-		LEFT JOIN dislikes d ON t.id = d.thread_id
+		LEFT JOIN threads t ON u.id = t.user_id AND t.deleted_at IS NULL
 		GROUP BY u.id, u.username
 		ORDER BY contribution_score DESC;
 	`
 
-	rows, err := db.Query(query)
+	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, err
 	}
@@ -262,7 +288,7 @@ func FetchLeaderboard(db *sql.DB) ([]UserScores, error) {
 }
 
 // Fetch a user's visible basic information
-func FetchUserInfo(db *sql.DB, username string) (*UserInfo, error) {
+func (s *PostgresStore) FetchUserInfo(username string) (*UserInfo, error) {
 	query := `
 		SELECT 
 			id AS user_id,
@@ -277,7 +303,7 @@ func FetchUserInfo(db *sql.DB, username string) (*UserInfo, error) {
 		WHERE username = $1;
 	`
 
-	row := db.QueryRow(query, username)
+	row := s.db.QueryRow(query, username)
 
 	var userInfo UserInfo
 	err := row.Scan(
@@ -295,28 +321,22 @@ func FetchUserInfo(db *sql.DB, username string) (*UserInfo, error) {
 }
 
 // Fetch a user's activity metrics
-func FetchUserMetrics(db *sql.DB, username string) (*UserMetrics, error) {
+func (s *PostgresStore) FetchUserMetrics(username string) (*UserMetrics, error) {
 	query := `
 		SELECT 
 			COUNT(CASE WHEN t.parent_id IS NULL THEN 1 END) AS threads_created,
 			COUNT(CASE WHEN t.parent_id IS NOT NULL THEN 1 END) AS comments_made,
-			COALESCE(SUM(likes_count), 0) AS likes_received,
-			COALESCE(SUM(dislikes_count), 0) AS dislikes_received
+			COALESCE(SUM(t.likes_count), 0) AS likes_received,
+			COALESCE(SUM(t.dislikes_count), 0) AS dislikes_received
 		FROM 
 			users u
-		LEFT JOIN threads t ON u.id = t.user_id
-		LEFT JOIN (
-			SELECT thread_id, COUNT(*) AS likes_count FROM likes GROUP BY thread_id
-		) l ON t.id = l.thread_id
-		LEFT JOIN (
-			SELECT thread_id, COUNT(*) AS dislikes_count FROM dislikes GROUP BY thread_id
-		) d ON t.id = d.thread_id
+		LEFT JOIN threads t ON u.id = t.user_id AND t.deleted_at IS NULL
 		WHERE 
 			u.username = $1
 		GROUP BY u.id;
 	`
 
-	row := db.QueryRow(query, username)
+	row := s.db.QueryRow(query, username)
 
 	var metrics UserMetrics
 	err := row.Scan(
@@ -333,7 +353,7 @@ func FetchUserMetrics(db *sql.DB, username string) (*UserMetrics, error) {
 }
 
 // Fetch a user's posts and comments
-func FetchUserActivity(db *sql.DB, username string) (*UserActivity, error) {
+func (s *PostgresStore) FetchUserActivity(username string) (*UserActivity, error) {
 	query := `
 		SELECT 
 			th.id, 
@@ -344,17 +364,18 @@ func FetchUserActivity(db *sql.DB, username string) (*UserActivity, error) {
 			th.user_id, 
 			th.parent_id, 
 			u_parent.username AS parent_author, -- Author of the parent thread
-			(SELECT COUNT(*) FROM likes WHERE thread_id = th.id) AS likes_count,
-			(SELECT COUNT(*) FROM dislikes WHERE thread_id = th.id) AS dislikes_count,
-			(SELECT COUNT(*) FROM threads WHERE parent_id = th.id) AS comments_count
+			th.likes_count,
+			th.dislikes_count,
+			th.comments_count,
+			th.score
 		FROM threads th
 		LEFT JOIN users u_current ON th.user_id = u_current.id
 		LEFT JOIN threads th_parent ON th.parent_id = th_parent.id 
 		LEFT JOIN users u_parent ON th_parent.user_id = u_parent.id 
-		WHERE u_current.username = $1;
+		WHERE u_current.username = $1 AND th.deleted_at IS NULL;
 	`
 
-	rows, err := db.Query(query, username)
+	rows, err := s.db.Query(query, username)
 	if err != nil {
 		return nil, err
 	}
@@ -372,7 +393,7 @@ func FetchUserActivity(db *sql.DB, username string) (*UserActivity, error) {
 			&thread.ID, &thread.Title, &thread.Content,
 			&thread.Author, &thread.CreatedAt, &thread.UserID, &thread.ParentID,
 			&parentAuthor,
-			&thread.LikesCount, &thread.DislikesCount, &thread.CommentsCount,
+			&thread.LikesCount, &thread.DislikesCount, &thread.CommentsCount, &thread.Score,
 		)
 		if err != nil {
 			return nil, err
@@ -401,7 +422,7 @@ func FetchUserActivity(db *sql.DB, username string) (*UserActivity, error) {
 }
 
 // Fetch saved threads for a user
-func FetchUserSavedThreads(db *sql.DB, userID int) ([]SavedThread, error) {
+func (s *PostgresStore) FetchUserSavedThreads(userID int) ([]SavedThread, error) {
 	query := `
 		SELECT 
 			t.id, t.title, t.created_at
@@ -412,7 +433,7 @@ func FetchUserSavedThreads(db *sql.DB, userID int) ([]SavedThread, error) {
 		WHERE 
 			st.user_id = $1;
 	`
-	rows, err := db.Query(query, userID)
+	rows, err := s.db.Query(query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -436,19 +457,19 @@ func FetchUserSavedThreads(db *sql.DB, userID int) ([]SavedThread, error) {
 }
 
 // Update a user's bio
-func UpdateBio(db *sql.DB, bio string, username string) error {
-	_, err := db.Exec("UPDATE users SET bio = $1 WHERE username = $2", bio, username)
+func (s *PostgresStore) UpdateBio(bio string, username string) error {
+	_, err := s.db.Exec("UPDATE users SET bio = $1 WHERE username = $2", bio, username)
 	return err
 }
 
 // Promote a user to admin
-func PromoteUser(db *sql.DB, username string) error {
-	_, err := db.Exec("UPDATE users SET is_admin = TRUE WHERE username = $1", username)
+func (s *PostgresStore) PromoteUser(username string) error {
+	_, err := s.db.Exec("UPDATE users SET is_admin = TRUE WHERE username = $1", username)
 	return err
 }
 
 // Demote a user from admin
-func DemoteUser(db *sql.DB, username string) error {
-	_, err := db.Exec("UPDATE users SET is_admin = FALSE WHERE username = $1", username)
+func (s *PostgresStore) DemoteUser(username string) error {
+	_, err := s.db.Exec("UPDATE users SET is_admin = FALSE WHERE username = $1", username)
 	return err
 }
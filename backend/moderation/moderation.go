@@ -0,0 +1,243 @@
+package moderation
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrEmptyPattern  = errors.New("pattern is required")
+	ErrInvalidKind   = errors.New("kind must be word, substring or regex")
+	ErrInvalidAction = errors.New("action must be reject, mask or flag")
+)
+
+// Field is a piece of user-written text under review, such as a title
+type Field struct {
+	Name string // Used in violation messages, e.g. "Title"
+	Text *string
+}
+
+// Verdict is the outcome of reviewing content against the rules
+type Verdict struct {
+	Violations []string // Why the content is rejected; empty if it may be saved
+	Flags      []int    // IDs of flag rules the content matched
+}
+
+// Service applies the admin-managed moderation rules to every write
+type Service struct {
+	store models.ModerationStore
+}
+
+// NewService reads rules from the given store
+func NewService(store models.ModerationStore) *Service {
+	return &Service{store: store}
+}
+
+// Review checks fields against the current rules. Matches of mask rules are
+// replaced in place; nil fields are skipped.
+func (s *Service) Review(fields ...Field) (Verdict, error) {
+	rules, err := s.store.FetchModerationRules()
+	if err != nil {
+		return Verdict{}, fmt.Errorf("error fetching moderation rules: %v", err)
+	}
+
+	verdict := Verdict{}
+	flagged := map[int]bool{}
+	for _, field := range fields {
+		if field.Text == nil {
+			continue
+		}
+		text := normalize(*field.Text)
+
+		var masks []span
+		for _, rule := range rules {
+			matches, err := find(rule, text)
+			if err != nil {
+				log.Printf("Skipping moderation rule %d: %v", rule.ID, err)
+				continue
+			}
+			if len(matches) == 0 {
+				continue
+			}
+			switch rule.Action {
+			case models.ActionReject:
+				word := (*field.Text)[matches[0].start:matches[0].end]
+				verdict.Violations = append(verdict.Violations, fmt.Sprintf("%s contains prohibited word: %s", field.Name, word))
+			case models.ActionMask:
+				masks = append(masks, matches...)
+			case models.ActionFlag:
+				if !flagged[rule.ID] {
+					flagged[rule.ID] = true
+					verdict.Flags = append(verdict.Flags, rule.ID)
+				}
+			}
+		}
+		*field.Text = mask(*field.Text, masks)
+	}
+	return verdict, nil
+}
+
+// Flag queues a saved post for review under the rules its verdict matched
+func (s *Service) Flag(threadID int, verdict Verdict) {
+	if len(verdict.Flags) == 0 {
+		return
+	}
+	if err := s.store.FlagThread(threadID, verdict.Flags); err != nil {
+		log.Printf("Failed to flag thread %d for review: %v", threadID, err)
+	}
+}
+
+// ValidateRule checks a rule before it is stored
+func ValidateRule(rule models.ModerationRule) error {
+	if strings.TrimSpace(rule.Pattern) == "" {
+		return ErrEmptyPattern
+	}
+	switch rule.Action {
+	case models.ActionReject, models.ActionMask, models.ActionFlag:
+	default:
+		return ErrInvalidAction
+	}
+	switch rule.Kind {
+	case models.RuleWord, models.RuleSubstring:
+		if normalize(rule.Pattern).text == "" {
+			return ErrEmptyPattern
+		}
+	case models.RuleRegex:
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("invalid regex: %v", err)
+		}
+	default:
+		return ErrInvalidKind
+	}
+	return nil
+}
+
+// A byte range of the original text
+type span struct {
+	start, end int
+}
+
+// Text folded for matching, with the original span behind each byte
+type normalized struct {
+	text    string
+	sources []span
+}
+
+// Lookalike letters from other scripts, folded to Latin
+var homoglyphs = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j',
+	'ѕ': 's', 'ԁ': 'd', 'α': 'a', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v',
+	'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x',
+}
+
+// Folds text so that simple evasions match: compatibility forms (full-width
+// and styled letters, ligatures) are decomposed, accents and invisible
+// characters dropped, case folded, and common lookalike letters mapped to Latin
+func normalize(original string) normalized {
+	var b strings.Builder
+	var sources []span
+	for i, r := range original {
+		source := span{i, i + utf8.RuneLen(r)}
+		if r == utf8.RuneError || unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		for _, d := range norm.NFKD.String(string(r)) {
+			if unicode.Is(unicode.Mn, d) {
+				continue
+			}
+			d = unicode.ToLower(d)
+			if latin, ok := homoglyphs[d]; ok {
+				d = latin
+			}
+			n, _ := b.WriteRune(d)
+			for ; n > 0; n-- {
+				sources = append(sources, source)
+			}
+		}
+	}
+	return normalized{text: b.String(), sources: sources}
+}
+
+// Maps a match in normalized text back to the original text
+func (n normalized) original(start, end int) span {
+	return span{n.sources[start].start, n.sources[end-1].end}
+}
+
+// Finds every match of a rule, as spans of the original text
+func find(rule models.ModerationRule, text normalized) ([]span, error) {
+	var matches []span
+	switch rule.Kind {
+	case models.RuleWord, models.RuleSubstring:
+		pattern := normalize(rule.Pattern).text
+		if pattern == "" {
+			return nil, ErrEmptyPattern
+		}
+		for offset := 0; offset < len(text.text); {
+			i := strings.Index(text.text[offset:], pattern)
+			if i < 0 {
+				break
+			}
+			start, end := offset+i, offset+i+len(pattern)
+			if rule.Kind == models.RuleSubstring || isWordBoundary(text.text, start, end) {
+				matches = append(matches, text.original(start, end))
+			}
+			offset = start + 1
+		}
+	case models.RuleRegex:
+		re, err := regexp.Compile("(?i)" + rule.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, loc := range re.FindAllStringIndex(text.text, -1) {
+			if loc[1] > loc[0] {
+				matches = append(matches, text.original(loc[0], loc[1]))
+			}
+		}
+	default:
+		return nil, ErrInvalidKind
+	}
+	return matches, nil
+}
+
+// Reports whether text[start:end] is not part of a longer word
+func isWordBoundary(text string, start, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:start])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	return !isWordRune(before) && !isWordRune(after)
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
+
+// Replaces each span of text with one asterisk per character
+func mask(text string, spans []span) string {
+	if len(spans) == 0 {
+		return text
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s.end <= last {
+			continue
+		}
+		start := max(s.start, last)
+		b.WriteString(text[last:start])
+		b.WriteString(strings.Repeat("*", utf8.RuneCountInString(text[start:s.end])))
+		last = s.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package notifications
+
+import (
+	"backend/models"
+	"log"
+)
+
+// Like counts that earn the author a notification
+var likeMilestones = []int{1, 10, 25, 50, 100, 250, 500, 1000}
+
+// Service turns forum events into notifications. Delivery is best effort:
+// failures are logged and never fail the request that caused the event.
+type Service struct {
+	store models.NotificationStore
+}
+
+// NewService records notifications in the given store
+func NewService(store models.NotificationStore) *Service {
+	return &Service{store: store}
+}
+
+// Reply notifies the author of a thread or comment about a direct reply
+func (s *Service) Reply(recipientID, actorID, replyID int) {
+	s.send(models.NewNotification{
+		UserID:   recipientID,
+		Type:     models.NotificationReply,
+		ActorID:  &actorID,
+		ThreadID: &replyID,
+	})
+}
+
+// Mention notifies a user mentioned in a thread or comment
+func (s *Service) Mention(recipientID, actorID, threadID int) {
+	s.send(models.NewNotification{
+		UserID:   recipientID,
+		Type:     models.NotificationMention,
+		ActorID:  &actorID,
+		ThreadID: &threadID,
+	})
+}
+
+// LikeMilestone notifies an author when their post's likes reach a milestone
+func (s *Service) LikeMilestone(recipientID, threadID, likes int) {
+	for _, milestone := range likeMilestones {
+		if likes == milestone {
+			s.send(models.NewNotification{
+				UserID:    recipientID,
+				Type:      models.NotificationLikeMilestone,
+				ThreadID:  &threadID,
+				Milestone: &milestone,
+			})
+			return
+		}
+	}
+}
+
+// Promotion notifies a user that an admin promoted them
+func (s *Service) Promotion(recipientID, actorID int) {
+	s.send(models.NewNotification{
+		UserID:  recipientID,
+		Type:    models.NotificationPromotion,
+		ActorID: &actorID,
+	})
+}
+
+// Warning notifies an author that a moderator warned them about a post
+func (s *Service) Warning(recipientID, moderatorID, threadID int) {
+	s.send(models.NewNotification{
+		UserID:   recipientID,
+		Type:     models.NotificationWarning,
+		ActorID:  &moderatorID,
+		ThreadID: &threadID,
+	})
+}
+
+// ThreadReply notifies the subscribers of a thread about a new comment in it
+func (s *Service) ThreadReply(recipientIDs []int, actorID, commentID int) {
+	for _, recipientID := range recipientIDs {
+		s.send(models.NewNotification{
+			UserID:   recipientID,
+			Type:     models.NotificationThreadReply,
+			ActorID:  &actorID,
+			ThreadID: &commentID,
+		})
+	}
+}
+
+// CategoryPost notifies the subscribers of a category about a new thread in it
+func (s *Service) CategoryPost(recipientIDs []int, actorID, threadID int) {
+	for _, recipientID := range recipientIDs {
+		s.send(models.NewNotification{
+			UserID:   recipientID,
+			Type:     models.NotificationCategoryPost,
+			ActorID:  &actorID,
+			ThreadID: &threadID,
+		})
+	}
+}
+
+// Records a notification, skipping events users cause themselves
+func (s *Service) send(n models.NewNotification) {
+	if n.ActorID != nil && *n.ActorID == n.UserID {
+		return
+	}
+	if err := s.store.CreateNotification(n); err != nil {
+		log.Printf("Failed to record %s notification for user %d: %v", n.Type, n.UserID, err)
+	}
+}
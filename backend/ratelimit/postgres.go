@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// PostgresLimiter keeps buckets in the rate_limits table so that every
+// backend instance draws on the same tokens
+type PostgresLimiter struct {
+	db    *sql.DB
+	takes atomic.Int64
+}
+
+// NewPostgresLimiter stores buckets in the given database
+func NewPostgresLimiter(db *sql.DB) *PostgresLimiter {
+	return &PostgresLimiter{db: db}
+}
+
+const selectBucket = "SELECT tokens, updated_at FROM rate_limits WHERE key = $1 FOR UPDATE"
+
+// Take spends a token from the key's bucket, locking its row so concurrent
+// requests on other instances queue behind it
+func (l *PostgresLimiter) Take(key string, policy Policy) (Decision, error) {
+	if l.takes.Add(1)%sweepEvery == 0 {
+		go l.sweep()
+	}
+
+	tx, err := l.db.Begin()
+	if err != nil {
+		return Decision{}, err
+	}
+	defer tx.Rollback()
+
+	var b bucket
+	var updated sql.NullTime
+	err = tx.QueryRow(selectBucket, key).Scan(&b.tokens, &updated)
+	if err == sql.ErrNoRows {
+		// First use: create the row, or lock the one a concurrent request just created
+		if _, err := tx.Exec("INSERT INTO rate_limits (key, tokens) VALUES ($1, $2) ON CONFLICT (key) DO NOTHING", key, policy.Burst); err != nil {
+			return Decision{}, fmt.Errorf("error creating rate limit bucket: %v", err)
+		}
+		err = tx.QueryRow(selectBucket, key).Scan(&b.tokens, &updated)
+	}
+	if err != nil {
+		return Decision{}, fmt.Errorf("error reading rate limit bucket: %v", err)
+	}
+	b.updated = updated.Time // Zero for a new bucket, which starts full
+
+	decision := b.take(policy, time.Now())
+	if _, err := tx.Exec("UPDATE rate_limits SET tokens = $2, updated_at = $3 WHERE key = $1", key, b.tokens, b.updated); err != nil {
+		return Decision{}, fmt.Errorf("error updating rate limit bucket: %v", err)
+	}
+	return decision, tx.Commit()
+}
+
+// Drops buckets that have refilled long ago
+func (l *PostgresLimiter) sweep() {
+	if _, err := l.db.Exec("DELETE FROM rate_limits WHERE updated_at < $1", time.Now().Add(-idleBucket)); err != nil {
+		log.Printf("Failed to sweep idle rate limit buckets: %v", err)
+	}
+}
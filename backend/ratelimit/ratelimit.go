@@ -0,0 +1,128 @@
+// Package ratelimit throttles requests with token buckets. Each bucket holds
+// up to Burst tokens and regains one every Every; a request spends a token or
+// is turned away until one is back.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Route groups with their own policy
+const (
+	Register       = "register"
+	Login          = "login"
+	Post           = "post"
+	Comment        = "comment"
+	Vote           = "vote"
+	Report         = "report"
+	NewAccountPost = "new_account_post" // Shared by threads and comments
+)
+
+// Policy sets the size and refill rate of a bucket. The zero Policy never limits.
+type Policy struct {
+	Burst      int           // Requests allowed at once
+	Every      time.Duration // Time to regain one request
+	AccountAge time.Duration // If set, applies only to accounts younger than this
+}
+
+// DefaultPolicies lists the limit applied to each route group. This is the
+// one place to tune throttling.
+func DefaultPolicies() map[string]Policy {
+	return map[string]Policy{
+		Register: {Burst: 3, Every: 20 * time.Minute},
+		Login:    {Burst: 10, Every: 30 * time.Second},
+		Post:     {Burst: 5, Every: 2 * time.Minute},
+		Comment:  {Burst: 10, Every: 20 * time.Second},
+		Vote:     {Burst: 30, Every: 2 * time.Second},
+		Report:   {Burst: 10, Every: time.Minute},
+		// A minimum interval between posts while an account is new
+		NewAccountPost: {Burst: 1, Every: 30 * time.Second, AccountAge: 24 * time.Hour},
+	}
+}
+
+// Decision is the outcome of taking a token, with what clients are told
+// in X-RateLimit-* and Retry-After headers
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // Until the next token; zero when allowed
+	Reset      time.Duration // Until the bucket is full again
+}
+
+// Limiter spends tokens from buckets identified by key. MemoryLimiter serves
+// a single process; PostgresLimiter shares buckets between instances.
+type Limiter interface {
+	Take(key string, policy Policy) (Decision, error)
+}
+
+// Bucket state, as stored by every limiter
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Refills the bucket for the time since it was last used, then spends a
+// token if one is available
+func (b *bucket) take(policy Policy, now time.Time) Decision {
+	burst := float64(policy.Burst)
+	if b.updated.IsZero() {
+		b.tokens = burst
+	} else if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+float64(elapsed)/float64(policy.Every))
+	}
+	b.updated = now
+
+	decision := Decision{Limit: policy.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - b.tokens) * float64(policy.Every))
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = time.Duration((burst - b.tokens) * float64(policy.Every))
+	return decision
+}
+
+// Buckets left untouched this long are full under any sensible policy
+const idleBucket = 24 * time.Hour
+
+// Takes between sweeps of idle buckets
+const sweepEvery = 1024
+
+// MemoryLimiter keeps buckets in process memory
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+}
+
+// NewMemoryLimiter returns a limiter with every bucket full
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: map[string]*bucket{}}
+}
+
+// Take spends a token from the key's bucket
+func (l *MemoryLimiter) Take(key string, policy Policy) (Decision, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.takes++; l.takes%sweepEvery == 0 {
+		for k, b := range l.buckets {
+			if now.Sub(b.updated) > idleBucket {
+				delete(l.buckets, k)
+			}
+		}
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{}
+		l.buckets[key] = b
+	}
+	return b.take(policy, now), nil
+}
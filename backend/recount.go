@@ -0,0 +1,27 @@
+package main
+
+import (
+	"backend/config"
+	"backend/models"
+	"fmt"
+	"os"
+)
+
+// Handles `main recount` and returns the process exit code
+func runRecount() int {
+	db, err := config.ConnectDatabase()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	repaired, err := models.NewPostgresStore(db).RecountThreadCounters()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to recount thread counters: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Recount complete: %d thread(s) repaired\n", repaired)
+	return 0
+}
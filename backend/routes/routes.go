@@ -3,75 +3,173 @@ package routes
 import (
 	"backend/controllers"
 	"backend/middleware"
-	"database/sql"
+	"backend/ratelimit"
 
 	"github.com/gin-gonic/gin"
 )
 
 // RegisterRoutes sets up all the API routes for the application
-func RegisterRoutes(router *gin.Engine, db *sql.DB) {
+func RegisterRoutes(router *gin.Engine, h *controllers.Handler) {
+	// Throttles a route group under its policy in h.RateLimits
+	limit := func(group string) gin.HandlerFunc {
+		return middleware.RateLimit(h.Limiter, group, h.RateLimits[group])
+	}
+
+	// Search across threads, comments and users
+	router.GET("/search", h.Search)
+
+	// The signed-in user's personalized feed
+	router.GET("/feed", middleware.AuthMiddleware(h.Users), h.GetFeed)
+
 	// Group routes for threads
 	threadRoutes := router.Group("/threads")
 	{
-		threadRoutes.GET("", func(c *gin.Context) { controllers.GetThreads(c, db) })
-		threadRoutes.GET("/categories", func(c *gin.Context) { controllers.GetCategories(c, db) })
-		threadRoutes.GET("/tags", func(c *gin.Context) { controllers.GetTags(c, db) })
-		threadRoutes.GET("/:id/authorize", func(c *gin.Context) { controllers.GetThreadAuthorization(c, db) })
-		threadRoutes.GET("/:id", func(c *gin.Context) { controllers.GetThreadDetails(c, db) })
+		threadRoutes.GET("", h.GetThreads)
+		threadRoutes.GET("/categories", h.GetCategories)
+		threadRoutes.GET("/tags", h.GetTags)
+		threadRoutes.GET("/:id/authorize", h.GetThreadAuthorization)
+		threadRoutes.GET("/:id/events", h.StreamThreadEvents)
+		threadRoutes.GET("/:id/revisions", h.GetThreadRevisions)
+		threadRoutes.GET("/:id/replies", h.GetThreadReplies)
+		threadRoutes.GET("/:id", h.GetThreadDetails)
 	}
 
 	// Protected Thread Routes
 	protectedThreadRoutes := router.Group("/threads")
-	protectedThreadRoutes.Use(middleware.AuthMiddleware())
+	protectedThreadRoutes.Use(middleware.AuthMiddleware(h.Users))
 	{
-		protectedThreadRoutes.POST("", func(c *gin.Context) { controllers.CreateThread(c, db) })
-		protectedThreadRoutes.POST("/:id/comment", func(c *gin.Context) { controllers.CommentThread(c, db) })
-		protectedThreadRoutes.PUT("/:id", func(c *gin.Context) { controllers.UpdateThread(c, db) })
-		protectedThreadRoutes.DELETE("/:id", func(c *gin.Context) { controllers.DeleteThread(c, db) })
+		protectedThreadRoutes.POST("", limit(ratelimit.NewAccountPost), limit(ratelimit.Post), h.CreateThread)
+		protectedThreadRoutes.POST("/:id/comment", limit(ratelimit.NewAccountPost), limit(ratelimit.Comment), h.CommentThread)
+		protectedThreadRoutes.PUT("/:id", h.UpdateThread)
+		protectedThreadRoutes.DELETE("/:id", h.DeleteThread)
+		protectedThreadRoutes.POST("/:id/report", limit(ratelimit.Report), h.ReportThread)
+		protectedThreadRoutes.POST("/:id/revisions/:revisionId/rollback", middleware.RequireAdmin(h.Users), h.RollbackThread)
 	}
 
 	// Group routes for interactions
 	interactionRoutes := router.Group("/threads/:id")
 	{
-		interactionRoutes.GET("/likestate", func(c *gin.Context) { controllers.GetInteractionState(c, db) })
-		interactionRoutes.GET("/likes", func(c *gin.Context) { controllers.GetLikesCount(c, db) })
-		interactionRoutes.GET("/dislikes", func(c *gin.Context) { controllers.GetDislikesCount(c, db) })
-		interactionRoutes.GET("/savestate", func(c *gin.Context) { controllers.GetSaveState(c, db) })
+		interactionRoutes.GET("/likestate", h.GetInteractionState)
+		interactionRoutes.GET("/likes", h.GetLikesCount)
+		interactionRoutes.GET("/dislikes", h.GetDislikesCount)
+		interactionRoutes.GET("/savestate", h.GetSaveState)
 	}
 
 	// Protected Interaction Routes
 	protectedInteractionRoutes := router.Group("/threads/:id")
-	protectedInteractionRoutes.Use(middleware.AuthMiddleware())
+	protectedInteractionRoutes.Use(middleware.AuthMiddleware(h.Users))
 	{
-		protectedInteractionRoutes.POST("/like", func(c *gin.Context) { controllers.LikeThread(c, db) })
-		protectedInteractionRoutes.POST("/dislike", func(c *gin.Context) { controllers.DislikeThread(c, db) })
-		protectedInteractionRoutes.POST("/save", func(c *gin.Context) { controllers.SaveThread(c, db) })
-		protectedInteractionRoutes.DELETE("/like", func(c *gin.Context) { controllers.RemoveLike(c, db) })
-		protectedInteractionRoutes.DELETE("/dislike", func(c *gin.Context) { controllers.RemoveDislike(c, db) })
-		protectedInteractionRoutes.DELETE("/save", func(c *gin.Context) { controllers.UnsaveThread(c, db) })
+		protectedInteractionRoutes.PUT("/vote", limit(ratelimit.Vote), h.VoteThread)
+		protectedInteractionRoutes.POST("/like", limit(ratelimit.Vote), h.LikeThread)
+		protectedInteractionRoutes.POST("/dislike", limit(ratelimit.Vote), h.DislikeThread)
+		protectedInteractionRoutes.POST("/save", h.SaveThread)
+		protectedInteractionRoutes.DELETE("/like", limit(ratelimit.Vote), h.RemoveLike)
+		protectedInteractionRoutes.DELETE("/dislike", limit(ratelimit.Vote), h.RemoveDislike)
+		protectedInteractionRoutes.DELETE("/save", h.UnsaveThread)
+		protectedInteractionRoutes.POST("/subscribe", h.SubscribeThread)
+		protectedInteractionRoutes.DELETE("/subscribe", h.UnsubscribeThread)
+		protectedInteractionRoutes.PUT("/read", h.MarkThreadRead)
 	}
 
 	// Group routes for users
 	userRoutes := router.Group("/users")
 	{
-		userRoutes.POST("", func(c *gin.Context) { controllers.Register(c, db) })
-		userRoutes.POST("/login", func(c *gin.Context) { controllers.Login(c, db) })
-		userRoutes.GET("/:username/authorize", func(c *gin.Context) { controllers.GetAuthorization(c, db) })
-		userRoutes.GET("/:username/info", func(c *gin.Context) { controllers.GetUserInfo(c, db) })
-		userRoutes.GET("/:username/scores", func(c *gin.Context) { controllers.GetUserScores(c, db) })
-		userRoutes.GET("/:username/metrics", func(c *gin.Context) { controllers.GetUserMetrics(c, db) })
-		userRoutes.GET("/:username/activity", func(c *gin.Context) { controllers.GetUserActivity(c, db) })
-		userRoutes.GET("/:username/saved", func(c *gin.Context) { controllers.GetUserSavedThreads(c, db) })
-		userRoutes.GET("/leaderboard", func(c *gin.Context) { controllers.GetLeaderboard(c, db) })
+		userRoutes.POST("", limit(ratelimit.Register), h.Register)
+		userRoutes.POST("/login", limit(ratelimit.Login), h.Login)
+		userRoutes.POST("/refresh", h.RefreshToken)
+		userRoutes.GET("/:username/authorize", h.GetAuthorization)
+		userRoutes.GET("/:username/info", h.GetUserInfo)
+		userRoutes.GET("/:username/scores", h.GetUserScores)
+		userRoutes.GET("/:username/metrics", h.GetUserMetrics)
+		userRoutes.GET("/:username/activity", h.GetUserActivity)
+		userRoutes.GET("/:username/saved", h.GetUserSavedThreads)
+		userRoutes.GET("/leaderboard", h.GetLeaderboard)
+		userRoutes.GET("/search", h.SuggestUsernames)
 	}
 
 	// Protected User Routes
 	protectedUserRoutes := router.Group("/users")
-	protectedUserRoutes.Use(middleware.AuthMiddleware())
+	protectedUserRoutes.Use(middleware.AuthMiddleware(h.Users))
+	{
+		protectedUserRoutes.POST("/logout", h.Logout)
+		protectedUserRoutes.POST("/:username/password", middleware.RequireSelf("username"), h.UpdatePasswordHandler)
+		protectedUserRoutes.PUT("/:username/bio", middleware.RequireSelf("username"), h.UpdateUserBio)
+		protectedUserRoutes.GET("/:username/votes", middleware.RequireSelf("username"), h.GetUserVotes)
+		protectedUserRoutes.GET("/:username/subscriptions", middleware.RequireSelf("username"), h.GetUserSubscriptions)
+		protectedUserRoutes.POST("/:username/follow", h.FollowUser)
+		protectedUserRoutes.DELETE("/:username/follow", h.UnfollowUser)
+		protectedUserRoutes.PUT("/:username/promote", middleware.RequireAdmin(h.Users), h.PromoteUserHandler)
+		protectedUserRoutes.PUT("/:username/demote", middleware.RequireAdmin(h.Users), h.DemoteUserHandler)
+	}
+
+	// Notifications for the signed-in user
+	notificationRoutes := router.Group("/notifications")
+	notificationRoutes.Use(middleware.AuthMiddleware(h.Users))
+	{
+		notificationRoutes.GET("", h.GetNotifications)
+		notificationRoutes.GET("/unread-count", h.GetUnreadNotificationCount)
+		notificationRoutes.PUT("/read", h.MarkAllNotificationsRead)
+		notificationRoutes.PUT("/:id/read", h.MarkNotificationRead)
+		notificationRoutes.GET("/preferences", h.GetNotificationPreferences)
+		notificationRoutes.PUT("/preferences", h.UpdateNotificationPreferences)
+	}
+
+	// Following categories for new threads
+	categorySubscriptionRoutes := router.Group("/categories/:id")
+	categorySubscriptionRoutes.Use(middleware.AuthMiddleware(h.Users))
+	{
+		categorySubscriptionRoutes.POST("/subscribe", h.SubscribeCategory)
+		categorySubscriptionRoutes.DELETE("/subscribe", h.UnsubscribeCategory)
+	}
+
+	// Admin category management; GET /threads/categories lists the tree
+	categoryRoutes := router.Group("/categories")
+	categoryRoutes.Use(middleware.AuthMiddleware(h.Users), middleware.RequireAdmin(h.Users))
+	{
+		categoryRoutes.POST("", h.CreateCategory)
+		categoryRoutes.PUT("/:id", h.UpdateCategory)
+		categoryRoutes.DELETE("/:id", h.DeleteCategory)
+	}
+
+	// Following tags for the feed
+	tagSubscriptionRoutes := router.Group("/tags/:id")
+	tagSubscriptionRoutes.Use(middleware.AuthMiddleware(h.Users))
+	{
+		tagSubscriptionRoutes.POST("/subscribe", h.SubscribeTag)
+		tagSubscriptionRoutes.DELETE("/subscribe", h.UnsubscribeTag)
+	}
+
+	// Admin tag management; GET /threads/tags lists them
+	tagRoutes := router.Group("/tags")
+	tagRoutes.Use(middleware.AuthMiddleware(h.Users), middleware.RequireAdmin(h.Users))
+	{
+		tagRoutes.POST("", h.CreateTag)
+		tagRoutes.PUT("/:id", h.RenameTag)
+		tagRoutes.POST("/:id/merge", h.MergeTags)
+		tagRoutes.DELETE("/:id", h.DeleteTag)
+	}
+
+	// Admin moderation: content rules, flagged posts, the report queue, deleted
+	// posts, and pinning, locking and announcing threads
+	moderationRoutes := router.Group("/moderation")
+	moderationRoutes.Use(middleware.AuthMiddleware(h.Users), middleware.RequireAdmin(h.Users))
 	{
-		userRoutes.POST("/:username/password", func(c *gin.Context) { controllers.UpdatePasswordHandler(c, db) })
-		userRoutes.PUT("/:username/bio", func(c *gin.Context) { controllers.UpdateUserBio(c, db) })
-		userRoutes.PUT("/:username/promote", func(c *gin.Context) { controllers.PromoteUserHandler(c, db) })
-		userRoutes.PUT("/:username/demote", func(c *gin.Context) { controllers.DemoteUserHandler(c, db) })
+		moderationRoutes.GET("/rules", h.GetModerationRules)
+		moderationRoutes.POST("/rules", h.CreateModerationRule)
+		moderationRoutes.PUT("/rules/:id", h.UpdateModerationRule)
+		moderationRoutes.DELETE("/rules/:id", h.DeleteModerationRule)
+		moderationRoutes.GET("/flags", h.GetModerationFlags)
+		moderationRoutes.DELETE("/flags/:id", h.ClearModerationFlags)
+		moderationRoutes.GET("/reports", h.GetReportQueue)
+		moderationRoutes.POST("/reports/:id/resolve", h.ResolveReports)
+		moderationRoutes.GET("/actions", h.GetModerationActions)
+		moderationRoutes.POST("/threads/:id/restore", h.RestoreThread)
+		moderationRoutes.DELETE("/threads/:id", h.PurgeThread)
+		moderationRoutes.PUT("/threads/:id/pin", h.PinThread)
+		moderationRoutes.DELETE("/threads/:id/pin", h.UnpinThread)
+		moderationRoutes.PUT("/threads/:id/lock", h.LockThread)
+		moderationRoutes.DELETE("/threads/:id/lock", h.UnlockThread)
+		moderationRoutes.PUT("/threads/:id/announcement", h.MarkAnnouncement)
+		moderationRoutes.DELETE("/threads/:id/announcement", h.UnmarkAnnouncement)
 	}
 }
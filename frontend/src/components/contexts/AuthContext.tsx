@@ -1,11 +1,12 @@
 import React, { useState, useEffect, useMemo, useCallback } from "react";
 import { createContextProvider } from "./createContext";
 import { jwtDecode } from "jwt-decode";
+import { logoutUser, refreshSession } from "../../services/userService";
 
 interface AuthContextType {
     isLoggedIn: boolean;
     username: string | null;
-    login: (username: string, token: string) => void;
+    login: (username: string, token: string, refreshToken?: string) => void;
     logout: () => void;
 }
 
@@ -23,13 +24,22 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
     const [isLoggedIn, setIsLoggedIn] = useState(false);
     const [username, setUsername] = useState<string | null>(null);
 
-    const logout = useCallback(() => {
+    const clearSession = useCallback(() => {
         localStorage.removeItem("username");
         localStorage.removeItem("jwtToken");
+        localStorage.removeItem("refreshToken");
         setUsername(null);
         setIsLoggedIn(false);
     }, []);
 
+    const logout = useCallback(() => {
+        // Revoke the session server-side; clear locally regardless of the outcome
+        if (localStorage.getItem("jwtToken")) {
+            logoutUser(localStorage.getItem("refreshToken")).catch(() => {});
+        }
+        clearSession();
+    }, [clearSession]);
+
     const handleTokenExpiry = useCallback(
         (token: string): boolean => {
             try {
@@ -37,20 +47,33 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
                 const expirationTime = exp * 1000 - Date.now(); // Calculate time remaining in milliseconds
 
                 if (expirationTime > 0) {
-                    setTimeout(() => {
-                        logout();
+                    setTimeout(async () => {
+                        // Swap the refresh token for a new pair before giving up
+                        const refreshToken = localStorage.getItem("refreshToken");
+                        if (!refreshToken) {
+                            clearSession();
+                            return;
+                        }
+                        try {
+                            const data = await refreshSession(refreshToken);
+                            localStorage.setItem("jwtToken", data.token);
+                            localStorage.setItem("refreshToken", data.refreshToken);
+                            handleTokenExpiry(data.token);
+                        } catch {
+                            clearSession();
+                        }
                     }, expirationTime);
                     return true;
                 } else {
-                    logout();
+                    clearSession();
                     return false;
                 }
             } catch {
-                logout();
+                clearSession();
                 return false;
             }
         },
-        [logout]
+        [clearSession]
     );
 
     useEffect(() => {
@@ -66,10 +89,13 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
     }, [handleTokenExpiry]);
 
     const login = useCallback(
-        (username: string, token: string) => {
+        (username: string, token: string, refreshToken?: string) => {
             if (handleTokenExpiry(token)) {
                 localStorage.setItem("username", username);
                 localStorage.setItem("jwtToken", token);
+                if (refreshToken) {
+                    localStorage.setItem("refreshToken", refreshToken);
+                }
                 setUsername(username);
                 setIsLoggedIn(true);
             } else {
@@ -24,7 +24,7 @@ const Login: React.FC<LoginProps> = ({ open, onClose }) => {
         try {
             const data = await loginUser(username, password);
             showAlert("Login successful!", "success");
-            login(username, data.token);
+            login(username, data.token, data.refreshToken);
             onClose();
         } catch (error: any) {
             showAlert(error.response?.data?.error || "Unexpected error occurred. Please try again.", "error");
@@ -43,7 +43,7 @@ const Register: React.FC<RegisterProps> = ({ open, onClose }) => {
             showAlert("Registration successful!", "success");
 
             const data = await loginUser(username, password); // Automatically log in
-            login(username, data.token);
+            login(username, data.token, data.refreshToken);
 
             navigate("/"); 
             onClose(); 
@@ -41,13 +41,15 @@ const ProfilePage: React.FC = () => {
     const { isOpen: isPasswordModalOpen, openModal: openPasswordModal, closeModal: closePasswordModal } = useModal();
     const { isOpen: isBioModalOpen, openModal: openBioModal, closeModal: closeBioModal } = useModal();
 
-    const { username } = useAuth();
+    const { username, login } = useAuth();
     const { showAlert } = useAlert();
     const { refreshFlag } = useRefresh();
 
     const handlePasswordChange = async (data: { currentPassword: string; newPassword: string }): Promise<boolean> => {
         try {
-            await updatePassword(username ?? "", data.currentPassword, data.newPassword);
+            const tokens = await updatePassword(username ?? "", data.currentPassword, data.newPassword);
+            // Other sessions were signed out; keep this one with the new tokens
+            login(username ?? "", tokens.token, tokens.refreshToken);
             showAlert("Password updated successfully!", "success");
             closePasswordModal(); 
             return true; 
@@ -16,6 +16,22 @@ export const loginUser = async (username: string, password: string): Promise<any
     });
 };
 
+export const refreshSession = async (refreshToken: string): Promise<any> => {
+    return apiCall({
+        url: "/users/refresh",
+        method: "POST",
+        data: { refreshToken },
+    });
+};
+
+export const logoutUser = async (refreshToken: string | null): Promise<any> => {
+    return apiCall({
+        url: "/users/logout",
+        method: "POST",
+        data: { refreshToken },
+    });
+};
+
 export const getAuthorization = async (username: string | null): Promise<any> => {
     return apiCall({
         url: `/users/${username}/authorize`,